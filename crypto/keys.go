@@ -4,6 +4,8 @@ import (
 	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 )
 
@@ -15,27 +17,34 @@ const (
 	AddressLen     = 20
 )
 
+var (
+	ErrInvalidSeed      = errors.New("invalid seed")
+	ErrInvalidPublicKey = errors.New("invalid public key")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrInvalidAddress   = errors.New("invalid address")
+)
+
 type PrivateKey struct {
 	key ed25519.PrivateKey
 }
 
-func NewPrivateKeyFromString(s string) *PrivateKey {
+func NewPrivateKeyFromString(s string) (*PrivateKey, error) {
 	b, err := hex.DecodeString(s)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSeed, err)
 	}
 
 	return NewPrivateKeyFromSeed(b)
 }
 
-func NewPrivateKeyFromSeed(seed []byte) *PrivateKey {
+func NewPrivateKeyFromSeed(seed []byte) (*PrivateKey, error) {
 	if len(seed) != SeedLen {
-		panic("invalid seed length, must be 32")
+		return nil, fmt.Errorf("%w: length (%d) must be %d", ErrInvalidSeed, len(seed), SeedLen)
 	}
 
 	return &PrivateKey{
 		key: ed25519.NewKeyFromSeed(seed),
-	}
+	}, nil
 }
 
 func NewPrivateKey() *PrivateKey {
@@ -69,13 +78,13 @@ type PublicKey struct {
 	key ed25519.PublicKey
 }
 
-func PublicKeyFromBytes(pubKeyBytes []byte) *PublicKey {
+func PublicKeyFromBytes(pubKeyBytes []byte) (*PublicKey, error) {
 	if len(pubKeyBytes) != PublicKeySize {
-		panic("length of the bytes not equal to 32")
+		return nil, fmt.Errorf("%w: length (%d) must be %d", ErrInvalidPublicKey, len(pubKeyBytes), PublicKeySize)
 	}
 	return &PublicKey{
 		key: ed25519.PublicKey(pubKeyBytes),
-	}
+	}, nil
 }
 
 func (k *PublicKey) Bytes() []byte {
@@ -96,13 +105,13 @@ type Signature struct {
 	value []byte
 }
 
-func SignatureFromBytes(sigByte []byte) *Signature {
+func SignatureFromBytes(sigByte []byte) (*Signature, error) {
 	if len(sigByte) != SignatureLen {
-		panic("length of the bytes not equal to 64")
+		return nil, fmt.Errorf("%w: length (%d) must be %d", ErrInvalidSignature, len(sigByte), SignatureLen)
 	}
 	return &Signature{
 		value: sigByte,
-	}
+	}, nil
 
 }
 
@@ -126,12 +135,12 @@ func (s Address) String() string {
 	return hex.EncodeToString(s.value)
 }
 
-func AddressFromBytes(b []byte) *Address {
+func AddressFromBytes(b []byte) (*Address, error) {
 	if len(b) != AddressLen {
-		panic("length of the bytes not equal to 20")
+		return nil, fmt.Errorf("%w: length (%d) must be %d", ErrInvalidAddress, len(b), AddressLen)
 	}
 
 	return &Address{
 		value: b,
-	}
+	}, nil
 }
@@ -40,3 +40,24 @@ func TestPublicKeyToAddress(t *testing.T) {
 
 	assert.Equal(t, AddressLen, len(address.Bytes()))
 }
+
+func TestKeysFromInvalidBytes(t *testing.T) {
+	_, err := NewPrivateKeyFromString("not hex")
+	assert.ErrorIs(t, err, ErrInvalidSeed)
+
+	_, err = NewPrivateKeyFromSeed(make([]byte, SeedLen-1))
+	assert.ErrorIs(t, err, ErrInvalidSeed)
+
+	_, err = PublicKeyFromBytes(make([]byte, PublicKeySize+1))
+	assert.ErrorIs(t, err, ErrInvalidPublicKey)
+
+	_, err = SignatureFromBytes(nil)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = AddressFromBytes(make([]byte, AddressLen-1))
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+
+	address, err := AddressFromBytes(make([]byte, AddressLen))
+	assert.Nil(t, err)
+	assert.Equal(t, AddressLen, len(address.Bytes()))
+}
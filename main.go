@@ -8,7 +8,7 @@ import (
 	"github.com/pdrm26/blocker/crypto"
 	"github.com/pdrm26/blocker/node"
 	"github.com/pdrm26/blocker/proto"
-	"github.com/pdrm26/blocker/utils"
+	"github.com/pdrm26/blocker/types"
 )
 
 func main() {
@@ -18,15 +18,23 @@ func main() {
 	time.Sleep(time.Second)
 	makeNode(":5000", []string{":4000"}, false)
 
+	// the demo spends the genesis output, then the change of every tx it
+	// sent, so the txs are valid and make it into blocks
+	var (
+		privKey = node.GenesisPrivKey()
+		prevTx  = node.GenesisTransaction()
+	)
 	for {
 		time.Sleep(time.Second)
-		makeTx()
+		if tx := makeTx(privKey, prevTx); tx != nil {
+			prevTx = tx
+		}
 	}
 }
 
 func makeNode(listenAddr string, bootstrapNodes []string, isValidator bool) *node.Node {
 	serverConfig := node.ServerConfig{
-		Version:    1,
+		Version:    node.ProtocolVersion,
 		ListenAddr: listenAddr,
 	}
 	if isValidator {
@@ -37,31 +45,42 @@ func makeNode(listenAddr string, bootstrapNodes []string, isValidator bool) *nod
 	return n
 }
 
-func makeTx() {
+// makeTx sends a tx that spends the first output of prevTx, paying a fee of
+// one, and returns it if the node took it.
+func makeTx(privKey *crypto.PrivateKey, prevTx *proto.Transaction) *proto.Transaction {
 	client, err := node.MakeNodeClient(":3000")
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	privKey := crypto.NewPrivateKey()
+	amount := prevTx.Outputs[0].Amount - 1
+	if amount <= 0 {
+		log.Println("demo output spent")
+		return nil
+	}
 	tx := &proto.Transaction{
 		Version: 1,
 		Inputs: []*proto.TxInput{
 			{
-				PrevTxHash:   utils.RandomHash(),
+				PrevTxHash:   types.HashTransaction(prevTx),
 				PrevOutIndex: 0,
 				PublicKey:    privKey.Public().Bytes(),
 			},
 		},
 		Outputs: []*proto.TxOutput{
 			{
-				Amount:  99,
+				Amount:  amount,
 				Address: privKey.Public().Address().Bytes(),
 			},
 		},
 	}
+	tx.Inputs[0].Signature = types.SignTransaction(tx, privKey).Bytes()
+
 	_, err = client.HandleTransaction(context.TODO(), tx)
 	if err != nil {
-		log.Fatal("HandleTransaction failed:", err)
+		log.Println("tx rejected:", node.RejectReason(err))
+		return nil
 	}
+
+	return tx
 }
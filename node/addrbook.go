@@ -0,0 +1,430 @@
+package node
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pdrm26/blocker/proto"
+	pb "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	// maxAddrs is how many addresses the address book keeps.
+	maxAddrs = 2000
+	// maxGetAddrs is the most addresses a reply to an addrs request carries.
+	maxGetAddrs = 100
+	// maxAddrFailures is how many dials in a row may fail before an address
+	// we never reached is forgotten.
+	maxAddrFailures = 10
+
+	defaultMaxOutbound = 8
+	defaultMaxInbound  = 32
+
+	// dialInterval is how often we dial more peers while below the
+	// outbound target.
+	dialInterval = 10 * time.Second
+)
+
+// AddrBook holds the addresses of the nodes we know about, with how dialing
+// them went. When it has a path, Save writes it there so it survives
+// restarts.
+type AddrBook struct {
+	lock  sync.Mutex
+	path  string
+	addrs map[string]*proto.KnownAddr
+}
+
+func newAddrBook(path string) *AddrBook {
+	return &AddrBook{
+		path:  path,
+		addrs: make(map[string]*proto.KnownAddr),
+	}
+}
+
+// NewAddrBook creates an address book saved at path, loading the addresses
+// already there. An empty path keeps the addresses in memory only.
+func NewAddrBook(path string) (*AddrBook, error) {
+	b := newAddrBook(path)
+	if path == "" {
+		return b, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := &proto.AddrList{}
+	if err := pb.Unmarshal(data, list); err != nil {
+		return nil, fmt.Errorf("address book %s: %w", path, err)
+	}
+	for _, addr := range list.Addrs {
+		if len(b.addrs) == maxAddrs {
+			break
+		}
+		b.addrs[addr.Addr] = addr
+	}
+
+	return b, nil
+}
+
+// Add records addrs we heard of. Addresses already known are left as they
+// are. A full book makes room by forgetting the addresses we never reached
+// that were seen the longest ago. Addresses we did reach are never given up
+// for gossip, so what doesn't fit after that is dropped.
+func (b *AddrBook) Add(addrs ...string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var fresh []string
+	for _, addr := range addrs {
+		if _, ok := b.addrs[addr]; ok || addr == "" || slices.Contains(fresh, addr) {
+			continue
+		}
+		fresh = append(fresh, addr)
+	}
+	if over := len(b.addrs) + len(fresh) - maxAddrs; over > 0 {
+		b.evictLocked(over)
+	}
+
+	now := time.Now().Unix()
+	for _, addr := range fresh {
+		if len(b.addrs) >= maxAddrs {
+			return
+		}
+		b.addrs[addr] = &proto.KnownAddr{Addr: addr, LastSeen: now}
+	}
+}
+
+// evictLocked forgets up to count addresses we never reached, the ones seen
+// the longest ago first, and returns how many it forgot.
+func (b *AddrBook) evictLocked(count int) int {
+	var untried []*proto.KnownAddr
+	for _, addr := range b.addrs {
+		if addr.Successes == 0 {
+			untried = append(untried, addr)
+		}
+	}
+	slices.SortFunc(untried, func(a, b *proto.KnownAddr) int {
+		return cmp.Compare(a.LastSeen, b.LastSeen)
+	})
+
+	untried = untried[:min(count, len(untried))]
+	for _, addr := range untried {
+		delete(b.addrs, addr.Addr)
+	}
+	return len(untried)
+}
+
+// Attempt records that we are dialing addr.
+func (b *AddrBook) Attempt(addr string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if known, ok := b.addrs[addr]; ok {
+		known.LastAttempt = time.Now().Unix()
+	}
+}
+
+// Good records that we connected to addr.
+func (b *AddrBook) Good(addr string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	known, ok := b.addrs[addr]
+	if !ok {
+		if len(b.addrs) >= maxAddrs && b.evictLocked(1) == 0 {
+			b.evictLeastRecentSuccessLocked()
+		}
+		known = &proto.KnownAddr{Addr: addr}
+		b.addrs[addr] = known
+	}
+	now := time.Now().Unix()
+	known.LastSeen = now
+	known.LastSuccess = now
+	known.Successes++
+	known.Failures = 0
+}
+
+// evictLeastRecentSuccessLocked makes room in a book full of addresses we
+// reached by forgetting the one we reached the longest ago.
+func (b *AddrBook) evictLeastRecentSuccessLocked() {
+	var oldest *proto.KnownAddr
+	for _, addr := range b.addrs {
+		if oldest == nil || addr.LastSuccess < oldest.LastSuccess {
+			oldest = addr
+		}
+	}
+	if oldest != nil {
+		delete(b.addrs, oldest.Addr)
+	}
+}
+
+// Failed records that dialing addr failed. Addresses we never reached are
+// forgotten after maxAddrFailures failures in a row.
+func (b *AddrBook) Failed(addr string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	known, ok := b.addrs[addr]
+	if !ok {
+		return
+	}
+	known.Failures++
+	if known.Failures >= maxAddrFailures && known.Successes == 0 {
+		delete(b.addrs, addr)
+	}
+}
+
+// Select picks up to n addresses to dial at random, skipping the ones skip
+// reports true for and the ones that failed too recently to try again.
+func (b *AddrBook) Select(n int, skip func(addr string) bool) []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	var candidates []string
+	for addr, known := range b.addrs {
+		retry := time.Unix(known.LastAttempt, 0).Add(reconnectDelay(int(known.Failures)))
+		if known.Failures > 0 && now.Before(retry) {
+			continue
+		}
+		candidates = append(candidates, addr)
+	}
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	var selected []string
+	for _, addr := range candidates {
+		if len(selected) == n {
+			break
+		}
+		if !skip(addr) {
+			selected = append(selected, addr)
+		}
+	}
+
+	return selected
+}
+
+// Sample returns up to n random addresses that did not fail the last time
+// we dialed them, to share with a peer.
+func (b *AddrBook) Sample(n int) []*proto.KnownAddr {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var addrs []*proto.KnownAddr
+	for _, known := range b.addrs {
+		if known.Failures == 0 {
+			addrs = append(addrs, &proto.KnownAddr{Addr: known.Addr, LastSeen: known.LastSeen})
+		}
+	}
+	rand.Shuffle(len(addrs), func(i, j int) {
+		addrs[i], addrs[j] = addrs[j], addrs[i]
+	})
+
+	return addrs[:min(n, len(addrs))]
+}
+
+func (b *AddrBook) Get(addr string) (*proto.KnownAddr, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	known, ok := b.addrs[addr]
+	if !ok {
+		return nil, false
+	}
+	return pb.Clone(known).(*proto.KnownAddr), true
+}
+
+func (b *AddrBook) Len() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return len(b.addrs)
+}
+
+// Save writes the book to its path, through a temporary file so a crash
+// never leaves half of it behind.
+func (b *AddrBook) Save() error {
+	if b.path == "" {
+		return nil
+	}
+
+	b.lock.Lock()
+	list := &proto.AddrList{}
+	for _, known := range b.addrs {
+		list.Addrs = append(list.Addrs, known)
+	}
+	data, err := pb.Marshal(list)
+	b.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
+
+// addAddrs puts the addresses we heard of in the address book, leaving out
+// our own.
+func (n *Node) addAddrs(addrs ...string) {
+	n.addrs.Add(slices.DeleteFunc(slices.Clone(addrs), func(addr string) bool {
+		return addr == n.ListenAddr
+	})...)
+}
+
+// checkPeerList makes sure the peers the node at addr sent in its handshake
+// would fit in a reply to an addrs request, so a handshake can't flood the
+// address book either.
+func (n *Node) checkPeerList(addr string, info *proto.PeerInfo) error {
+	if len(info.PeerList) <= maxGetAddrs {
+		return nil
+	}
+
+	n.misbehaving(addr, misbehaviorProtocol)
+	return fmt.Errorf("%w: peer list of (%d) addresses max (%d)", ErrHandshakeFailed, len(info.PeerList), maxGetAddrs)
+}
+
+// getAddrs shares some of the addresses we know with a peer.
+func (n *Node) getAddrs() *proto.AddrList {
+	return &proto.AddrList{Addrs: n.addrs.Sample(maxGetAddrs)}
+}
+
+func (n *Node) dialLoop() {
+	ticker := time.NewTicker(dialInterval)
+
+	for {
+		n.fillOutbound()
+		if err := n.addrs.Save(); err != nil {
+			n.logger.Errorw("could not save address book", "error", err)
+		}
+		<-ticker.C
+	}
+}
+
+// fillOutbound dials random addresses from the book until we have as many
+// outbound peers as we aim for, counting the dials still under way.
+func (n *Node) fillOutbound() {
+	n.peerLock.RLock()
+	dialing := len(n.dialing)
+	n.peerLock.RUnlock()
+
+	need := n.maxOutbound() - n.outboundCount() - dialing
+	if need <= 0 {
+		return
+	}
+
+	for _, addr := range n.addrs.Select(need, n.skipDial) {
+		go n.dialAddr(addr)
+	}
+}
+
+func (n *Node) skipDial(addr string) bool {
+	if !n.canConnectWith(addr) {
+		return true
+	}
+
+	n.peerLock.RLock()
+	defer n.peerLock.RUnlock()
+
+	return n.dialing[addr]
+}
+
+// dialAddr connects to the node at addr and asks it for more addresses.
+func (n *Node) dialAddr(addr string) {
+	n.peerLock.Lock()
+	if n.dialing[addr] {
+		n.peerLock.Unlock()
+		return
+	}
+	n.dialing[addr] = true
+	n.peerLock.Unlock()
+
+	defer func() {
+		n.peerLock.Lock()
+		delete(n.dialing, addr)
+		n.peerLock.Unlock()
+	}()
+
+	n.addrs.Attempt(addr)
+	p, err := n.dialRemoteNode(addr)
+	if err != nil {
+		n.logger.Debugw("dial failed", "remote", addr, "error", err)
+		n.addrs.Failed(addr)
+		return
+	}
+	n.addrs.Good(addr)
+	n.addPeer(p)
+
+	if p.version >= versionAddrGossip {
+		n.requestAddrs(p)
+	}
+}
+
+// requestAddrs asks p for the addresses it knows.
+func (n *Node) requestAddrs(p *peerConn) {
+	resp, err := n.peerRequest(p, &proto.Envelope{Payload: &proto.Envelope_AddrsRequest{AddrsRequest: &emptypb.Empty{}}})
+	if err != nil {
+		n.logger.Debugw("getaddrs failed", "remote", p.addr, "error", err)
+		return
+	}
+	list := resp.GetAddrs()
+	if list == nil || len(list.Addrs) > maxGetAddrs {
+		n.misbehaving(p.addr, misbehaviorProtocol)
+		return
+	}
+
+	for _, known := range list.Addrs {
+		n.addAddrs(known.Addr)
+	}
+}
+
+func (n *Node) maxOutbound() int {
+	if n.MaxOutbound > 0 {
+		return n.MaxOutbound
+	}
+	return defaultMaxOutbound
+}
+
+func (n *Node) maxInbound() int {
+	if n.MaxInbound > 0 {
+		return n.MaxInbound
+	}
+	return defaultMaxInbound
+}
+
+func (n *Node) outboundCount() int {
+	return n.countPeers(false)
+}
+
+func (n *Node) inboundCount() int {
+	return n.countPeers(true)
+}
+
+func (n *Node) countPeers(inbound bool) int {
+	n.peerLock.RLock()
+	defer n.peerLock.RUnlock()
+
+	count := 0
+	for _, conn := range n.peers {
+		if conn.inbound == inbound {
+			count++
+		}
+	}
+	return count
+}
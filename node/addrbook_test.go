@@ -0,0 +1,194 @@
+package node
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pdrm26/blocker/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func noSkip(string) bool { return false }
+
+func TestAddrBookBookkeeping(t *testing.T) {
+	b := newAddrBook("")
+	b.Add("a", "b", "a", "")
+	assert.Equal(t, 2, b.Len())
+
+	b.Attempt("a")
+	b.Good("a")
+	known, ok := b.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, int32(1), known.Successes)
+	assert.NotZero(t, known.LastSuccess)
+	assert.NotZero(t, known.LastAttempt)
+
+	// reached once, so it stays however often it fails
+	for i := 0; i < maxAddrFailures; i++ {
+		b.Failed("a")
+		b.Failed("b")
+	}
+	known, ok = b.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, int32(maxAddrFailures), known.Failures)
+	_, ok = b.Get("b")
+	assert.False(t, ok)
+}
+
+func TestAddrBookEvictsOldest(t *testing.T) {
+	b := newAddrBook("")
+	for i := 0; i < maxAddrs; i++ {
+		b.Add(fmt.Sprintf("addr%d", i))
+	}
+	b.addrs["addr7"].LastSeen = 1
+
+	b.Add("new")
+	assert.Equal(t, maxAddrs, b.Len())
+	_, ok := b.Get("addr7")
+	assert.False(t, ok)
+	_, ok = b.Get("new")
+	assert.True(t, ok)
+}
+
+func TestAddrBookKeepsReachedAddrs(t *testing.T) {
+	b := newAddrBook("")
+	for i := 0; i < maxAddrs; i++ {
+		b.Add(fmt.Sprintf("addr%d", i))
+	}
+	b.Good("addr1")
+	b.Good("addr2")
+
+	// gossip pushes out everything but the addresses we reached
+	var flood []string
+	for i := 0; i < 2*maxAddrs; i++ {
+		flood = append(flood, fmt.Sprintf("fake%d", i))
+	}
+	b.Add(flood...)
+	assert.Equal(t, maxAddrs, b.Len())
+	for _, addr := range []string{"addr1", "addr2"} {
+		_, ok := b.Get(addr)
+		assert.True(t, ok, addr)
+	}
+
+	// and with only those left, gossip doesn't get in at all
+	for _, known := range b.addrs {
+		known.Successes = 1
+	}
+	b.Add("new")
+	_, ok := b.Get("new")
+	assert.False(t, ok)
+	b.Good("reached")
+	_, ok = b.Get("reached")
+	assert.True(t, ok)
+	assert.Equal(t, maxAddrs, b.Len())
+}
+
+func TestAddrBookSelect(t *testing.T) {
+	b := newAddrBook("")
+	b.Add("a", "b", "c", "d")
+
+	assert.Equal(t, 2, len(b.Select(2, noSkip)))
+	assert.Equal(t, 4, len(b.Select(10, noSkip)))
+	assert.ElementsMatch(t, []string{"a", "b"}, b.Select(10, func(addr string) bool {
+		return addr == "c" || addr == "d"
+	}))
+
+	// a failed dial waits for its backoff before it is tried again
+	b.Attempt("a")
+	b.Failed("a")
+	assert.NotContains(t, b.Select(10, noSkip), "a")
+	b.addrs["a"].LastAttempt = time.Now().Add(-time.Hour).Unix()
+	assert.Contains(t, b.Select(10, noSkip), "a")
+}
+
+func TestAddrBookPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "addrs")
+
+	b, err := NewAddrBook(path)
+	assert.Nil(t, err)
+	b.Add("a", "b")
+	b.Good("b")
+	assert.Nil(t, b.Save())
+
+	b, err = NewAddrBook(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, b.Len())
+	known, ok := b.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, int32(1), known.Successes)
+}
+
+func TestGetAddrs(t *testing.T) {
+	n := NewNode(ServerConfig{ListenAddr: "self"})
+	for i := 0; i < 2*maxGetAddrs; i++ {
+		n.addAddrs(fmt.Sprintf("addr%d", i))
+	}
+	n.addAddrs("self")
+	n.addrs.Failed("addr0")
+
+	list := n.getAddrs()
+	assert.Equal(t, maxGetAddrs, len(list.Addrs))
+	for _, known := range list.Addrs {
+		assert.NotEqual(t, "self", known.Addr)
+		assert.NotEqual(t, "addr0", known.Addr)
+		assert.Zero(t, known.Successes)
+	}
+}
+
+func TestPeerListGoesToAddrBook(t *testing.T) {
+	n := NewNode(ServerConfig{ListenAddr: "a"})
+	toB, _ := newPipe()
+	addStream(n, "b", toB, &proto.PeerInfo{ListenAddr: "b", PeerList: []string{"a", "c", "d"}})
+
+	assert.Equal(t, 2, n.addrs.Len())
+	assert.Equal(t, []string{"b"}, n.getPeerList())
+}
+
+func TestOversizedPeerListRejected(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{ListenAddr: "a"})
+		b = NewNode(ServerConfig{ListenAddr: "b"})
+	)
+	challenge, info := signedHandshake(b)
+	for i := 0; i <= maxGetAddrs; i++ {
+		info.PeerList = append(info.PeerList, fmt.Sprintf("addr%d", i))
+	}
+
+	_, err := a.acceptPeer(challenge, "b", info)
+	assert.ErrorIs(t, err, ErrHandshakeFailed)
+	assert.Equal(t, misbehaviorProtocol.score, a.bans.Score("b"))
+	assert.Zero(t, a.addrs.Len())
+}
+
+func TestMaxInbound(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{ListenAddr: "a", MaxInbound: 1})
+		b = NewNode(ServerConfig{ListenAddr: "b"})
+	)
+	toC, _ := newPipe()
+	addStream(a, "c", toC, &proto.PeerInfo{ListenAddr: "c"}).inbound = true
+
+	challenge, info := signedHandshake(b)
+	_, err := a.acceptPeer(challenge, "b", info)
+	assert.ErrorIs(t, err, ErrTooManyPeers)
+}
+
+func TestFillOutbound(t *testing.T) {
+	var (
+		n     = NewNode(ServerConfig{MaxOutbound: 2})
+		addrN = freeAddr(t)
+	)
+	for i := 0; i < 3; i++ {
+		addr := freeAddr(t)
+		startNode(t, NewNode(ServerConfig{}), addr)
+		n.addrs.Add(addr)
+	}
+	startNode(t, n, addrN)
+
+	assert.Eventually(t, func() bool { return n.outboundCount() == 2 }, 5*time.Second, 10*time.Millisecond)
+	n.fillOutbound()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 2, n.outboundCount())
+}
@@ -0,0 +1,129 @@
+package node
+
+import (
+	"cmp"
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pdrm26/blocker/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// adminServer serves the Admin service. It runs on a listener of its own,
+// apart from the one peers and clients reach, and only takes calls from
+// this machine.
+type adminServer struct {
+	n *Node
+
+	proto.UnimplementedAdminServer
+}
+
+// serveAdmin serves the Admin service on addr until the listener fails.
+func (n *Node) serveAdmin(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(localOnly))
+	proto.RegisterAdminServer(grpcServer, &adminServer{n: n})
+
+	n.logger.Infow("admin running", "addr", ln.Addr().String())
+	go func() {
+		if err := grpcServer.Serve(ln); err != nil {
+			n.logger.Errorw("admin server stopped", "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// localOnly turns away every call that doesn't come from a loopback
+// address, so binding the admin listener to a public address by mistake
+// doesn't open it up.
+func localOnly(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	host, ok := remoteHost(ctx)
+	if ip := net.ParseIP(host); !ok || ip == nil || !ip.IsLoopback() {
+		return nil, status.Errorf(codes.PermissionDenied, "%s is only served to local callers", info.FullMethod)
+	}
+	return handler(ctx, req)
+}
+
+// MakeAdminClient connects to the Admin service of the node whose admin
+// listener is at targetAddr.
+func MakeAdminClient(targetAddr string) (proto.AdminClient, error) {
+	conn, err := grpc.NewClient(targetAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.NewAdminClient(conn), nil
+}
+
+func (s *adminServer) ListBans(ctx context.Context, _ *emptypb.Empty) (*proto.BanList, error) {
+	return &proto.BanList{Bans: s.n.bans.Bans()}, nil
+}
+
+// BanPeer bans a peer by hand and disconnects it.
+func (s *adminServer) BanPeer(ctx context.Context, req *proto.BanRequest) (*emptypb.Empty, error) {
+	if req.Addr == "" {
+		return nil, status.Error(codes.InvalidArgument, "no peer address")
+	}
+	if req.Duration <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "ban duration (%d) must be positive", req.Duration)
+	}
+
+	// clamped in seconds, the product could overflow otherwise
+	d := time.Duration(min(req.Duration, int64(maxBanDuration/time.Second))) * time.Second
+	if err := s.n.bans.Ban(req.Addr, req.Reason, d); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.n.logger.Infow("banned peer", "remote", req.Addr, "reason", req.Reason, "duration", d)
+	s.n.disconnect(req.Addr)
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *adminServer) UnbanPeer(ctx context.Context, req *proto.BanRequest) (*emptypb.Empty, error) {
+	ok, err := s.n.bans.Unban(req.Addr)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "peer %s is not banned", req.Addr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// ExportSnapshot returns the UTXO snapshot as of the requested height, for
+// starting another node with NewNodeFromSnapshot.
+func (s *adminServer) ExportSnapshot(ctx context.Context, req *proto.SnapshotRequest) (*proto.UTXOSnapshot, error) {
+	snapshot, err := s.n.chain.ExportSnapshot(int(req.Height))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return snapshot.Proto(), nil
+}
+
+// ListPeers returns the peers we are connected to and how they are doing,
+// ordered by address.
+func (s *adminServer) ListPeers(ctx context.Context, _ *emptypb.Empty) (*proto.PeerStatsList, error) {
+	list := &proto.PeerStatsList{}
+	for _, p := range s.n.peerSnapshot() {
+		list.Peers = append(list.Peers, p.stats())
+	}
+	slices.SortFunc(list.Peers, func(a, b *proto.PeerStats) int {
+		return cmp.Or(strings.Compare(a.Addr, b.Addr), strings.Compare(a.Id, b.Id))
+	})
+
+	return list, nil
+}
@@ -0,0 +1,142 @@
+package node
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pdrm26/blocker/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestBanRPCs(t *testing.T) {
+	var (
+		a     = NewNode(ServerConfig{ListenAddr: "a"})
+		b     = NewNode(ServerConfig{ListenAddr: "b"})
+		admin = &adminServer{n: a}
+		ctx   = context.Background()
+	)
+	connect(a, b)
+
+	_, err := admin.BanPeer(ctx, &proto.BanRequest{Addr: "b", Reason: "testing", Duration: 60})
+	assert.Nil(t, err)
+	assert.Empty(t, a.getPeerList())
+
+	list, err := admin.ListBans(ctx, &emptypb.Empty{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(list.Bans))
+	assert.Equal(t, "b", list.Bans[0].Addr)
+	assert.Equal(t, int64(60), list.Bans[0].Until-list.Bans[0].Created)
+
+	_, err = admin.UnbanPeer(ctx, &proto.BanRequest{Addr: "b"})
+	assert.Nil(t, err)
+	assert.True(t, a.canConnectWith("b"))
+
+	_, err = admin.UnbanPeer(ctx, &proto.BanRequest{Addr: "b"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = admin.BanPeer(ctx, &proto.BanRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	for _, d := range []int64{0, -1, math.MinInt64} {
+		_, err = admin.BanPeer(ctx, &proto.BanRequest{Addr: "c", Duration: d})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+
+	// a duration too long to be a time.Duration is cut to the longest ban
+	_, err = admin.BanPeer(ctx, &proto.BanRequest{Addr: "c", Duration: math.MaxInt64})
+	assert.Nil(t, err)
+	until, ok := a.bans.BannedUntil("c")
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(maxBanDuration), until, time.Minute)
+}
+
+func TestAdminLocalOnly(t *testing.T) {
+	var (
+		info    = &grpc.UnaryServerInfo{FullMethod: "/Admin/BanPeer"}
+		handled = 0
+		handler = func(ctx context.Context, req any) (any, error) {
+			handled++
+			return nil, nil
+		}
+	)
+
+	for _, ctx := range []context.Context{callerContext("10.0.0.1:4000"), callerContext("[2001:db8::1]:4000"), context.Background()} {
+		_, err := localOnly(ctx, nil, info, handler)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	}
+	assert.Zero(t, handled)
+
+	for _, ctx := range []context.Context{callerContext("127.0.0.1:4000"), callerContext("[::1]:4000")} {
+		_, err := localOnly(ctx, nil, info, handler)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 2, handled)
+}
+
+func TestAdminServedApart(t *testing.T) {
+	var (
+		addr      = freeAddr(t)
+		adminAddr = freeAddr(t)
+		n         = NewNode(ServerConfig{AdminAddr: adminAddr})
+		ctx       = context.Background()
+	)
+	startNode(t, n, addr)
+
+	admin, err := MakeAdminClient(adminAddr)
+	assert.Nil(t, err)
+	_, err = admin.BanPeer(ctx, &proto.BanRequest{Addr: "10.0.0.1:3000", Reason: "testing", Duration: 60})
+	assert.Nil(t, err)
+	assert.True(t, n.bans.IsBanned("10.0.0.1:3000"))
+
+	// peers and clients reach no admin RPCs
+	public, err := MakeAdminClient(addr)
+	assert.Nil(t, err)
+	_, err = public.UnbanPeer(ctx, &proto.BanRequest{Addr: "10.0.0.1:3000"})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+	assert.True(t, n.bans.IsBanned("10.0.0.1:3000"))
+}
+
+func TestExportSnapshotRPC(t *testing.T) {
+	var (
+		a     = NewNode(ServerConfig{ListenAddr: "a"})
+		admin = &adminServer{n: a}
+		ctx   = context.Background()
+	)
+	chain, _ := chainWithSpend(t)
+	a.chain = chain
+
+	resp, err := admin.ExportSnapshot(ctx, &proto.SnapshotRequest{Height: int32(chain.Height())})
+	assert.Nil(t, err)
+	snapshot := SnapshotFromProto(resp)
+	b, err := NewNodeFromSnapshot(ServerConfig{ListenAddr: "b"}, snapshot, snapshot.Commitment)
+	assert.Nil(t, err)
+	assert.Equal(t, chain.Height(), b.chain.Height())
+
+	_, err = admin.ExportSnapshot(ctx, &proto.SnapshotRequest{Height: int32(chain.Height() + 1)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestListPeersRPC(t *testing.T) {
+	var (
+		a     = NewNode(ServerConfig{ListenAddr: "a"})
+		b     = NewNode(ServerConfig{ListenAddr: "b"})
+		admin = &adminServer{n: a}
+	)
+	connect(a, b)
+	conn, _ := a.peerConn(b.ID())
+	assert.Nil(t, a.ping(conn))
+
+	list, err := admin.ListPeers(context.Background(), &emptypb.Empty{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(list.Peers))
+	peer := list.Peers[0]
+	assert.Equal(t, string(b.ID()), peer.Id)
+	assert.Equal(t, "b", peer.Addr)
+	assert.Greater(t, peer.Latency, int64(0))
+	assert.Equal(t, int64(1), peer.Sent)
+	assert.Zero(t, peer.Failed)
+}
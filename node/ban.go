@@ -0,0 +1,284 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pdrm26/blocker/proto"
+	"google.golang.org/grpc/codes"
+	pb "google.golang.org/protobuf/proto"
+)
+
+const (
+	// banThreshold is the misbehavior score at which a peer gets banned.
+	banThreshold = 100
+	// banDuration is how long a ban lasts unless asked otherwise.
+	banDuration = 24 * time.Hour
+	// maxBanDuration is the longest a ban asked for by hand may last.
+	maxBanDuration = 365 * 24 * time.Hour
+	// scoreDecay is how long it takes for one point of misbehavior to be
+	// forgiven, so honest peers don't add up to a ban over time.
+	scoreDecay = time.Minute
+)
+
+// misbehavior is something a peer did wrong, weighted by how unlikely it is
+// to be an honest mistake.
+type misbehavior struct {
+	score  int
+	reason string
+}
+
+var (
+	misbehaviorInvalidBlock = misbehavior{100, "invalid block"}
+	misbehaviorInvalidTx    = misbehavior{20, "invalid transaction"}
+	misbehaviorProtocol     = misbehavior{50, "protocol violation"}
+	misbehaviorSpam         = misbehavior{10, "spam"}
+	misbehaviorSlow         = misbehavior{5, "slow response"}
+)
+
+type peerScore struct {
+	score   int
+	updated time.Time
+}
+
+// current returns the score with the decay since its last update applied.
+func (s peerScore) current(now time.Time) int {
+	return max(s.score-int(now.Sub(s.updated)/scoreDecay), 0)
+}
+
+// BanList keeps the misbehavior score of every peer, and the peers that are
+// banned. When it has a path, the bans are saved there whenever they change.
+// Peers are known by their host only, like the rate limiter knows them, so a
+// peer can't shed its score or its ban by connecting from another port or
+// claiming to listen on one.
+type BanList struct {
+	lock   sync.Mutex
+	path   string
+	scores map[string]peerScore
+	bans   map[string]*proto.Ban
+}
+
+func newBanList(path string) *BanList {
+	return &BanList{
+		path:   path,
+		scores: make(map[string]peerScore),
+		bans:   make(map[string]*proto.Ban),
+	}
+}
+
+// NewBanList creates a ban list saved at path, loading the bans already
+// there. An empty path keeps the bans in memory only.
+func NewBanList(path string) (*BanList, error) {
+	b := newBanList(path)
+	if path == "" {
+		return b, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := &proto.BanList{}
+	if err := pb.Unmarshal(data, list); err != nil {
+		return nil, fmt.Errorf("ban list %s: %w", path, err)
+	}
+	now := time.Now()
+	for _, ban := range list.Bans {
+		if ban.Until > now.Unix() {
+			ban.Addr = addrHost(ban.Addr)
+			b.bans[ban.Addr] = ban
+		}
+	}
+
+	return b, nil
+}
+
+// misbehaving adds m to the score of the peer at addr, and bans the peer
+// once its score reaches banThreshold. It reports whether the peer got
+// banned.
+func (b *BanList) misbehaving(addr string, m misbehavior) (bool, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	addr = addrHost(addr)
+	now := time.Now()
+	score := b.scores[addr].current(now) + m.score
+	if score < banThreshold {
+		b.scores[addr] = peerScore{score: score, updated: now}
+		return false, nil
+	}
+
+	return true, b.banLocked(addr, m.reason, banDuration)
+}
+
+// Score returns the current misbehavior score of the peer at addr.
+func (b *BanList) Score(addr string) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.scores[addrHost(addr)].current(time.Now())
+}
+
+// Ban bans the peer at addr for d.
+func (b *BanList) Ban(addr string, reason string, d time.Duration) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.banLocked(addrHost(addr), reason, d)
+}
+
+// banLocked bans host for d.
+func (b *BanList) banLocked(host string, reason string, d time.Duration) error {
+	now := time.Now()
+	b.bans[host] = &proto.Ban{
+		Addr:    host,
+		Reason:  reason,
+		Created: now.Unix(),
+		Until:   now.Add(d).Unix(),
+	}
+	delete(b.scores, host)
+
+	return b.saveLocked()
+}
+
+// Unban lifts the ban of the peer at addr and reports whether it was
+// banned.
+func (b *BanList) Unban(addr string) (bool, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	addr = addrHost(addr)
+	if _, ok := b.bans[addr]; !ok {
+		return false, nil
+	}
+	delete(b.bans, addr)
+	delete(b.scores, addr)
+
+	return true, b.saveLocked()
+}
+
+// BannedUntil returns when the ban of the peer at addr ends, if it is
+// banned.
+func (b *BanList) BannedUntil(addr string) (time.Time, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	addr = addrHost(addr)
+	ban, ok := b.bans[addr]
+	if !ok {
+		return time.Time{}, false
+	}
+	until := time.Unix(ban.Until, 0)
+	if !time.Now().Before(until) {
+		delete(b.bans, addr)
+		return time.Time{}, false
+	}
+
+	return until, true
+}
+
+func (b *BanList) IsBanned(addr string) bool {
+	_, ok := b.BannedUntil(addr)
+	return ok
+}
+
+// Bans returns the bans in effect, ordered by address.
+func (b *BanList) Bans() []*proto.Ban {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now().Unix()
+	bans := make([]*proto.Ban, 0, len(b.bans))
+	for _, ban := range b.bans {
+		if ban.Until > now {
+			bans = append(bans, ban)
+		}
+	}
+	slices.SortFunc(bans, func(a, b *proto.Ban) int {
+		return strings.Compare(a.Addr, b.Addr)
+	})
+
+	return bans
+}
+
+// saveLocked writes the bans to a temporary file first, so a crash never
+// leaves a half written list behind.
+func (b *BanList) saveLocked() error {
+	if b.path == "" {
+		return nil
+	}
+
+	list := &proto.BanList{}
+	for _, ban := range b.bans {
+		list.Bans = append(list.Bans, ban)
+	}
+	data, err := pb.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
+
+// misbehaving scores m against the peer at addr, and disconnects every peer
+// on its host if that got it banned.
+func (n *Node) misbehaving(addr string, m misbehavior) {
+	banned, err := n.bans.misbehaving(addr, m)
+	if err != nil {
+		n.logger.Errorw("could not save ban list", "error", err)
+	}
+	if !banned {
+		n.logger.Debugw("peer misbehaving", "remote", addr, "reason", m.reason, "score", n.bans.Score(addr))
+		return
+	}
+
+	n.logger.Infow("banned peer", "remote", addr, "reason", m.reason, "duration", banDuration)
+	n.disconnect(addr)
+}
+
+// misbehaviorFor returns the misbehavior a peer committed by sending data
+// that failed with err. Only the errors an honest peer could not have caused
+// count, so a peer that is merely behind or racing us is left alone.
+func misbehaviorFor(err error, isBlock bool) (misbehavior, bool) {
+	switch {
+	case errors.Is(err, ErrTooManyOrphans):
+		return misbehaviorSpam, true
+	case errors.Is(err, ErrInvalidCompactBlock):
+		return misbehaviorProtocol, true
+	// a peer with a skewed clock or newer software is not malicious
+	case errors.Is(err, ErrTimestampTooNew), errors.Is(err, ErrUnknownVersion), errors.Is(err, ErrUnknownTxVersion):
+		return misbehavior{}, false
+	}
+
+	for _, r := range errorReasons {
+		if r.code != codes.InvalidArgument || !errors.Is(err, r.err) {
+			continue
+		}
+		if isBlock {
+			return misbehaviorInvalidBlock, true
+		}
+		return misbehaviorInvalidTx, true
+	}
+
+	return misbehavior{}, false
+}
+
+// disconnect drops every peer on the host of addr.
+func (n *Node) disconnect(addr string) {
+	for _, p := range n.peersOnHost(addrHost(addr)) {
+		n.removePeer(p.id)
+	}
+}
@@ -0,0 +1,130 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pdrm26/blocker/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBanListBansAtThreshold(t *testing.T) {
+	b := newBanList("")
+
+	for i := 0; i < banThreshold/misbehaviorInvalidTx.score-1; i++ {
+		banned, err := b.misbehaving("peer", misbehaviorInvalidTx)
+		assert.Nil(t, err)
+		assert.False(t, banned)
+	}
+	assert.Equal(t, banThreshold-misbehaviorInvalidTx.score, b.Score("peer"))
+	assert.False(t, b.IsBanned("peer"))
+
+	banned, err := b.misbehaving("peer", misbehaviorInvalidTx)
+	assert.Nil(t, err)
+	assert.True(t, banned)
+	assert.True(t, b.IsBanned("peer"))
+	assert.Equal(t, 0, b.Score("peer"))
+	assert.False(t, b.IsBanned("other"))
+}
+
+func TestPeerScoreDecays(t *testing.T) {
+	now := time.Now()
+	score := peerScore{score: 50, updated: now.Add(-10 * scoreDecay)}
+
+	assert.Equal(t, 40, score.current(now))
+	assert.Equal(t, 0, score.current(now.Add(time.Hour)))
+}
+
+func TestBanExpires(t *testing.T) {
+	b := newBanList("")
+	assert.Nil(t, b.Ban("peer", "testing", -time.Second))
+
+	assert.False(t, b.IsBanned("peer"))
+	assert.Empty(t, b.Bans())
+}
+
+func TestBanListPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans")
+
+	b, err := NewBanList(path)
+	assert.Nil(t, err)
+	assert.Nil(t, b.Ban("a", "spam", time.Hour))
+	assert.Nil(t, b.Ban("b", "invalid block", time.Hour))
+	unbanned, err := b.Unban("b")
+	assert.Nil(t, err)
+	assert.True(t, unbanned)
+
+	b, err = NewBanList(path)
+	assert.Nil(t, err)
+	bans := b.Bans()
+	assert.Equal(t, 1, len(bans))
+	assert.Equal(t, "a", bans[0].Addr)
+	assert.Equal(t, "spam", bans[0].Reason)
+	assert.False(t, b.IsBanned("b"))
+}
+
+func TestMisbehaviorFor(t *testing.T) {
+	m, ok := misbehaviorFor(fmt.Errorf("%w: bad", ErrInvalidSignature), false)
+	assert.True(t, ok)
+	assert.Equal(t, misbehaviorInvalidTx, m)
+
+	m, ok = misbehaviorFor(ErrInvalidBlockSignature, true)
+	assert.True(t, ok)
+	assert.Equal(t, misbehaviorInvalidBlock, m)
+
+	m, ok = misbehaviorFor(ErrTooManyOrphans, false)
+	assert.True(t, ok)
+	assert.Equal(t, misbehaviorSpam, m)
+
+	// things an honest peer runs into
+	for _, err := range []error{ErrDoubleSpend, ErrInsufficientFee, ErrTimestampTooNew, ErrDuplicateBlock, errors.New("eof")} {
+		_, ok := misbehaviorFor(err, true)
+		assert.False(t, ok, err.Error())
+	}
+}
+
+func TestBannedPeerIsDisconnected(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{ListenAddr: "a"})
+		b = NewNode(ServerConfig{ListenAddr: "b"})
+	)
+	connect(a, b)
+
+	a.misbehaving("b", misbehaviorProtocol)
+	assert.Equal(t, []string{"b"}, a.getPeerList())
+
+	a.misbehaving("b", misbehaviorProtocol)
+	assert.Empty(t, a.getPeerList())
+	assert.True(t, a.bans.IsBanned("b"))
+	assert.False(t, a.canConnectWith("b"))
+}
+
+func TestOversizedInventoryIsScored(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{ListenAddr: "a"})
+		b = NewNode(ServerConfig{ListenAddr: "b"})
+	)
+	connect(a, b)
+	p, _ := a.peerConn(b.ID())
+
+	err := a.inventory(p, &proto.InvMessage{Items: make([]*proto.InvItem, maxInvBatch+1)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, misbehaviorProtocol.score, a.bans.Score("b"))
+}
+
+func TestHandshakeFromBannedPeer(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{ListenAddr: "a"})
+		b = NewNode(ServerConfig{ListenAddr: "b"})
+	)
+	assert.Nil(t, a.bans.Ban("b", "testing", time.Hour))
+
+	challenge, info := signedHandshake(b)
+	_, err := a.acceptPeer(challenge, "b", info)
+	assert.ErrorIs(t, err, ErrPeerBanned)
+}
@@ -3,7 +3,10 @@ package node
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/pdrm26/blocker/crypto"
 	"github.com/pdrm26/blocker/proto"
@@ -19,7 +22,22 @@ type UTXO struct {
 	Hash     string
 	OutIndex int
 	Amount   int64
-	Spent    bool
+	Address  []byte
+	Height   int
+}
+
+func utxoKey(hash string, outIndex int) string {
+	return fmt.Sprintf("%s_%d", hash, outIndex)
+}
+
+func (u *UTXO) Key() string {
+	return utxoKey(u.Hash, u.OutIndex)
+}
+
+// BlockUndo holds the outputs a block spent, so the block can be rolled back
+// off the UTXO set after its spent entries have been deleted.
+type BlockUndo struct {
+	Spent []*UTXO
 }
 
 func NewHeaderList() *HeaderList {
@@ -39,6 +57,13 @@ func (h *HeaderList) Get(height int) *proto.Header {
 	return h.headers[height]
 }
 
+// Pop removes and returns the header at the tip of the list.
+func (h *HeaderList) Pop() *proto.Header {
+	header := h.headers[h.Height()]
+	h.headers = h.headers[:h.Height()]
+	return header
+}
+
 func (h *HeaderList) Len() int {
 	return len(h.headers)
 }
@@ -48,16 +73,27 @@ func (h *HeaderList) Height() int {
 }
 
 type Chain struct {
+	lock       sync.RWMutex
 	txStore    TXStorer
 	utxoStore  UTXOStorer
+	undoStore  UndoStorer
 	blockStore BlockStorer
 	headers    *HeaderList
+	// pruneDepth is the number of most recent blocks whose bodies and undo
+	// records are kept. Zero disables pruning.
+	pruneDepth int
+	// snapshot is set when the chain was bootstrapped from a UTXO snapshot.
+	snapshot *UTXOSnapshot
+	// state is the state tree of the UTXO set at the tip, kept up to date
+	// with every block so its root never has to be rebuilt from the store.
+	state types.SparseMerkleTree
 }
 
 func NewChain(blockStore BlockStorer, txStore TXStorer) *Chain {
 	chain := &Chain{
 		txStore:    txStore,
 		utxoStore:  NewMemoryUTXOStore(),
+		undoStore:  NewMemoryUndoStore(),
 		blockStore: blockStore,
 		headers:    NewHeaderList(),
 	}
@@ -65,54 +101,182 @@ func NewChain(blockStore BlockStorer, txStore TXStorer) *Chain {
 	return chain
 }
 
+// EnablePruning makes the chain drop block bodies and undo records that are
+// more than depth blocks below the tip. Headers are always kept, and blocks
+// can only be disconnected down to the pruning depth.
+func (c *Chain) EnablePruning(depth int) error {
+	if depth < 1 {
+		return fmt.Errorf("prune depth must be at least 1, got %d", depth)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.pruneDepth = depth
+
+	for height := 0; height <= c.headers.Height()-depth; height++ {
+		if err := c.pruneBlock(height); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HasFullHistory reports whether the chain keeps every block since genesis,
+// which it doesn't once pruned or when started from a snapshot.
+func (c *Chain) HasFullHistory() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.pruneDepth == 0 && c.snapshot == nil
+}
+
 func (c *Chain) Height() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
 	return c.headers.Height()
 }
 
 func (c *Chain) AddBlock(block *proto.Block) error {
-	if err := c.ValidateBlock(block); err != nil {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.validateBlock(block); err != nil {
 		return err
 	}
 	return c.addBlock(block)
 }
 
 func (c *Chain) addBlock(block *proto.Block) error {
-	c.headers.Add(block.Header)
-
+	height := c.headers.Height() + 1
+	view := newUTXOView(c.utxoStore)
 	for _, tx := range block.Transactions {
+		if err := view.apply(tx, height); err != nil {
+			return err
+		}
+	}
+
+	blockHash := hex.EncodeToString(types.HashBlock(block))
+	for txIndex, tx := range block.Transactions {
 		// for getting the hash of the genesis transaction and use it in the tests like: TestAddBlockWithTX
 		// fmt.Println("NEW TX:", hex.EncodeToString(types.HashTransaction(tx)))
-		if err := c.txStore.Put(tx); err != nil {
+		location := &TXLocation{
+			BlockHash: blockHash,
+			Height:    height,
+			Index:     txIndex,
+		}
+		if err := c.txStore.Put(tx, location); err != nil {
 			return err
 		}
+	}
+
+	state := view.stateTree(c.state)
+	undo := &BlockUndo{Spent: view.spentUTXOs()}
+	for _, utxo := range undo.Spent {
+		if err := c.utxoStore.Delete(utxo.Key()); err != nil {
+			return err
+		}
+	}
+	for _, utxo := range view.added {
+		if err := c.utxoStore.Put(utxo); err != nil {
+			return err
+		}
+	}
+
+	if err := c.undoStore.Put(blockHash, undo); err != nil {
+		return err
+	}
+	if err := c.blockStore.Put(block); err != nil {
+		return err
+	}
+	c.headers.Add(block.Header)
+	c.state = state
+
+	if c.pruneDepth > 0 && height-c.pruneDepth >= 0 {
+		return c.pruneBlock(height - c.pruneDepth)
+	}
+
+	return nil
+}
 
+func (c *Chain) pruneBlock(height int) error {
+	hash := hex.EncodeToString(types.HashHeader(c.headers.Get(height)))
+	if err := c.undoStore.Delete(hash); err != nil {
+		return err
+	}
+	return c.blockStore.Delete(hash)
+}
+
+// DisconnectTip removes the block at the tip of the chain, restoring the
+// outputs it spent from its undo record and deleting the ones it created.
+func (c *Chain) DisconnectTip() (*proto.Block, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.headers.Height() == 0 {
+		return nil, fmt.Errorf("cannot disconnect the genesis block")
+	}
+
+	block, err := c.blockByHeight(c.headers.Height())
+	if err != nil {
+		return nil, err
+	}
+	blockHash := hex.EncodeToString(types.HashBlock(block))
+	undo, err := c.undoStore.Get(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		tx := block.Transactions[i]
 		hash := hex.EncodeToString(types.HashTransaction(tx))
-		for index, output := range tx.Outputs {
-			utxo := &UTXO{
-				Hash:     hash,
-				Amount:   output.Amount,
-				OutIndex: index,
-				Spent:    false,
+		for index := range tx.Outputs {
+			key := utxoKey(hash, index)
+			if _, err := c.utxoStore.Get(key); err != nil {
+				// spent later in the same block, never reached the store
+				continue
 			}
-			if err := c.utxoStore.Put(utxo); err != nil {
-				return err
+			if err := c.utxoStore.Delete(key); err != nil {
+				return nil, err
 			}
 		}
+		if err := c.txStore.Delete(hash); err != nil {
+			return nil, err
+		}
+	}
 
-		for _, input := range tx.Inputs {
-			key := fmt.Sprintf("%s_%d", hex.EncodeToString(input.PrevTxHash), input.PrevOutIndex)
-			utxo, err := c.utxoStore.Get(key)
-			if err != nil {
-				return err
-			}
-			utxo.Spent = true
-			if err := c.utxoStore.Put(utxo); err != nil {
-				return err
-			}
+	for _, utxo := range undo.Spent {
+		if err := c.utxoStore.Put(utxo); err != nil {
+			return nil, err
 		}
 	}
 
-	return c.blockStore.Put(block)
+	if err := c.undoStore.Delete(blockHash); err != nil {
+		return nil, err
+	}
+	c.headers.Pop()
+	c.state = undoState(c.state, block, undo)
+
+	return block, nil
+}
+
+// HasBlock reports whether the block with the given hash is on the chain.
+// Disconnected blocks are still in the store, but no longer count.
+func (c *Chain) HasBlock(hash []byte) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	block, err := c.blockStore.Get(hex.EncodeToString(hash))
+	if err != nil {
+		return false
+	}
+	height := int(block.Header.Height)
+	if height < 0 || height > c.headers.Height() {
+		return false
+	}
+
+	return bytes.Equal(types.HashHeader(c.headers.Get(height)), hash)
 }
 
 func (c *Chain) GetBlockByHash(hash []byte) (*proto.Block, error) {
@@ -121,8 +285,15 @@ func (c *Chain) GetBlockByHash(hash []byte) (*proto.Block, error) {
 }
 
 func (c *Chain) GetBlockByHeight(height int) (*proto.Block, error) {
-	if height > c.Height() {
-		return nil, fmt.Errorf("given height (%d) too heigh - height (%d)", height, c.Height())
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.blockByHeight(height)
+}
+
+func (c *Chain) blockByHeight(height int) (*proto.Block, error) {
+	if height > c.headers.Height() {
+		return nil, fmt.Errorf("given height (%d) too heigh - height (%d)", height, c.headers.Height())
 	}
 
 	header := c.headers.Get(height)
@@ -130,83 +301,250 @@ func (c *Chain) GetBlockByHeight(height int) (*proto.Block, error) {
 	return c.GetBlockByHash(headerHash)
 }
 
-func (c *Chain) createGenesisBlock() *proto.Block {
-	privKey := crypto.NewPrivateKeyFromString(seed)
+// GetTransaction returns a confirmed transaction together with the location
+// of the block it was included in.
+func (c *Chain) GetTransaction(hash TXHash) (*proto.Transaction, *TXLocation, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
 
-	block := &proto.Block{
-		Header: &proto.Header{
-			Version: 1,
-		},
+	tx, err := c.txStore.Get(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	location, err := c.txStore.GetLocation(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, location, nil
+}
+
+func (c *Chain) HasUTXO(key string) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	_, err := c.utxoStore.Get(key)
+	return err == nil
+}
+
+// Confirmations returns the number of blocks built on top of the given
+// location, counting the block the transaction was included in.
+func (c *Chain) Confirmations(location *TXLocation) int {
+	return c.Height() - location.Height + 1
+}
+
+// NewBlockTemplate returns an unsigned block with the transactions on top of
+// the current tip, with every header field filled in.
+func (c *Chain) NewBlockTemplate(txs []*proto.Transaction) (*proto.Block, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	height := c.headers.Height() + 1
+	view := newUTXOView(c.utxoStore)
+	for _, tx := range txs {
+		if err := view.apply(tx, height); err != nil {
+			return nil, err
+		}
 	}
-	tx := &proto.Transaction{
+	header := &proto.Header{
+		Version:   1,
+		Height:    int32(height),
+		PrevHash:  types.HashHeader(c.headers.Get(c.headers.Height())),
+		RootHash:  types.CalculateRootHash(txs),
+		Timestamp: max(time.Now().Unix(), c.medianTimePast()+1),
+		StateRoot: view.stateTree(c.state).Root(),
+	}
+
+	return &proto.Block{Header: header, Transactions: txs}, nil
+}
+
+// GenesisPrivKey returns the key that owns the output of the genesis
+// transaction. Its seed is public, so that output is only good for demos and
+// tests.
+func GenesisPrivKey() *crypto.PrivateKey {
+	// the seed is a constant, so this can only fail if it gets edited badly
+	privKey, err := crypto.NewPrivateKeyFromString(seed)
+	if err != nil {
+		panic(err)
+	}
+
+	return privKey
+}
+
+// GenesisTransaction returns the only transaction of the genesis block.
+func GenesisTransaction() *proto.Transaction {
+	return &proto.Transaction{
 		Version: 1,
 		Inputs:  []*proto.TxInput{},
 		Outputs: []*proto.TxOutput{
 			{
 				Amount:  1000,
-				Address: privKey.Public().Address().Bytes(),
+				Address: GenesisPrivKey().Public().Address().Bytes(),
 			},
 		},
 	}
+}
+
+func (c *Chain) createGenesisBlock() *proto.Block {
+	privKey := GenesisPrivKey()
+	block := &proto.Block{
+		Header: &proto.Header{
+			Version: 1,
+		},
+	}
+	tx := GenesisTransaction()
 
 	block.Transactions = append(block.Transactions, tx)
+	block.Header.RootHash = types.CalculateRootHash(block.Transactions)
+
+	view := newUTXOView(NewMemoryUTXOStore())
+	if err := view.apply(tx, 0); err != nil {
+		panic(err)
+	}
+	block.Header.StateRoot = view.stateTree(types.SparseMerkleTree{}).Root()
 	types.SignBlock(privKey, block)
 
 	return block
 }
 
 func (c *Chain) ValidateBlock(b *proto.Block) error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.validateBlock(b)
+}
+
+func (c *Chain) validateBlock(b *proto.Block) error {
+	if b.Header == nil {
+		return ErrMissingHeader
+	}
+
 	if !types.VerifyBlock(b) {
-		return fmt.Errorf("invalid block signature")
+		return ErrInvalidBlockSignature
 	}
 
-	currentBlock, err := c.GetBlockByHeight(c.Height())
-	if err != nil {
+	hash := types.HashHeader(c.headers.Get(c.headers.Height()))
+	if !bytes.Equal(hash, b.Header.PrevHash) {
+		return ErrInvalidPrevHash
+	}
+
+	if err := c.validateHeader(b.Header); err != nil {
 		return err
 	}
 
-	hash := types.HashBlock(currentBlock)
-	if !bytes.Equal(hash, b.Header.PrevHash) {
-		return fmt.Errorf("invalid previous hash block")
+	if err := validateBlockSize(b); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(types.CalculateRootHash(b.Transactions), b.Header.RootHash) {
+		return ErrInvalidRootHash
 	}
 
+	// Transactions are checked in order against a view, so a block may spend
+	// outputs created earlier in the same block but never spend one twice.
+	view := newUTXOView(c.utxoStore)
 	for _, tx := range b.Transactions {
-		if err := c.ValidateTransaction(tx); err != nil {
+		if _, err := c.validateTransaction(tx, view); err != nil {
+			return err
+		}
+		if err := view.apply(tx, c.headers.Height()+1); err != nil {
 			return err
 		}
 	}
 
+	if !bytes.Equal(view.stateTree(c.state).Root(), b.Header.StateRoot) {
+		return ErrInvalidStateRoot
+	}
+
 	return nil
 }
 
 func (c *Chain) ValidateTransaction(tx *proto.Transaction) error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	_, err := c.validateTransaction(tx, newUTXOView(c.utxoStore))
+	return err
+}
+
+// checkTransaction validates tx against the tip, with the unconfirmed
+// outputs of mempool transactions available as inputs too, and returns the
+// fee it pays.
+func (c *Chain) checkTransaction(tx *proto.Transaction, unconfirmed map[string]*UTXO) (int64, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	view := newUTXOView(c.utxoStore)
+	for key, utxo := range unconfirmed {
+		view.added[key] = utxo
+	}
+
+	return c.validateTransaction(tx, view)
+}
+
+// validateTransaction checks tx against the view and returns the fee it
+// pays, which is what is left of its inputs after its outputs.
+func (c *Chain) validateTransaction(tx *proto.Transaction, view *utxoView) (int64, error) {
+	if err := validateTransactionFormat(tx); err != nil {
+		return 0, err
+	}
+
 	if !types.VerifyTransaction(tx) {
-		return fmt.Errorf("invalid tx signature")
+		return 0, ErrInvalidSignature
 	}
 
-	sumIns := 0
-	for outputIndex := range len(tx.Inputs) {
-		prevHash := hex.EncodeToString(tx.Inputs[outputIndex].PrevTxHash)
-		key := fmt.Sprintf("%s_%d", prevHash, outputIndex)
-		utxo, err := c.utxoStore.Get(key)
+	var sumIns int64
+	seen := make(map[string]bool, len(tx.Inputs))
+	for _, input := range tx.Inputs {
+		prevHash := hex.EncodeToString(input.PrevTxHash)
+		key := utxoKey(prevHash, int(input.PrevOutIndex))
+		if seen[key] {
+			return 0, fmt.Errorf("%w: %s is spent twice by the same tx", ErrDoubleSpend, key)
+		}
+		seen[key] = true
+
+		utxo, err := view.Get(key)
+		if errors.Is(err, ErrDoubleSpend) || (err != nil && c.isSpent(prevHash, int(input.PrevOutIndex))) {
+			return 0, fmt.Errorf("%w: %s", ErrDoubleSpend, key)
+		}
 		if err != nil {
-			return err
+			return 0, fmt.Errorf("%w: %s", ErrUnknownInput, key)
 		}
 
-		sumIns += int(utxo.Amount)
-		if utxo.Spent {
-			return fmt.Errorf("input %d of tx %s is already spent", outputIndex, prevHash)
+		pubKey, err := crypto.PublicKeyFromBytes(input.PublicKey)
+		if err != nil {
+			return 0, ErrInvalidSignature
 		}
+		if !bytes.Equal(pubKey.Address().Bytes(), utxo.Address) {
+			return 0, fmt.Errorf("%w: %s", ErrInputNotOwned, key)
+		}
+
+		sumIns += utxo.Amount
 	}
 
-	sumOuts := 0
-	for _, out := range tx.Outputs {
-		sumOuts += int(out.Amount)
+	var sumOuts int64
+	for index, out := range tx.Outputs {
+		if out.Amount < 0 || sumOuts+out.Amount < sumOuts {
+			return 0, fmt.Errorf("%w: output %d has amount (%d)", ErrInvalidAmount, index, out.Amount)
+		}
+		sumOuts += out.Amount
 	}
 
 	if sumOuts > sumIns {
-		return fmt.Errorf("insufficient balance: have (%d) spent (%d)", sumIns, sumOuts)
+		return 0, fmt.Errorf("%w: have (%d) spent (%d)", ErrInsufficientFunds, sumIns, sumOuts)
 	}
 
-	return nil
+	return sumIns - sumOuts, nil
+}
+
+// isSpent tells whether the output was created by a confirmed transaction
+// and is therefore missing from the UTXO set because it was spent.
+func (c *Chain) isSpent(txHash TXHash, outIndex int) bool {
+	tx, err := c.txStore.Get(txHash)
+	if err != nil {
+		return false
+	}
+
+	return outIndex < len(tx.Outputs)
 }
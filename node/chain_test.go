@@ -1,7 +1,9 @@
 package node
 
 import (
+	"encoding/hex"
 	"testing"
+	"time"
 
 	"github.com/pdrm26/blocker/crypto"
 	"github.com/pdrm26/blocker/proto"
@@ -10,12 +12,27 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func randomBlock(t *testing.T, chain *Chain) *proto.Block {
+// genesisTXHash is the hash of the only transaction in the genesis block.
+const genesisTXHash = "aec69e5c66624dee9d9478033704318c87bc757b27e35eb98d08bae22ea04271"
+
+func genesisPrivKey(t *testing.T) *crypto.PrivateKey {
+	privKey, err := crypto.NewPrivateKeyFromString(seed)
+	assert.Nil(t, err)
+	return privKey
+}
+
+func randomBlock(t *testing.T, chain *Chain, txs ...*proto.Transaction) *proto.Block {
 	privKey := crypto.NewPrivateKey()
 	block := utils.RandomBlock()
-	prevBlock, err := chain.GetBlockByHeight(chain.Height())
-	assert.Nil(t, err)
-	block.Header.PrevHash = types.HashBlock(prevBlock)
+	block.Header.Height = int32(chain.Height() + 1)
+	block.Header.Timestamp = max(time.Now().Unix(), chain.MedianTimePast()+1)
+	block.Header.PrevHash = types.HashHeader(chain.headers.Get(chain.Height()))
+	block.Transactions = txs
+	block.Header.RootHash = types.CalculateRootHash(txs)
+	// blocks meant to fail validation may not have a state root at all
+	if template, err := chain.NewBlockTemplate(txs); err == nil {
+		block.Header.StateRoot = template.Header.StateRoot
+	}
 	types.SignBlock(privKey, block)
 
 	return block
@@ -61,12 +78,11 @@ func TestNewChain(t *testing.T) {
 func TestAddBlockWithTX(t *testing.T) {
 	var (
 		chain     = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
-		block     = randomBlock(t, chain)
-		privKey   = crypto.NewPrivateKeyFromString(seed)
+		privKey   = genesisPrivKey(t)
 		recipient = crypto.NewPrivateKey().Public().Address()
 	)
 
-	genesisTX, err := chain.txStore.Get("10d9f0e9d2be769fa4620206a41718c2569c91bc56073b435975413d631603a5")
+	genesisTX, err := chain.txStore.Get(genesisTXHash)
 	assert.Nil(t, err)
 
 	inputs := []*proto.TxInput{
@@ -91,19 +107,18 @@ func TestAddBlockWithTX(t *testing.T) {
 	txSig := types.SignTransaction(tx, privKey)
 	tx.Inputs[0].Signature = txSig.Bytes()
 
-	block.Transactions = append(block.Transactions, tx)
+	block := randomBlock(t, chain, tx)
 	assert.Nil(t, chain.AddBlock(block))
 }
 
 func TestAddBlockWithInsufficientPaymentTX(t *testing.T) {
 	var (
 		chain     = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
-		block     = randomBlock(t, chain)
-		privKey   = crypto.NewPrivateKeyFromString(seed)
+		privKey   = genesisPrivKey(t)
 		recepient = crypto.NewPrivateKey().Public().Address()
 	)
 
-	genesisTX, err := chain.txStore.Get("10d9f0e9d2be769fa4620206a41718c2569c91bc56073b435975413d631603a5")
+	genesisTX, err := chain.txStore.Get(genesisTXHash)
 	assert.Nil(t, err)
 
 	inputs := []*proto.TxInput{
@@ -124,6 +139,163 @@ func TestAddBlockWithInsufficientPaymentTX(t *testing.T) {
 	txSig := types.SignTransaction(tx, privKey)
 	tx.Inputs[0].Signature = txSig.Bytes()
 
-	block.Transactions = append(block.Transactions, tx)
+	block := randomBlock(t, chain, tx)
+	assert.NotNil(t, chain.AddBlock(block))
+}
+
+func TestGetTransactionLocation(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	_, location, err := chain.GetTransaction(genesisTXHash)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, location.Height)
+	assert.Equal(t, 0, location.Index)
+	assert.Equal(t, 1, chain.Confirmations(location))
+
+	genesisBlock, err := chain.GetBlockByHeight(0)
+	assert.Nil(t, err)
+	assert.Equal(t, hex.EncodeToString(types.HashBlock(genesisBlock)), location.BlockHash)
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, chain.AddBlock(randomBlock(t, chain)))
+	}
+	assert.Equal(t, 11, chain.Confirmations(location))
+
+	_, _, err = chain.GetTransaction(hex.EncodeToString(utils.RandomHash()))
+	assert.NotNil(t, err)
+}
+
+func spendGenesisTX(t *testing.T, chain *Chain) *proto.Transaction {
+	privKey := genesisPrivKey(t)
+	genesisTX, err := chain.txStore.Get(genesisTXHash)
+	assert.Nil(t, err)
+
+	tx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   types.HashTransaction(genesisTX),
+				PrevOutIndex: 0,
+				PublicKey:    privKey.Public().Bytes(),
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  100,
+				Address: crypto.NewPrivateKey().Public().Address().Bytes(),
+			},
+			{
+				Amount:  900,
+				Address: privKey.Public().Address().Bytes(),
+			},
+		},
+	}
+	tx.Inputs[0].Signature = types.SignTransaction(tx, privKey).Bytes()
+
+	return tx
+}
+
+func TestAddBlockDeletesSpentUTXO(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	utxoStore := chain.utxoStore.(*MemoryUTXOStore)
+	assert.Equal(t, 1, utxoStore.Len())
+
+	tx := spendGenesisTX(t, chain)
+	block := randomBlock(t, chain, tx)
+	assert.Nil(t, chain.AddBlock(block))
+
+	genesisKey := utxoKey(genesisTXHash, 0)
+	_, err := utxoStore.Get(genesisKey)
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, utxoStore.Len())
+
+	undo, err := chain.undoStore.Get(hex.EncodeToString(types.HashBlock(block)))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(undo.Spent))
+	assert.Equal(t, genesisKey, undo.Spent[0].Key())
+}
+
+func TestAddBlockWithDoubleSpendInBlock(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	block := randomBlock(t, chain, spendGenesisTX(t, chain), spendGenesisTX(t, chain))
 	assert.NotNil(t, chain.AddBlock(block))
+	assert.Equal(t, 0, chain.Height())
+}
+
+func TestDisconnectTip(t *testing.T) {
+	var (
+		chain = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		tx    = spendGenesisTX(t, chain)
+		block = randomBlock(t, chain, tx)
+	)
+
+	assert.Nil(t, chain.AddBlock(block))
+	txHash := hex.EncodeToString(types.HashTransaction(tx))
+
+	disconnected, err := chain.DisconnectTip()
+	assert.Nil(t, err)
+	assert.Equal(t, block, disconnected)
+	assert.Equal(t, 0, chain.Height())
+
+	_, err = chain.utxoStore.Get(utxoKey(genesisTXHash, 0))
+	assert.Nil(t, err)
+	_, err = chain.utxoStore.Get(utxoKey(txHash, 0))
+	assert.NotNil(t, err)
+	_, _, err = chain.GetTransaction(txHash)
+	assert.NotNil(t, err)
+
+	// the genesis output can be spent again once the block is gone
+	block = randomBlock(t, chain, spendGenesisTX(t, chain))
+	assert.Nil(t, chain.AddBlock(block))
+
+	_, err = chain.DisconnectTip()
+	assert.Nil(t, err)
+	_, err = chain.DisconnectTip()
+	assert.NotNil(t, err)
+}
+
+func TestChainPruning(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, chain.AddBlock(randomBlock(t, chain)))
+	}
+
+	assert.NotNil(t, chain.EnablePruning(0))
+	assert.Nil(t, chain.EnablePruning(3))
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, chain.AddBlock(randomBlock(t, chain)))
+	}
+
+	for height := 0; height <= chain.Height(); height++ {
+		_, err := chain.GetBlockByHeight(height)
+		if height <= chain.Height()-3 {
+			assert.NotNil(t, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := chain.DisconnectTip()
+		assert.Nil(t, err)
+	}
+	_, err := chain.DisconnectTip()
+	assert.NotNil(t, err)
+}
+
+func TestValidateTransactionErrors(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	tx := spendGenesisTX(t, chain)
+	assert.Nil(t, chain.ValidateTransaction(tx))
+	assert.Nil(t, chain.AddBlock(randomBlock(t, chain, tx)))
+
+	assert.ErrorIs(t, chain.ValidateTransaction(spendGenesisTX(t, chain)), ErrDoubleSpend)
+
+	unknown := spendGenesisTX(t, chain)
+	unknown.Inputs[0].PrevTxHash = utils.RandomHash()
+	unknown.Inputs[0].Signature = types.SignTransaction(unknown, genesisPrivKey(t)).Bytes()
+	assert.ErrorIs(t, chain.ValidateTransaction(unknown), ErrUnknownInput)
+
+	unsigned := spendGenesisTX(t, chain)
+	unsigned.Inputs[0].Signature = nil
+	assert.ErrorIs(t, chain.ValidateTransaction(unsigned), ErrInvalidSignature)
 }
@@ -0,0 +1,189 @@
+package node
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/pdrm26/blocker/proto"
+	"github.com/pdrm26/blocker/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// shortIDBytes is how many bytes of the salted tx hash make up a short id.
+const shortIDBytes = 6
+
+// shortTxID returns the short id of a tx in a compact block. Salting it with
+// the per block nonce keeps collisions from being planned ahead.
+func shortTxID(nonce uint64, txHash []byte) uint64 {
+	buf := make([]byte, 8+len(txHash))
+	binary.BigEndian.PutUint64(buf, nonce)
+	copy(buf[8:], txHash)
+	sum := sha256.Sum256(buf)
+
+	var id [8]byte
+	copy(id[8-shortIDBytes:], sum[:shortIDBytes])
+	return binary.BigEndian.Uint64(id[:])
+}
+
+// newCompactBlock turns block into a compact block. The txs prefill reports
+// true for are sent in full, the rest as short ids.
+func newCompactBlock(block *proto.Block, prefill func(hash TXHash) bool) *proto.CompactBlock {
+	cb := &proto.CompactBlock{
+		Header:    block.Header,
+		PublicKey: block.PublicKey,
+		Signature: block.Signature,
+		Nonce:     rand.Uint64(),
+	}
+	for i, tx := range block.Transactions {
+		hash := types.HashTransaction(tx)
+		if prefill(hex.EncodeToString(hash)) {
+			cb.Prefilled = append(cb.Prefilled, &proto.PrefilledTransaction{Index: int32(i), Transaction: tx})
+			continue
+		}
+		cb.ShortIds = append(cb.ShortIds, shortTxID(cb.Nonce, hash))
+	}
+
+	return cb
+}
+
+// reconstructBlock fills in the txs of cb from the prefilled ones and the
+// mempool. It returns the block together with the indexes of the txs it
+// could not find, which are left nil.
+func (n *Node) reconstructBlock(cb *proto.CompactBlock) (*proto.Block, []int32, error) {
+	count := len(cb.ShortIds) + len(cb.Prefilled)
+	if count > MaxBlockTxs {
+		return nil, nil, fmt.Errorf("%w: (%d) max (%d)", ErrTooManyTxs, count, MaxBlockTxs)
+	}
+
+	txs := make([]*proto.Transaction, count)
+	for _, prefilled := range cb.Prefilled {
+		index := int(prefilled.Index)
+		if index < 0 || index >= count || txs[index] != nil || prefilled.Transaction == nil {
+			return nil, nil, fmt.Errorf("%w: prefilled tx at index (%d)", ErrInvalidCompactBlock, index)
+		}
+		txs[index] = prefilled.Transaction
+	}
+
+	byID := make(map[uint64]*proto.Transaction)
+	collided := make(map[uint64]bool)
+	for _, entry := range n.mempool.Entries() {
+		id := shortTxID(cb.Nonce, types.HashTransaction(entry.Tx))
+		if _, ok := byID[id]; ok {
+			collided[id] = true
+		}
+		byID[id] = entry.Tx
+	}
+
+	var (
+		missing []int32
+		next    int
+	)
+	for i := range txs {
+		if txs[i] != nil {
+			continue
+		}
+		id := cb.ShortIds[next]
+		next++
+		if tx, ok := byID[id]; ok && !collided[id] {
+			txs[i] = tx
+			continue
+		}
+		missing = append(missing, int32(i))
+	}
+
+	return &proto.Block{
+		Header:       cb.Header,
+		Transactions: txs,
+		PublicKey:    cb.PublicKey,
+		Signature:    cb.Signature,
+	}, missing, nil
+}
+
+// processCompactBlock rebuilds a compact block sent by p, fetching the txs
+// we don't have from it, and adds the block to the chain.
+func (n *Node) processCompactBlock(p *peerConn, cb *proto.CompactBlock) (*proto.Block, error) {
+	if cb.Header == nil {
+		return nil, ErrMissingHeader
+	}
+	hash := types.HashHeader(cb.Header)
+	if n.chain.HasBlock(hash) {
+		return nil, ErrDuplicateBlock
+	}
+
+	block, missing, err := n.reconstructBlock(cb)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(missing) > 0 {
+		env, err := n.peerRequest(p, &proto.Envelope{Payload: &proto.Envelope_BlockTxsRequest{
+			BlockTxsRequest: &proto.BlockTxRequest{BlockHash: hash, Indexes: missing},
+		}})
+		if err != nil {
+			return nil, err
+		}
+		resp := env.GetBlockTxs()
+		if resp == nil {
+			return nil, fmt.Errorf("%w: peer answered with %T", ErrInvalidCompactBlock, env.Payload)
+		}
+		if len(resp.Transactions) != len(missing) {
+			return nil, fmt.Errorf("%w: got (%d) of (%d) missing txs", ErrInvalidCompactBlock, len(resp.Transactions), len(missing))
+		}
+		for i, index := range missing {
+			block.Transactions[index] = resp.Transactions[i]
+		}
+	}
+
+	err = n.processBlock(block)
+	if !errors.Is(err, ErrInvalidRootHash) {
+		return block, err
+	}
+
+	// some short id matched the wrong mempool tx, get the whole block
+	data, err := n.requestData(p, []*proto.InvItem{{Type: proto.InvType_INV_BLOCK, Hash: hash}})
+	if err != nil {
+		return nil, err
+	}
+	if len(data.Blocks) != 1 || data.Blocks[0].Header == nil || !bytes.Equal(types.HashBlock(data.Blocks[0]), hash) {
+		return nil, fmt.Errorf("%w: peer did not send the full block", ErrInvalidCompactBlock)
+	}
+
+	return data.Blocks[0], n.processBlock(data.Blocks[0])
+}
+
+// getBlockTransactions returns the txs of a block at the requested indexes,
+// for peers that could not rebuild it from a compact block. The indexes must
+// go up, so no tx is asked for twice and the answer is never larger than the
+// block.
+func (n *Node) getBlockTransactions(req *proto.BlockTxRequest) (*proto.BlockTransactions, error) {
+	if !n.chain.HasBlock(req.BlockHash) {
+		return nil, status.Errorf(codes.NotFound, "block %s not found", hex.EncodeToString(req.BlockHash))
+	}
+	block, err := n.chain.GetBlockByHash(req.BlockHash)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	if len(req.Indexes) > len(block.Transactions) {
+		return nil, status.Errorf(codes.InvalidArgument, "(%d) tx indexes for a block of (%d) txs", len(req.Indexes), len(block.Transactions))
+	}
+
+	resp := &proto.BlockTransactions{BlockHash: req.BlockHash}
+	for i, index := range req.Indexes {
+		if index < 0 || int(index) >= len(block.Transactions) {
+			return nil, status.Errorf(codes.InvalidArgument, "tx index (%d) out of range", index)
+		}
+		if i > 0 && index <= req.Indexes[i-1] {
+			return nil, status.Errorf(codes.InvalidArgument, "tx index (%d) after (%d)", index, req.Indexes[i-1])
+		}
+		resp.Transactions = append(resp.Transactions, block.Transactions[index])
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,138 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pdrm26/blocker/crypto"
+	"github.com/pdrm26/blocker/proto"
+	"github.com/pdrm26/blocker/types"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestShortTxID(t *testing.T) {
+	hash := types.HashTransaction(orphanTX(t, crypto.NewPrivateKey(), 0))
+
+	assert.Equal(t, shortTxID(1, hash), shortTxID(1, hash))
+	assert.NotEqual(t, shortTxID(1, hash), shortTxID(2, hash))
+	assert.Less(t, shortTxID(1, hash), uint64(1)<<(8*shortIDBytes))
+}
+
+// twoTxBlock makes a validator mine a block with a tx n has in its mempool
+// and one it has never seen.
+func twoTxBlock(t *testing.T, validator, n *Node) (*proto.Block, *proto.Transaction) {
+	var (
+		privKey = genesisPrivKey(t)
+		parent  = spendOutput(t, privKey, genesisTX(t, validator.chain), 0, 500, 490)
+		unseen  = spendOutput(t, privKey, parent, 1, 480)
+	)
+
+	assert.Nil(t, validator.acceptTransaction(parent))
+	assert.Nil(t, validator.acceptTransaction(unseen))
+	assert.Nil(t, n.acceptTransaction(parent))
+
+	block, err := validator.createBlock()
+	assert.Nil(t, err)
+	return block, unseen
+}
+
+func TestCompactBlockPrefillsUnknownTxs(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{ListenAddr: "a", PrivKey: crypto.NewPrivateKey()})
+		b = NewNode(ServerConfig{ListenAddr: "b"})
+	)
+	block, unseen := twoTxBlock(t, a, b)
+	a.inv.markKnown(b.ID(), hashOf(block.Transactions[0]))
+
+	data, err := a.getData(a.newPeerConn(b.ID(), nil, b.getPeerInfo()), &proto.InvMessage{
+		Items: []*proto.InvItem{{Type: proto.InvType_INV_COMPACT_BLOCK, Hash: types.HashBlock(block)}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(data.CompactBlocks))
+
+	cb := data.CompactBlocks[0]
+	assert.Equal(t, 1, len(cb.ShortIds))
+	assert.Equal(t, 1, len(cb.Prefilled))
+	assert.Equal(t, int32(1), cb.Prefilled[0].Index)
+	assert.Equal(t, hashOf(unseen), hashOf(cb.Prefilled[0].Transaction))
+
+	reconstructed, missing, err := b.reconstructBlock(cb)
+	assert.Nil(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, types.CalculateRootHash(block.Transactions), types.CalculateRootHash(reconstructed.Transactions))
+}
+
+func TestCompactBlockFetchesMissingTxs(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{ListenAddr: "a", PrivKey: crypto.NewPrivateKey()})
+		b = NewNode(ServerConfig{ListenAddr: "b"})
+	)
+	block, _ := twoTxBlock(t, a, b)
+	_, toA := connect(a, b)
+	peerA, _ := b.peerConn(a.ID())
+
+	cb := newCompactBlock(block, func(TXHash) bool { return false })
+	_, missing, err := b.reconstructBlock(cb)
+	assert.Nil(t, err)
+	assert.Equal(t, []int32{1}, missing)
+
+	_, err = b.processCompactBlock(peerA, cb)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), toA.blockTxRequests.Load())
+	assert.True(t, b.chain.HasBlock(types.HashBlock(block)))
+	assert.Equal(t, 0, b.mempool.Len())
+
+	_, err = b.processCompactBlock(peerA, cb)
+	assert.ErrorIs(t, err, ErrDuplicateBlock)
+}
+
+func TestCompactBlockGossip(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{ListenAddr: "a", PrivKey: crypto.NewPrivateKey()})
+		b = NewNode(ServerConfig{ListenAddr: "b"})
+	)
+	connect(a, b)
+	block, _ := twoTxBlock(t, a, b)
+
+	a.flushInventory()
+	assert.Eventually(t, func() bool { return b.chain.HasBlock(types.HashBlock(block)) }, time.Second, 10*time.Millisecond)
+}
+
+func TestCompactBlockInvalidPrefill(t *testing.T) {
+	n := NewNode(ServerConfig{})
+	tx := orphanTX(t, crypto.NewPrivateKey(), 0)
+
+	_, _, err := n.reconstructBlock(&proto.CompactBlock{
+		Prefilled: []*proto.PrefilledTransaction{{Index: 1, Transaction: tx}},
+	})
+	assert.ErrorIs(t, err, ErrInvalidCompactBlock)
+}
+
+func TestGetBlockTransactions(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{PrivKey: crypto.NewPrivateKey()})
+		b = NewNode(ServerConfig{})
+	)
+	block, unseen := twoTxBlock(t, a, b)
+
+	resp, err := a.getBlockTransactions(&proto.BlockTxRequest{BlockHash: types.HashBlock(block), Indexes: []int32{1}})
+	assert.Nil(t, err)
+	assert.Equal(t, hashOf(unseen), hashOf(resp.Transactions[0]))
+
+	_, err = a.getBlockTransactions(&proto.BlockTxRequest{BlockHash: types.HashBlock(block), Indexes: []int32{2}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = a.getBlockTransactions(&proto.BlockTxRequest{BlockHash: []byte("missing")})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// every index at most once, in order, no more than the block has
+	for _, indexes := range [][]int32{{1, 1}, {1, 0}, {0, 1, 1}, make([]int32, 1000)} {
+		_, err = a.getBlockTransactions(&proto.BlockTxRequest{BlockHash: types.HashBlock(block), Indexes: indexes})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+	resp, err = a.getBlockTransactions(&proto.BlockTxRequest{BlockHash: types.HashBlock(block), Indexes: []int32{0, 1}})
+	assert.Nil(t, err)
+	assert.Len(t, resp.Transactions, 2)
+}
@@ -0,0 +1,93 @@
+package node
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pdrm26/blocker/proto"
+	pb "google.golang.org/protobuf/proto"
+)
+
+const (
+	// medianTimeSpan is the number of recent blocks whose median timestamp a
+	// new block has to be above.
+	medianTimeSpan = 11
+	// maxFutureBlockTime is how far ahead of our clock a block may be.
+	maxFutureBlockTime = 2 * time.Hour
+
+	MaxBlockSize = 1 << 20
+	MaxBlockTxs  = 10_000
+	MaxTxSize    = 100 << 10
+)
+
+var (
+	knownBlockVersions = map[int32]bool{1: true}
+	knownTxVersions    = map[int32]bool{1: true}
+)
+
+// MedianTimePast returns the median timestamp of the last medianTimeSpan
+// blocks.
+func (c *Chain) MedianTimePast() int64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.medianTimePast()
+}
+
+func (c *Chain) medianTimePast() int64 {
+	timestamps := make([]int64, 0, medianTimeSpan)
+	for height := c.headers.Height(); height >= 0 && len(timestamps) < medianTimeSpan; height-- {
+		timestamps = append(timestamps, c.headers.Get(height).Timestamp)
+	}
+
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i] < timestamps[j]
+	})
+
+	return timestamps[len(timestamps)/2]
+}
+
+func (c *Chain) validateHeader(header *proto.Header) error {
+	if !knownBlockVersions[header.Version] {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, header.Version)
+	}
+
+	if int(header.Height) != c.headers.Height()+1 {
+		return fmt.Errorf("%w: have (%d) want (%d)", ErrInvalidHeight, header.Height, c.headers.Height()+1)
+	}
+
+	if mtp := c.medianTimePast(); header.Timestamp <= mtp {
+		return fmt.Errorf("%w: timestamp (%d) median (%d)", ErrTimestampTooOld, header.Timestamp, mtp)
+	}
+
+	if limit := time.Now().Add(maxFutureBlockTime).Unix(); header.Timestamp > limit {
+		return fmt.Errorf("%w: timestamp (%d) limit (%d)", ErrTimestampTooNew, header.Timestamp, limit)
+	}
+
+	return nil
+}
+
+func validateBlockSize(block *proto.Block) error {
+	if len(block.Transactions) > MaxBlockTxs {
+		return fmt.Errorf("%w: (%d) max (%d)", ErrTooManyTxs, len(block.Transactions), MaxBlockTxs)
+	}
+
+	if size := pb.Size(block); size > MaxBlockSize {
+		return fmt.Errorf("%w: (%d) bytes max (%d)", ErrBlockTooLarge, size, MaxBlockSize)
+	}
+
+	return nil
+}
+
+func validateTransactionFormat(tx *proto.Transaction) error {
+	if !knownTxVersions[tx.Version] {
+		return fmt.Errorf("%w: %d", ErrUnknownTxVersion, tx.Version)
+	}
+
+	if size := pb.Size(tx); size > MaxTxSize {
+		return fmt.Errorf("%w: (%d) bytes max (%d)", ErrTransactionTooLarge, size, MaxTxSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package node
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/pdrm26/blocker/crypto"
+	"github.com/pdrm26/blocker/proto"
+	"github.com/pdrm26/blocker/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateHeaderRules(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	for i := 0; i < 12; i++ {
+		assert.Nil(t, chain.AddBlock(randomBlock(t, chain)))
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*proto.Header)
+		err    error
+	}{
+		{"height skips ahead", func(h *proto.Header) { h.Height++ }, ErrInvalidHeight},
+		{"height repeats parent", func(h *proto.Header) { h.Height-- }, ErrInvalidHeight},
+		{"timestamp at median", func(h *proto.Header) { h.Timestamp = chain.MedianTimePast() }, ErrTimestampTooOld},
+		{"timestamp in the far future", func(h *proto.Header) { h.Timestamp = time.Now().Add(3 * time.Hour).Unix() }, ErrTimestampTooNew},
+		{"unknown version", func(h *proto.Header) { h.Version = 2 }, ErrUnknownVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := randomBlock(t, chain)
+			tt.modify(block.Header)
+			types.SignBlock(crypto.NewPrivateKey(), block)
+
+			assert.ErrorIs(t, chain.AddBlock(block), tt.err)
+		})
+	}
+}
+
+func TestMedianTimePast(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	assert.Equal(t, int64(0), chain.MedianTimePast())
+
+	for i := 0; i < 20; i++ {
+		block := randomBlock(t, chain)
+		block.Header.Timestamp = chain.MedianTimePast() + 10
+		types.SignBlock(crypto.NewPrivateKey(), block)
+		assert.Nil(t, chain.AddBlock(block))
+	}
+
+	// the median only looks at the last blocks
+	assert.Equal(t, chain.headers.Get(chain.Height()-medianTimeSpan/2).Timestamp, chain.MedianTimePast())
+}
+
+func TestValidateBlockSizeLimits(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+
+	txs := make([]*proto.Transaction, MaxBlockTxs+1)
+	for i := range txs {
+		txs[i] = &proto.Transaction{Version: 1}
+	}
+	block := randomBlock(t, chain, txs...)
+	assert.ErrorIs(t, chain.AddBlock(block), ErrTooManyTxs)
+
+	output := &proto.TxOutput{Amount: 1, Address: bytes.Repeat([]byte{1}, MaxTxSize)}
+	tx := &proto.Transaction{Version: 1, Outputs: []*proto.TxOutput{output}}
+	assert.ErrorIs(t, chain.ValidateTransaction(tx), ErrTransactionTooLarge)
+
+	big := make([]*proto.Transaction, 12)
+	for i := range big {
+		big[i] = &proto.Transaction{Version: 1, Outputs: []*proto.TxOutput{{Address: bytes.Repeat([]byte{byte(i)}, MaxTxSize-100)}}}
+	}
+	block = randomBlock(t, chain, big...)
+	assert.ErrorIs(t, chain.AddBlock(block), ErrBlockTooLarge)
+
+	tx = &proto.Transaction{Version: 2}
+	assert.ErrorIs(t, chain.ValidateTransaction(tx), ErrUnknownTxVersion)
+}
@@ -0,0 +1,148 @@
+package node
+
+import (
+	"errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain is the domain of the ErrorInfo attached to rejections sent
+// over gRPC.
+const errorDomain = "blocker"
+
+// Block validation errors.
+var (
+	ErrMissingHeader         = errors.New("block has no header")
+	ErrInvalidBlockSignature = errors.New("invalid block signature")
+	ErrInvalidPrevHash       = errors.New("invalid previous hash block")
+	ErrInvalidRootHash       = errors.New("invalid transaction root hash")
+	ErrInvalidStateRoot      = errors.New("invalid state root")
+	ErrInvalidHeight         = errors.New("invalid block height")
+	ErrTimestampTooOld       = errors.New("block timestamp is not above the median time past")
+	ErrTimestampTooNew       = errors.New("block timestamp is too far in the future")
+	ErrUnknownVersion        = errors.New("unknown block version")
+	ErrBlockTooLarge         = errors.New("block is too large")
+	ErrTooManyTxs            = errors.New("block has too many transactions")
+	ErrDuplicateBlock        = errors.New("block already known")
+	ErrInvalidCompactBlock   = errors.New("invalid compact block")
+)
+
+// Transaction validation errors.
+var (
+	ErrInvalidSignature    = errors.New("invalid tx signature")
+	ErrUnknownTxVersion    = errors.New("unknown transaction version")
+	ErrTransactionTooLarge = errors.New("transaction is too large")
+	ErrUnknownInput        = errors.New("input spends an unknown output")
+	ErrDoubleSpend         = errors.New("input spends an already spent output")
+	ErrInsufficientFunds   = errors.New("insufficient balance")
+	ErrInvalidAmount       = errors.New("invalid output amount")
+	ErrInputNotOwned       = errors.New("input is not signed by the owner of the output")
+	ErrDuplicateTx         = errors.New("transaction already known")
+	ErrNoInputs            = errors.New("transaction has no inputs")
+	ErrMempoolConflict     = errors.New("input is already spent by a mempool transaction")
+	ErrInsufficientFee     = errors.New("transaction fee rate is below the minimum relay fee rate")
+	ErrTooManyOrphans      = errors.New("too many orphan transactions from peer")
+	ErrMempoolFull         = errors.New("mempool is full")
+	ErrReplacementRejected = errors.New("replacement transaction rejected")
+)
+
+// Peer errors.
+var (
+	ErrPeerBanned          = errors.New("peer is banned")
+	ErrHandshakeFailed     = errors.New("peer handshake failed")
+	ErrTooManyPeers        = errors.New("too many peers")
+	ErrIncompatibleVersion = errors.New("no protocol version in common")
+	ErrRateLimited         = errors.New("rate limit exceeded")
+)
+
+type errorReason struct {
+	err    error
+	code   codes.Code
+	reason string
+}
+
+// errorReasons maps every error a client can be rejected with to a gRPC code
+// and a stable reason string.
+var errorReasons = []errorReason{
+	{ErrMissingHeader, codes.InvalidArgument, "MISSING_HEADER"},
+	{ErrInvalidBlockSignature, codes.InvalidArgument, "INVALID_BLOCK_SIGNATURE"},
+	{ErrInvalidPrevHash, codes.FailedPrecondition, "INVALID_PREV_HASH"},
+	{ErrInvalidRootHash, codes.InvalidArgument, "INVALID_ROOT_HASH"},
+	{ErrInvalidStateRoot, codes.InvalidArgument, "INVALID_STATE_ROOT"},
+	{ErrInvalidHeight, codes.FailedPrecondition, "INVALID_HEIGHT"},
+	{ErrTimestampTooOld, codes.InvalidArgument, "TIMESTAMP_TOO_OLD"},
+	{ErrTimestampTooNew, codes.InvalidArgument, "TIMESTAMP_TOO_NEW"},
+	{ErrUnknownVersion, codes.InvalidArgument, "UNKNOWN_VERSION"},
+	{ErrBlockTooLarge, codes.InvalidArgument, "BLOCK_TOO_LARGE"},
+	{ErrTooManyTxs, codes.InvalidArgument, "TOO_MANY_TXS"},
+	{ErrDuplicateBlock, codes.AlreadyExists, "DUPLICATE_BLOCK"},
+	{ErrInvalidCompactBlock, codes.InvalidArgument, "INVALID_COMPACT_BLOCK"},
+	{ErrInvalidSignature, codes.InvalidArgument, "INVALID_SIGNATURE"},
+	{ErrUnknownTxVersion, codes.InvalidArgument, "UNKNOWN_TX_VERSION"},
+	{ErrTransactionTooLarge, codes.InvalidArgument, "TX_TOO_LARGE"},
+	{ErrUnknownInput, codes.FailedPrecondition, "UNKNOWN_INPUT"},
+	{ErrDoubleSpend, codes.FailedPrecondition, "DOUBLE_SPEND"},
+	{ErrInsufficientFunds, codes.InvalidArgument, "INSUFFICIENT_FUNDS"},
+	{ErrInvalidAmount, codes.InvalidArgument, "INVALID_AMOUNT"},
+	{ErrInputNotOwned, codes.InvalidArgument, "INPUT_NOT_OWNED"},
+	{ErrDuplicateTx, codes.AlreadyExists, "DUPLICATE_TX"},
+	{ErrNoInputs, codes.InvalidArgument, "NO_INPUTS"},
+	{ErrMempoolConflict, codes.FailedPrecondition, "MEMPOOL_CONFLICT"},
+	{ErrInsufficientFee, codes.FailedPrecondition, "INSUFFICIENT_FEE"},
+	{ErrTooManyOrphans, codes.ResourceExhausted, "TOO_MANY_ORPHANS"},
+	{ErrMempoolFull, codes.ResourceExhausted, "MEMPOOL_FULL"},
+	{ErrReplacementRejected, codes.FailedPrecondition, "REPLACEMENT_REJECTED"},
+	{ErrPeerBanned, codes.PermissionDenied, "PEER_BANNED"},
+	{ErrHandshakeFailed, codes.Unauthenticated, "HANDSHAKE_FAILED"},
+	{ErrTooManyPeers, codes.ResourceExhausted, "TOO_MANY_PEERS"},
+	{ErrIncompatibleVersion, codes.FailedPrecondition, "INCOMPATIBLE_VERSION"},
+	{ErrRateLimited, codes.ResourceExhausted, "RATE_LIMITED"},
+}
+
+// toStatusError turns a validation error into a gRPC status error whose
+// details carry the stable reason, so clients can tell exactly why they were
+// rejected. Errors outside the catalog become Internal.
+func toStatusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	for _, r := range errorReasons {
+		if !errors.Is(err, r.err) {
+			continue
+		}
+
+		st, detailErr := status.New(r.code, err.Error()).WithDetails(&errdetails.ErrorInfo{
+			Reason: r.reason,
+			Domain: errorDomain,
+		})
+		if detailErr != nil {
+			return status.Error(r.code, err.Error())
+		}
+		return st.Err()
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
+
+// RejectReason returns the reason a node attached to a rejection, or an
+// empty string if the error carries none.
+func RejectReason(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == errorDomain {
+			return info.Reason
+		}
+	}
+
+	return ""
+}
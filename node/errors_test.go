@@ -0,0 +1,39 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatusError(t *testing.T) {
+	err := toStatusError(fmt.Errorf("%w: abc_0", ErrDoubleSpend))
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, "DOUBLE_SPEND", RejectReason(err))
+
+	err = toStatusError(ErrDuplicateTx)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	assert.Equal(t, "DUPLICATE_TX", RejectReason(err))
+
+	err = toStatusError(errors.New("disk on fire"))
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, "", RejectReason(err))
+
+	// status errors pass through untouched
+	notFound := status.Error(codes.NotFound, "missing")
+	assert.Equal(t, notFound, toStatusError(notFound))
+	assert.Nil(t, toStatusError(nil))
+}
+
+func TestEveryErrorHasAReason(t *testing.T) {
+	seen := map[string]bool{}
+	for _, r := range errorReasons {
+		assert.False(t, seen[r.reason], r.reason)
+		seen[r.reason] = true
+		assert.Equal(t, r.reason, RejectReason(toStatusError(r.err)))
+	}
+}
@@ -0,0 +1,113 @@
+package node
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pdrm26/blocker/crypto"
+	"github.com/pdrm26/blocker/proto"
+)
+
+const challengeSize = 32
+
+// Domains of the two signatures in a handshake, so neither can be replayed
+// as the other.
+const (
+	challengeDomain = "blocker challenge\n"
+	handshakeDomain = "blocker handshake\n"
+)
+
+// NodeID identifies a node by its identity key, hex encoded.
+type NodeID string
+
+func nodeID(pubKey *crypto.PublicKey) NodeID {
+	return NodeID(hex.EncodeToString(pubKey.Bytes()))
+}
+
+// ID returns the id of the node, derived from its identity key.
+func (n *Node) ID() NodeID {
+	return nodeID(n.IdentityKey.Public())
+}
+
+func challengeMessage(nonce []byte) []byte {
+	return append([]byte(challengeDomain), nonce...)
+}
+
+// handshakeMessage binds the listen address to the challenge, so a
+// handshake can't be replayed for another address.
+func handshakeMessage(challenge []byte, listenAddr string) []byte {
+	msg := append([]byte(handshakeDomain), challenge...)
+	return append(msg, listenAddr...)
+}
+
+// verifyIdentity checks that sig over msg was made with pubKey and returns
+// the id that belongs to the key.
+func verifyIdentity(pubKey, msg, sig []byte) (NodeID, error) {
+	key, err := crypto.PublicKeyFromBytes(pubKey)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrHandshakeFailed, err)
+	}
+	if len(sig) != crypto.SignatureLen || !key.Verify(msg, sig) {
+		return "", fmt.Errorf("%w: invalid signature", ErrHandshakeFailed)
+	}
+
+	return nodeID(key), nil
+}
+
+func newNonce() []byte {
+	nonce := make([]byte, challengeSize)
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	return nonce
+}
+
+// answerChallenge proves we hold our identity key by signing the nonce of a
+// node that dialed us, and hands it challenge to sign in its peer info.
+func (n *Node) answerChallenge(nonce, challenge []byte) (*proto.ChallengeResponse, error) {
+	if len(nonce) != challengeSize {
+		return nil, fmt.Errorf("%w: nonce length (%d) must be %d", ErrHandshakeFailed, len(nonce), challengeSize)
+	}
+
+	return &proto.ChallengeResponse{
+		PublicKey: n.IdentityKey.Public().Bytes(),
+		Signature: n.IdentityKey.Sign(challengeMessage(nonce)).Bytes(),
+		Challenge: challenge,
+	}, nil
+}
+
+// verifyHandshake checks that info answers the challenge we issued, signed
+// by the key it claims, and returns the id of the peer.
+func (n *Node) verifyHandshake(challenge []byte, info *proto.PeerInfo) (NodeID, error) {
+	if !bytes.Equal(info.Challenge, challenge) {
+		return "", fmt.Errorf("%w: wrong challenge", ErrHandshakeFailed)
+	}
+	id, err := verifyIdentity(info.PublicKey, handshakeMessage(info.Challenge, info.ListenAddr), info.Signature)
+	if err != nil {
+		return "", err
+	}
+	if id == n.ID() {
+		return "", fmt.Errorf("%w: connected to ourselves", ErrHandshakeFailed)
+	}
+
+	return id, nil
+}
+
+// signedPeerInfo is our peer info answering challenge.
+func (n *Node) signedPeerInfo(challenge []byte) *proto.PeerInfo {
+	info := n.getPeerInfo()
+	info.Challenge = challenge
+	info.Signature = n.IdentityKey.Sign(handshakeMessage(challenge, n.ListenAddr)).Bytes()
+	return info
+}
+
+// checkPeerKey makes sure the node that answered the handshake is the one
+// we challenged before.
+func checkPeerKey(id NodeID, info *proto.PeerInfo) error {
+	if id != NodeID(hex.EncodeToString(info.PublicKey)) {
+		return fmt.Errorf("%w: peer key changed during the handshake", ErrHandshakeFailed)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package node
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pdrm26/blocker/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+// signedHandshake returns a challenge and the peer info from answers it
+// with.
+func signedHandshake(from *Node) ([]byte, *proto.PeerInfo) {
+	challenge := newNonce()
+	return challenge, from.signedPeerInfo(challenge)
+}
+
+// freeAddr returns a local address nothing listens on.
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// startNode runs n on addr and waits until it accepts connections.
+func startNode(t *testing.T, n *Node, addr string, bootstrapNodes ...string) {
+	go n.Start(addr, bootstrapNodes)
+	assert.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestAnswerChallenge(t *testing.T) {
+	var (
+		n         = NewNode(ServerConfig{ListenAddr: "a"})
+		nonce     = newNonce()
+		challenge = newNonce()
+	)
+
+	resp, err := n.answerChallenge(nonce, challenge)
+	assert.Nil(t, err)
+	assert.Equal(t, challenge, resp.Challenge)
+	id, err := verifyIdentity(resp.PublicKey, challengeMessage(nonce), resp.Signature)
+	assert.Nil(t, err)
+	assert.Equal(t, n.ID(), id)
+
+	// the signature only answers the nonce it was made for
+	_, err = verifyIdentity(resp.PublicKey, challengeMessage(newNonce()), resp.Signature)
+	assert.ErrorIs(t, err, ErrHandshakeFailed)
+
+	_, err = n.answerChallenge([]byte("short"), challenge)
+	assert.ErrorIs(t, err, ErrHandshakeFailed)
+	assert.Equal(t, "HANDSHAKE_FAILED", RejectReason(toStatusError(err)))
+}
+
+func TestVerifyHandshake(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{ListenAddr: "a"})
+		b = NewNode(ServerConfig{ListenAddr: "b"})
+	)
+
+	challenge, info := signedHandshake(b)
+	id, err := a.verifyHandshake(challenge, info)
+	assert.Nil(t, err)
+	assert.Equal(t, b.ID(), id)
+
+	// only the challenge we issued counts
+	_, err = a.verifyHandshake(newNonce(), info)
+	assert.ErrorIs(t, err, ErrHandshakeFailed)
+
+	// nor for another address
+	challenge, info = signedHandshake(b)
+	info.ListenAddr = "c"
+	_, err = a.verifyHandshake(challenge, info)
+	assert.ErrorIs(t, err, ErrHandshakeFailed)
+
+	// nor with someone else's key
+	challenge, info = signedHandshake(b)
+	info.PublicKey = NewNode(ServerConfig{}).IdentityKey.Public().Bytes()
+	_, err = a.verifyHandshake(challenge, info)
+	assert.ErrorIs(t, err, ErrHandshakeFailed)
+
+	challenge, info = signedHandshake(a)
+	_, err = a.verifyHandshake(challenge, info)
+	assert.ErrorIs(t, err, ErrHandshakeFailed)
+}
+
+func TestHandshakeOverNetwork(t *testing.T) {
+	var (
+		a     = NewNode(ServerConfig{})
+		b     = NewNode(ServerConfig{})
+		addrA = freeAddr(t)
+		addrB = freeAddr(t)
+	)
+	startNode(t, a, addrA)
+	startNode(t, b, addrB, addrA)
+
+	assert.Eventually(t, connected(a, b), 5*time.Second, 10*time.Millisecond)
+
+	conn, _ := a.peerConn(b.ID())
+	assert.Equal(t, addrB, conn.info.ListenAddr)
+	assert.True(t, conn.inbound)
+}
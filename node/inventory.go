@@ -0,0 +1,424 @@
+package node
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pdrm26/blocker/proto"
+	"github.com/pdrm26/blocker/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "google.golang.org/protobuf/proto"
+)
+
+const (
+	// invInterval is how often queued announcements go out to the peers.
+	invInterval = 500 * time.Millisecond
+	// maxInvBatch is the most items a single inventory or getdata message
+	// may carry.
+	maxInvBatch = 1000
+	// maxDataSize is how many bytes of txs and blocks we send in answer to
+	// a single getdata, well below the 4MB a gRPC message may carry. What
+	// doesn't fit is answered as not found and can be asked for again.
+	maxDataSize = 2 * MaxBlockSize
+	// maxKnownInv is how many hashes we remember per peer as known to it.
+	maxKnownInv = 5000
+	// getDataTimeout is how long a requested item counts as in flight
+	// before another peer may be asked for it.
+	getDataTimeout = 30 * time.Second
+)
+
+// inventorySet is a set of hashes that forgets the oldest ones once it
+// holds max of them.
+type inventorySet struct {
+	items map[string]struct{}
+	order []string
+	max   int
+}
+
+func newInventorySet(max int) *inventorySet {
+	return &inventorySet{
+		items: make(map[string]struct{}),
+		max:   max,
+	}
+}
+
+func (s *inventorySet) Add(hash string) {
+	if _, ok := s.items[hash]; ok {
+		return
+	}
+	s.items[hash] = struct{}{}
+	s.order = append(s.order, hash)
+
+	if len(s.order) > s.max {
+		delete(s.items, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+func (s *inventorySet) Has(hash string) bool {
+	_, ok := s.items[hash]
+	return ok
+}
+
+// inventory keeps track of what we announced and requested, and of what
+// every peer, by node id, is known to have.
+type inventory struct {
+	lock      sync.Mutex
+	known     map[NodeID]*inventorySet
+	pending   []*proto.InvItem
+	requested map[string]time.Time
+}
+
+func newInventory() *inventory {
+	return &inventory{
+		known:     make(map[NodeID]*inventorySet),
+		requested: make(map[string]time.Time),
+	}
+}
+
+// announce queues item for the next batch of announcements.
+func (inv *inventory) announce(item *proto.InvItem) {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+
+	inv.pending = append(inv.pending, item)
+}
+
+func (inv *inventory) takePending() []*proto.InvItem {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+
+	items := inv.pending
+	inv.pending = nil
+	return items
+}
+
+func (inv *inventory) knows(peer NodeID, hash string) bool {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+
+	known, ok := inv.known[peer]
+	return ok && known.Has(hash)
+}
+
+func (inv *inventory) markKnown(peer NodeID, hash string) {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+
+	inv.markKnownLocked(peer, hash)
+}
+
+func (inv *inventory) markKnownLocked(peer NodeID, hash string) {
+	known, ok := inv.known[peer]
+	if !ok {
+		known = newInventorySet(maxKnownInv)
+		inv.known[peer] = known
+	}
+	known.Add(hash)
+}
+
+// unknownTo returns the items peer does not know about yet, and marks them
+// as known since they are about to be sent.
+func (inv *inventory) unknownTo(peer NodeID, items []*proto.InvItem) []*proto.InvItem {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+
+	var unknown []*proto.InvItem
+	for _, item := range items {
+		hash := hex.EncodeToString(item.Hash)
+		if known, ok := inv.known[peer]; ok && known.Has(hash) {
+			continue
+		}
+		inv.markKnownLocked(peer, hash)
+		unknown = append(unknown, item)
+	}
+
+	return unknown
+}
+
+// request marks hash as in flight and reports whether it should be fetched,
+// that is when nobody was asked for it recently.
+func (inv *inventory) request(hash string) bool {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+
+	now := time.Now()
+	if requested, ok := inv.requested[hash]; ok && now.Sub(requested) < getDataTimeout {
+		return false
+	}
+	inv.requested[hash] = now
+	return true
+}
+
+// done clears the in-flight mark of hash.
+func (inv *inventory) done(hash string) {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+
+	delete(inv.requested, hash)
+}
+
+func (inv *inventory) forget(peer NodeID) {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+
+	delete(inv.known, peer)
+}
+
+// relay announces an accepted tx or block to the peers with the next batch
+// of inventory.
+func (n *Node) relay(msg any) {
+	switch v := msg.(type) {
+	case *proto.Transaction:
+		n.inv.announce(&proto.InvItem{Type: proto.InvType_INV_TX, Hash: types.HashTransaction(v)})
+	case *proto.Block:
+		n.inv.announce(&proto.InvItem{Type: proto.InvType_INV_BLOCK, Hash: types.HashBlock(v)})
+	}
+}
+
+func (n *Node) announceLoop() {
+	ticker := time.NewTicker(invInterval)
+
+	for {
+		<-ticker.C
+		n.flushInventory()
+	}
+}
+
+// flushInventory queues the pending announcements for every peer that does
+// not know about them yet.
+func (n *Node) flushInventory() {
+	items := n.inv.takePending()
+	if len(items) == 0 {
+		return
+	}
+
+	for _, peer := range n.peerSnapshot() {
+		unknown := n.inv.unknownTo(peer.id, items)
+		for len(unknown) > 0 {
+			batch := unknown[:min(len(unknown), maxInvBatch)]
+			unknown = unknown[len(batch):]
+
+			msg := &proto.InvMessage{Items: batch}
+			if !peer.send(&proto.Envelope{Payload: &proto.Envelope_Inv{Inv: msg}}) {
+				n.logger.Debugw("peer queue full, dropped inventory", "remote", peer.addr, "items", len(batch))
+			}
+		}
+	}
+}
+
+// inventory handles an announcement from p and fetches whatever we don't
+// have from it.
+func (n *Node) inventory(p *peerConn, req *proto.InvMessage) error {
+	if len(req.Items) > maxInvBatch {
+		n.misbehaving(p.addr, misbehaviorProtocol)
+		return status.Errorf(codes.InvalidArgument, "inventory of (%d) items max (%d)", len(req.Items), maxInvBatch)
+	}
+
+	var wanted []*proto.InvItem
+	for _, item := range req.Items {
+		hash := hex.EncodeToString(item.Hash)
+		n.inv.markKnown(p.id, hash)
+		if item.Type == proto.InvType_INV_BLOCK && !p.services.servesBlocks() {
+			continue
+		}
+		if n.haveInventory(item) || !n.inv.request(hash) {
+			continue
+		}
+		// most of the block's txs are likely in our mempool already
+		if item.Type == proto.InvType_INV_BLOCK && p.version >= versionCompactBlocks {
+			item = &proto.InvItem{Type: proto.InvType_INV_COMPACT_BLOCK, Hash: item.Hash}
+		}
+		wanted = append(wanted, item)
+	}
+
+	if len(wanted) > 0 {
+		go n.fetchData(p, wanted)
+	}
+
+	return nil
+}
+
+// getData returns the txs and blocks p asked for, as many as fit in
+// maxDataSize. Asking for more items than maxInvBatch, or for the same one
+// twice, counts against p.
+func (n *Node) getData(p *peerConn, req *proto.InvMessage) (*proto.DataMessage, error) {
+	if len(req.Items) > maxInvBatch {
+		n.misbehaving(p.addr, misbehaviorProtocol)
+		return nil, status.Errorf(codes.InvalidArgument, "getdata of (%d) items max (%d)", len(req.Items), maxInvBatch)
+	}
+
+	// compact blocks are only for peers that agreed on a version with them
+	compact := p.version >= versionCompactBlocks
+
+	var (
+		data = &proto.DataMessage{}
+		seen = make(map[string]bool, len(req.Items))
+		size int
+	)
+	for _, item := range req.Items {
+		hash := hex.EncodeToString(item.Hash)
+		if seen[hash] {
+			n.misbehaving(p.addr, misbehaviorSpam)
+			return nil, status.Errorf(codes.InvalidArgument, "getdata asks for %s twice", hash)
+		}
+		seen[hash] = true
+		if size >= maxDataSize {
+			data.NotFound = append(data.NotFound, item)
+			continue
+		}
+
+		switch item.Type {
+		case proto.InvType_INV_TX:
+			if tx, ok := n.mempool.Get(hash); ok {
+				data.Transactions = append(data.Transactions, tx)
+				size += pb.Size(tx)
+				n.inv.markKnown(p.id, hash)
+				continue
+			}
+		case proto.InvType_INV_BLOCK, proto.InvType_INV_COMPACT_BLOCK:
+			if item.Type == proto.InvType_INV_COMPACT_BLOCK && !compact {
+				break
+			}
+			if !n.chain.HasBlock(item.Hash) {
+				break
+			}
+			block, err := n.chain.GetBlockByHash(item.Hash)
+			if err != nil {
+				break
+			}
+			if item.Type == proto.InvType_INV_BLOCK {
+				data.Blocks = append(data.Blocks, block)
+				size += pb.Size(block)
+			} else {
+				// send in full only the txs the peer is not known to have
+				cb := newCompactBlock(block, func(hash TXHash) bool {
+					return !n.inv.knows(p.id, hash)
+				})
+				data.CompactBlocks = append(data.CompactBlocks, cb)
+				size += pb.Size(cb)
+			}
+			n.inv.markKnown(p.id, hash)
+			continue
+		}
+		data.NotFound = append(data.NotFound, item)
+	}
+
+	return data, nil
+}
+
+// fetchData asks p for items and processes what it sends. Anything we did
+// not ask for is ignored.
+func (n *Node) fetchData(p *peerConn, items []*proto.InvItem) {
+	addr := p.addr
+	wanted := make(map[string]bool)
+	for _, item := range items {
+		wanted[hex.EncodeToString(item.Hash)] = true
+	}
+	defer func() {
+		for hash := range wanted {
+			n.inv.done(hash)
+		}
+	}()
+
+	data, err := n.requestData(p, items)
+	if err != nil {
+		n.logger.Errorw("getdata error", "remote", addr, "error", err)
+		return
+	}
+
+	// blocks first, they may clear txs that would now conflict
+	for _, block := range data.Blocks {
+		if block.Header == nil || !wanted[hex.EncodeToString(types.HashBlock(block))] {
+			continue
+		}
+		n.blockReceived(block, addr, n.processBlock(block))
+	}
+	for _, cb := range data.CompactBlocks {
+		if cb.Header == nil || !wanted[hex.EncodeToString(types.HashHeader(cb.Header))] {
+			continue
+		}
+		block, err := n.processCompactBlock(p, cb)
+		n.blockReceived(block, addr, err)
+	}
+
+	for _, tx := range data.Transactions {
+		if !wanted[hex.EncodeToString(types.HashTransaction(tx))] {
+			continue
+		}
+		n.txReceived(tx, addr)
+	}
+}
+
+// requestData asks p for items.
+func (n *Node) requestData(p *peerConn, items []*proto.InvItem) (*proto.DataMessage, error) {
+	resp, err := n.peerRequest(p, &proto.Envelope{Payload: &proto.Envelope_DataRequest{
+		DataRequest: &proto.InvMessage{Items: items},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	data := resp.GetData()
+	if data == nil {
+		n.misbehaving(p.addr, misbehaviorProtocol)
+		return nil, fmt.Errorf("peer answered getdata with %T", resp.Payload)
+	}
+
+	return data, nil
+}
+
+// txReceived processes a tx from the peer at addr. Invalid txs count
+// against the peer.
+func (n *Node) txReceived(tx *proto.Transaction, addr string) {
+	err := n.processTransaction(tx, addr)
+	if err == nil || errors.Is(err, ErrDuplicateTx) {
+		return
+	}
+
+	n.logger.Debugw("rejected tx", "from", addr, "error", err, "we", n.ListenAddr)
+	if m, ok := misbehaviorFor(err, false); ok {
+		n.misbehaving(addr, m)
+	}
+}
+
+// blockReceived logs the outcome of processing a block from the peer at
+// addr, and relays the block if it was added. Invalid blocks count against
+// the peer.
+func (n *Node) blockReceived(block *proto.Block, addr string, err error) {
+	if err != nil {
+		if !errors.Is(err, ErrDuplicateBlock) {
+			n.logger.Debugw("rejected block", "from", addr, "error", err, "we", n.ListenAddr)
+		}
+		if m, ok := misbehaviorFor(err, true); ok {
+			n.misbehaving(addr, m)
+		}
+		return
+	}
+
+	n.logger.Infow("received block", "from", addr, "height", block.Header.Height, "txLen", len(block.Transactions), "we", n.ListenAddr)
+	n.relay(block)
+}
+
+// haveInventory reports whether we already have the announced item.
+func (n *Node) haveInventory(item *proto.InvItem) bool {
+	hash := hex.EncodeToString(item.Hash)
+	switch item.Type {
+	case proto.InvType_INV_TX:
+		if _, ok := n.mempool.Get(hash); ok {
+			return true
+		}
+		if _, _, err := n.chain.GetTransaction(hash); err == nil {
+			return true
+		}
+		return n.orphans.Has(hash)
+	case proto.InvType_INV_BLOCK:
+		return n.chain.HasBlock(item.Hash)
+	}
+
+	// nothing to fetch for types we don't know
+	return true
+}
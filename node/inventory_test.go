@@ -0,0 +1,214 @@
+package node
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pdrm26/blocker/crypto"
+	"github.com/pdrm26/blocker/proto"
+	"github.com/pdrm26/blocker/types"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "google.golang.org/protobuf/proto"
+)
+
+// fakeStream is one end of an in-memory peer stream, as if the envelopes
+// went over the network. It counts some of the requests sent through it.
+type fakeStream struct {
+	ctx             context.Context
+	cancel          context.CancelFunc
+	in              <-chan *proto.Envelope
+	out             chan<- *proto.Envelope
+	invs            atomic.Int32
+	blockTxRequests atomic.Int32
+}
+
+// newPipe returns the two ends of a stream. Closing either ends both.
+func newPipe() (*fakeStream, *fakeStream) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ab := make(chan *proto.Envelope, peerQueueSize)
+	ba := make(chan *proto.Envelope, peerQueueSize)
+	return &fakeStream{ctx: ctx, cancel: cancel, in: ba, out: ab},
+		&fakeStream{ctx: ctx, cancel: cancel, in: ab, out: ba}
+}
+
+func (s *fakeStream) Send(env *proto.Envelope) error {
+	switch env.Payload.(type) {
+	case *proto.Envelope_Inv:
+		s.invs.Add(1)
+	case *proto.Envelope_BlockTxsRequest:
+		s.blockTxRequests.Add(1)
+	}
+
+	select {
+	case s.out <- env:
+		return nil
+	case <-s.ctx.Done():
+		return io.EOF
+	}
+}
+
+func (s *fakeStream) Recv() (*proto.Envelope, error) {
+	select {
+	case env := <-s.in:
+		return env, nil
+	case <-s.ctx.Done():
+		return nil, io.EOF
+	}
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+// addStream adds the peer with info to n over stream.
+func addStream(n *Node, id NodeID, stream peerStream, info *proto.PeerInfo) *peerConn {
+	p := n.newPeerConn(id, stream, info)
+	if s, ok := stream.(*fakeStream); ok {
+		p.onClose = s.cancel
+	}
+	n.addPeer(p)
+	return p
+}
+
+// connect makes a and b peers of each other over a pipe, and returns the
+// end a sends to b on and the end b sends to a on.
+func connect(a, b *Node) (*fakeStream, *fakeStream) {
+	toB, toA := newPipe()
+	addStream(a, b.ID(), toB, b.getPeerInfo())
+	addStream(b, a.ID(), toA, a.getPeerInfo())
+	return toB, toA
+}
+
+func TestInventorySetForgetsOldest(t *testing.T) {
+	set := newInventorySet(2)
+	set.Add("a")
+	set.Add("b")
+	set.Add("a")
+	set.Add("c")
+
+	assert.False(t, set.Has("a"))
+	assert.True(t, set.Has("b"))
+	assert.True(t, set.Has("c"))
+}
+
+func TestInventoryRequestInFlight(t *testing.T) {
+	inv := newInventory()
+
+	assert.True(t, inv.request("hash"))
+	assert.False(t, inv.request("hash"))
+
+	inv.requested["hash"] = time.Now().Add(-getDataTimeout)
+	assert.True(t, inv.request("hash"))
+
+	inv.done("hash")
+	assert.True(t, inv.request("hash"))
+}
+
+func TestTransactionGossip(t *testing.T) {
+	var (
+		a       = NewNode(ServerConfig{ListenAddr: "a"})
+		b       = NewNode(ServerConfig{ListenAddr: "b"})
+		privKey = genesisPrivKey(t)
+		tx      = spendOutput(t, privKey, genesisTX(t, a.chain), 0, 990)
+	)
+	toB, toA := connect(a, b)
+
+	assert.Nil(t, a.processTransaction(tx, "client"))
+	assert.False(t, b.mempool.Has(tx))
+
+	a.flushInventory()
+	assert.Eventually(t, func() bool { return b.mempool.Has(tx) }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), toB.invs.Load())
+
+	// b got it from a, so it announces it to nobody
+	b.flushInventory()
+	assert.Equal(t, int32(0), toA.invs.Load())
+
+	// and a doesn't announce the same tx to b twice
+	a.relay(tx)
+	a.flushInventory()
+	assert.Equal(t, int32(1), toB.invs.Load())
+}
+
+func TestBlockGossip(t *testing.T) {
+	var (
+		a       = NewNode(ServerConfig{ListenAddr: "a", PrivKey: crypto.NewPrivateKey()})
+		b       = NewNode(ServerConfig{ListenAddr: "b"})
+		privKey = genesisPrivKey(t)
+		tx      = spendOutput(t, privKey, genesisTX(t, a.chain), 0, 990)
+	)
+	connect(a, b)
+
+	assert.Nil(t, a.processTransaction(tx, "client"))
+	a.flushInventory()
+	assert.Eventually(t, func() bool { return b.mempool.Has(tx) }, time.Second, 10*time.Millisecond)
+
+	block, err := a.createBlock()
+	assert.Nil(t, err)
+	a.flushInventory()
+
+	assert.Eventually(t, func() bool { return b.chain.Height() == 1 }, time.Second, 10*time.Millisecond)
+	assert.True(t, b.chain.HasBlock(types.HashBlock(block)))
+	assert.Equal(t, 0, b.mempool.Len())
+}
+
+func TestGetDataNotFound(t *testing.T) {
+	var (
+		n = NewNode(ServerConfig{ListenAddr: "a"})
+		p = n.newPeerConn("b", nil, &proto.PeerInfo{ListenAddr: "b"})
+	)
+	items := []*proto.InvItem{
+		{Type: proto.InvType_INV_TX, Hash: []byte("missing tx")},
+		{Type: proto.InvType_INV_BLOCK, Hash: []byte("missing block")},
+	}
+
+	data, err := n.getData(p, &proto.InvMessage{Items: items})
+	assert.Nil(t, err)
+	assert.Empty(t, data.Transactions)
+	assert.Empty(t, data.Blocks)
+	assert.Equal(t, 2, len(data.NotFound))
+}
+
+func TestGetDataStopsAtSizeBudget(t *testing.T) {
+	var (
+		n     = NewNode(ServerConfig{ListenAddr: "a"})
+		p     = n.newPeerConn("b", nil, &proto.PeerInfo{ListenAddr: "b"})
+		items []*proto.InvItem
+	)
+	for i := 0; i < 2*maxDataSize/MaxTxSize; i++ {
+		tx := &proto.Transaction{
+			Version: 1,
+			Outputs: []*proto.TxOutput{{Amount: int64(i), Address: make([]byte, MaxTxSize)}},
+		}
+		// got in without admission checks
+		assert.Nil(t, n.mempool.Add(tx, 1<<40))
+		items = append(items, &proto.InvItem{Type: proto.InvType_INV_TX, Hash: types.HashTransaction(tx)})
+	}
+
+	data, err := n.getData(p, &proto.InvMessage{Items: items})
+	assert.Nil(t, err)
+	assert.Equal(t, maxDataSize/MaxTxSize+1, len(data.Transactions))
+	assert.Equal(t, len(items), len(data.Transactions)+len(data.NotFound))
+	assert.Less(t, pb.Size(data), maxDataSize+MaxTxSize+1024)
+}
+
+func TestGetDataRejectsDuplicates(t *testing.T) {
+	var (
+		n    = NewNode(ServerConfig{ListenAddr: "a"})
+		p    = n.newPeerConn("b", nil, &proto.PeerInfo{ListenAddr: "b"})
+		item = &proto.InvItem{Type: proto.InvType_INV_TX, Hash: []byte("hash")}
+	)
+
+	_, err := n.getData(p, &proto.InvMessage{Items: []*proto.InvItem{item, item}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, misbehaviorSpam.score, n.bans.Score("b"))
+
+	_, err = n.getData(p, &proto.InvMessage{Items: make([]*proto.InvItem, maxInvBatch+1)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, misbehaviorSpam.score+misbehaviorProtocol.score, n.bans.Score("b"))
+}
@@ -0,0 +1,775 @@
+package node
+
+import (
+	"cmp"
+	"container/heap"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pdrm26/blocker/proto"
+	"github.com/pdrm26/blocker/types"
+	pb "google.golang.org/protobuf/proto"
+)
+
+// minRelayFeeRate is the lowest fee per 1000 bytes a transaction must pay to
+// be admitted to the mempool and relayed while the mempool is not under
+// pressure.
+const minRelayFeeRate = 1
+
+// maxReplacements is the most txs a single replacement may evict from the
+// mempool, descendants included.
+const maxReplacements = 100
+
+const (
+	DefaultMempoolMaxTxs  = 5_000
+	DefaultMempoolMaxSize = 32 << 20
+	DefaultMempoolExpiry  = 72 * time.Hour
+)
+
+// MempoolConfig bounds the mempool. Zero fields take the defaults.
+type MempoolConfig struct {
+	MaxTxs  int
+	MaxSize int
+	Expiry  time.Duration
+}
+
+func (cfg MempoolConfig) withDefaults() MempoolConfig {
+	if cfg.MaxTxs <= 0 {
+		cfg.MaxTxs = DefaultMempoolMaxTxs
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultMempoolMaxSize
+	}
+	if cfg.Expiry <= 0 {
+		cfg.Expiry = DefaultMempoolExpiry
+	}
+	return cfg
+}
+
+// feeRate returns the fee paid per 1000 bytes.
+func feeRate(fee int64, size int) int64 {
+	if size <= 0 {
+		return 0
+	}
+	return fee * 1000 / int64(size)
+}
+
+type MempoolEntry struct {
+	Tx    *proto.Transaction
+	Hash  TXHash
+	Fee   int64
+	Size  int
+	Added time.Time
+
+	// seq orders entries by arrival, so parents always come before the
+	// children spending them.
+	seq uint64
+	// parents and children link the entry to the mempool txs it spends and
+	// that spend it.
+	parents  map[TXHash]*MempoolEntry
+	children map[TXHash]*MempoolEntry
+
+	// descFee and descSize add up the entry and all its descendants, the
+	// package that leaves the mempool when the entry is evicted.
+	descFee  int64
+	descSize int
+	// evictIndex is the position of the entry in the eviction queue.
+	evictIndex int
+	// ancFee, ancSize and ancCount add up the entry and all its ancestors,
+	// the package that has to be mined for the entry to be.
+	ancFee   int64
+	ancSize  int
+	ancCount int
+}
+
+func (e *MempoolEntry) FeeRate() int64 {
+	return feeRate(e.Fee, e.Size)
+}
+
+// evictionQueue orders entries by the fee rate of their descendant package,
+// lowest first, so the package that pays the least per byte is evicted first.
+// Among equal rates the latest arrival goes first.
+type evictionQueue []*MempoolEntry
+
+func (q evictionQueue) Len() int { return len(q) }
+
+func (q evictionQueue) Less(i, j int) bool {
+	// compare descFee/descSize without rounding
+	lhs, rhs := q[i].descFee*int64(q[j].descSize), q[j].descFee*int64(q[i].descSize)
+	if lhs != rhs {
+		return lhs < rhs
+	}
+	return q[i].seq > q[j].seq
+}
+
+func (q evictionQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].evictIndex = i
+	q[j].evictIndex = j
+}
+
+func (q *evictionQueue) Push(x any) {
+	entry := x.(*MempoolEntry)
+	entry.evictIndex = len(*q)
+	*q = append(*q, entry)
+}
+
+func (q *evictionQueue) Pop() any {
+	old := *q
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	entry.evictIndex = -1
+	return entry
+}
+
+// selectCandidate is an entry waiting to be picked for a block, with the
+// fee, size and count of its ancestor package that is not picked yet.
+type selectCandidate struct {
+	entry *MempoolEntry
+	fee   int64
+	size  int
+	count int
+	index int
+}
+
+// selectionQueue orders candidates by the fee rate of their ancestor
+// package, highest first. Among equal rates the earliest arrival goes first.
+type selectionQueue []*selectCandidate
+
+func (q selectionQueue) Len() int { return len(q) }
+
+func (q selectionQueue) Less(i, j int) bool {
+	// compare fee/size without rounding
+	lhs, rhs := q[i].fee*int64(q[j].size), q[j].fee*int64(q[i].size)
+	if lhs != rhs {
+		return lhs > rhs
+	}
+	return q[i].entry.seq < q[j].entry.seq
+}
+
+func (q selectionQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *selectionQueue) Push(x any) {
+	c := x.(*selectCandidate)
+	c.index = len(*q)
+	*q = append(*q, c)
+}
+
+func (q *selectionQueue) Pop() any {
+	old := *q
+	c := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	c.index = -1
+	return c
+}
+
+type Mempool struct {
+	lock sync.RWMutex
+	cfg  MempoolConfig
+	txx  map[TXHash]*MempoolEntry
+	// spends maps every outpoint spent by a mempool tx to that tx.
+	spends map[string]TXHash
+	size   int
+	seq    uint64
+	evict  evictionQueue
+
+	subs    map[int]chan MempoolEvent
+	nextSub int
+}
+
+func NewMempool() *Mempool {
+	return NewMempoolWithConfig(MempoolConfig{})
+}
+
+func NewMempoolWithConfig(cfg MempoolConfig) *Mempool {
+	return &Mempool{
+		cfg:    cfg.withDefaults(),
+		txx:    make(map[TXHash]*MempoolEntry),
+		spends: make(map[string]TXHash),
+		subs:   make(map[int]chan MempoolEvent),
+	}
+}
+
+// Clear empties the mempool and returns its transactions in arrival order.
+func (pool *Mempool) Clear() []*proto.Transaction {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	entries := make([]*MempoolEntry, 0, len(pool.txx))
+	for _, entry := range pool.txx {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seq < entries[j].seq
+	})
+
+	txs := make([]*proto.Transaction, len(entries))
+	for i, entry := range entries {
+		txs[i] = entry.Tx
+	}
+
+	pool.txx = make(map[TXHash]*MempoolEntry)
+	pool.spends = make(map[string]TXHash)
+	pool.size = 0
+	pool.evict = nil
+	for _, entry := range entries {
+		pool.notify(MempoolEvent{Entry: entry, Removed: true, Reason: RemovedReset})
+	}
+	return txs
+}
+
+func (pool *Mempool) Len() int {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	return len(pool.txx)
+}
+
+// Size returns the total size in bytes of the mempool txs.
+func (pool *Mempool) Size() int {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	return pool.size
+}
+
+func (pool *Mempool) Has(tx *proto.Transaction) bool {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	hash := hex.EncodeToString(types.HashTransaction(tx))
+	_, ok := pool.txx[hash]
+	return ok
+}
+
+func (pool *Mempool) Get(hash TXHash) (*proto.Transaction, bool) {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	entry, ok := pool.txx[hash]
+	if !ok {
+		return nil, false
+	}
+	return entry.Tx, true
+}
+
+// SpentBy returns the mempool tx spending the outpoint with the given key.
+func (pool *Mempool) SpentBy(key string) (TXHash, bool) {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	hash, ok := pool.spends[key]
+	return hash, ok
+}
+
+// Output returns an output created by a mempool tx.
+func (pool *Mempool) Output(hash TXHash, outIndex int) (*UTXO, bool) {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	entry, ok := pool.txx[hash]
+	if !ok || outIndex < 0 || outIndex >= len(entry.Tx.Outputs) {
+		return nil, false
+	}
+
+	output := entry.Tx.Outputs[outIndex]
+	return &UTXO{
+		Hash:     hash,
+		OutIndex: outIndex,
+		Amount:   output.Amount,
+		Address:  output.Address,
+	}, true
+}
+
+// MinFeeRate returns the fee rate a tx must pay to get in right now. It is
+// minRelayFeeRate until the mempool is half full, and doubles with every
+// further tenth of the capacity used.
+func (pool *Mempool) MinFeeRate() int64 {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	return pool.minFeeRate()
+}
+
+func (pool *Mempool) minFeeRate() int64 {
+	usage := max(
+		len(pool.txx)*100/pool.cfg.MaxTxs,
+		pool.size*100/pool.cfg.MaxSize,
+	)
+	if usage < 50 {
+		return minRelayFeeRate
+	}
+
+	return minRelayFeeRate << ((usage-50)/10 + 1)
+}
+
+// Add puts a validated tx paying fee into the mempool. It fails if the tx is
+// already there or spends an output another mempool tx spends. When the
+// mempool is full, the txs whose packages with their descendants pay the
+// lowest fee rate are evicted to make room, as long as they pay less than tx.
+func (pool *Mempool) Add(tx *proto.Transaction, fee int64) error {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	entry := newMempoolEntry(tx, fee)
+	if _, ok := pool.txx[entry.Hash]; ok {
+		return ErrDuplicateTx
+	}
+
+	for _, input := range tx.Inputs {
+		key := utxoKey(hex.EncodeToString(input.PrevTxHash), int(input.PrevOutIndex))
+		if spender, ok := pool.spends[key]; ok {
+			return fmt.Errorf("%w: %s spent by %s", ErrMempoolConflict, key, spender)
+		}
+	}
+
+	return pool.insert(entry, nil)
+}
+
+// Remove drops the given txs, which made it into a block, from the mempool.
+// Their mempool children stay, they now spend confirmed outputs. Mempool txs
+// spending the same outputs as the block txs can never confirm anymore and
+// are dropped together with their descendants.
+func (pool *Mempool) Remove(txs []*proto.Transaction) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	for _, tx := range txs {
+		if entry, ok := pool.txx[hex.EncodeToString(types.HashTransaction(tx))]; ok {
+			pool.remove(entry)
+			pool.notify(MempoolEvent{Entry: entry, Removed: true, Reason: RemovedMined})
+		}
+	}
+
+	for _, tx := range txs {
+		for _, input := range tx.Inputs {
+			key := utxoKey(hex.EncodeToString(input.PrevTxHash), int(input.PrevOutIndex))
+			spender, ok := pool.spends[key]
+			if !ok {
+				continue
+			}
+			for _, e := range pool.removeAll(pool.withDescendants(pool.txx[spender])) {
+				pool.notify(MempoolEvent{Entry: e, Removed: true, Reason: RemovedConflict})
+			}
+		}
+	}
+}
+
+// SelectTransactions picks txs for a block of at most maxSize bytes and maxTxs
+// txs. Txs are taken as packages of a tx and its not yet selected ancestors,
+// highest package fee rate first, so a child paying a high fee pulls in its
+// low-fee parent. Parents always come before their children.
+func (pool *Mempool) SelectTransactions(maxSize, maxTxs int) []*proto.Transaction {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	var (
+		txs        []*proto.Transaction
+		size       int
+		queue      = make(selectionQueue, 0, len(pool.txx))
+		candidates = make(map[TXHash]*selectCandidate, len(pool.txx))
+		selected   = make(map[TXHash]bool)
+	)
+	for hash, entry := range pool.txx {
+		c := &selectCandidate{entry: entry, fee: entry.ancFee, size: entry.ancSize, count: entry.ancCount, index: len(queue)}
+		candidates[hash] = c
+		queue = append(queue, c)
+	}
+	heap.Init(&queue)
+
+	for queue.Len() > 0 {
+		best := heap.Pop(&queue).(*selectCandidate)
+		if len(txs)+best.count > maxTxs || size+best.size > maxSize {
+			// the package does not fit, smaller ones still might
+			continue
+		}
+
+		pkg := []*MempoolEntry{best.entry}
+		for _, ancestor := range pool.ancestors(best.entry) {
+			if !selected[ancestor.Hash] {
+				pkg = append(pkg, ancestor)
+			}
+		}
+		sort.Slice(pkg, func(i, j int) bool {
+			return pkg[i].seq < pkg[j].seq
+		})
+		for _, e := range pkg {
+			selected[e.Hash] = true
+			txs = append(txs, e.Tx)
+			if c := candidates[e.Hash]; c.index >= 0 {
+				heap.Remove(&queue, c.index)
+			}
+		}
+		size += best.size
+
+		// what was picked no longer weighs on the packages depending on it
+		for _, e := range pkg {
+			for _, d := range pool.withDescendants(e)[1:] {
+				if selected[d.Hash] {
+					continue
+				}
+				c := candidates[d.Hash]
+				c.fee -= e.Fee
+				c.size -= e.Size
+				c.count--
+				if c.index >= 0 {
+					heap.Fix(&queue, c.index)
+				}
+			}
+		}
+	}
+
+	return txs
+}
+
+// Replace puts a validated tx paying fee into the mempool in place of the
+// txs spending any of the same outputs, and of their descendants. It returns
+// the hashes of the replaced txs. The replacement must
+//   - pay a strictly higher fee rate than every tx it directly conflicts with,
+//   - pay a strictly higher absolute fee than all replaced txs together,
+//   - pay at least minRelayFeeRate on top of that for its own relay,
+//   - not replace more than maxReplacements txs, and
+//   - not spend outputs of the txs it replaces.
+func (pool *Mempool) Replace(tx *proto.Transaction, fee int64) ([]TXHash, error) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	entry := newMempoolEntry(tx, fee)
+	if _, ok := pool.txx[entry.Hash]; ok {
+		return nil, ErrDuplicateTx
+	}
+
+	var (
+		conflicts []*MempoolEntry
+		replaced  []*MempoolEntry
+		seen      = make(map[TXHash]bool)
+	)
+	for _, input := range tx.Inputs {
+		key := utxoKey(hex.EncodeToString(input.PrevTxHash), int(input.PrevOutIndex))
+		spender, ok := pool.spends[key]
+		if !ok || seen[spender] {
+			continue
+		}
+		conflicts = append(conflicts, pool.txx[spender])
+		for _, e := range pool.withDescendants(pool.txx[spender]) {
+			if !seen[e.Hash] {
+				seen[e.Hash] = true
+				replaced = append(replaced, e)
+			}
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil, pool.insert(entry, nil)
+	}
+
+	if len(replaced) > maxReplacements {
+		return nil, fmt.Errorf("%w: would replace (%d) txs max (%d)", ErrReplacementRejected, len(replaced), maxReplacements)
+	}
+	for _, input := range tx.Inputs {
+		if prevHash := hex.EncodeToString(input.PrevTxHash); seen[prevHash] {
+			return nil, fmt.Errorf("%w: spends an output of replaced tx %s", ErrReplacementRejected, prevHash)
+		}
+	}
+	for _, conflict := range conflicts {
+		if entry.FeeRate() <= conflict.FeeRate() {
+			return nil, fmt.Errorf("%w: fee rate (%d) not above (%d) of %s", ErrReplacementRejected, entry.FeeRate(), conflict.FeeRate(), conflict.Hash)
+		}
+	}
+	var replacedFee int64
+	for _, e := range replaced {
+		replacedFee += e.Fee
+	}
+	if fee <= replacedFee {
+		return nil, fmt.Errorf("%w: fee (%d) not above replaced fees (%d)", ErrReplacementRejected, fee, replacedFee)
+	}
+	if rate := feeRate(fee-replacedFee, entry.Size); rate < minRelayFeeRate {
+		return nil, fmt.Errorf("%w: additional fee rate (%d) minimum (%d)", ErrReplacementRejected, rate, minRelayFeeRate)
+	}
+
+	pool.removeAll(replaced)
+	if err := pool.insert(entry, replaced); err != nil {
+		pool.restore(replaced)
+		return nil, err
+	}
+
+	return entryHashes(replaced), nil
+}
+
+func newMempoolEntry(tx *proto.Transaction, fee int64) *MempoolEntry {
+	return &MempoolEntry{
+		Tx:    tx,
+		Hash:  hex.EncodeToString(types.HashTransaction(tx)),
+		Fee:   fee,
+		Size:  pb.Size(tx),
+		Added: time.Now(),
+
+		parents:  make(map[TXHash]*MempoolEntry),
+		children: make(map[TXHash]*MempoolEntry),
+	}
+}
+
+// insert makes room for entry and adds it. The caller has checked it does not
+// conflict with any mempool tx, and already took out the txs it replaces.
+func (pool *Mempool) insert(entry *MempoolEntry, replaced []*MempoolEntry) error {
+	evicted, err := pool.makeRoom(entry)
+	if err != nil {
+		return err
+	}
+
+	pool.seq++
+	entry.seq = pool.seq
+	pool.put(entry)
+
+	for _, e := range replaced {
+		pool.notify(MempoolEvent{Entry: e, Removed: true, Reason: RemovedReplaced})
+	}
+	for _, e := range evicted {
+		pool.notify(MempoolEvent{Entry: e, Removed: true, Reason: RemovedEvicted})
+	}
+	pool.notify(MempoolEvent{Entry: entry})
+	return nil
+}
+
+// put adds entry to the mempool and links it to its parents there. Children
+// of entry must not be in the mempool yet.
+func (pool *Mempool) put(entry *MempoolEntry) {
+	for _, input := range entry.Tx.Inputs {
+		prevHash := hex.EncodeToString(input.PrevTxHash)
+		pool.spends[utxoKey(prevHash, int(input.PrevOutIndex))] = entry.Hash
+		if parent, ok := pool.txx[prevHash]; ok {
+			entry.parents[prevHash] = parent
+			parent.children[entry.Hash] = entry
+		}
+	}
+	pool.txx[entry.Hash] = entry
+	pool.size += entry.Size
+
+	entry.descFee, entry.descSize = entry.Fee, entry.Size
+	entry.ancFee, entry.ancSize, entry.ancCount = entry.Fee, entry.Size, 1
+	for _, ancestor := range pool.ancestors(entry) {
+		entry.ancFee += ancestor.Fee
+		entry.ancSize += ancestor.Size
+		entry.ancCount++
+		ancestor.descFee += entry.Fee
+		ancestor.descSize += entry.Size
+		heap.Fix(&pool.evict, ancestor.evictIndex)
+	}
+	heap.Push(&pool.evict, entry)
+}
+
+// restore puts back entries taken out together, parents before children.
+func (pool *Mempool) restore(entries []*MempoolEntry) {
+	entries = slices.Clone(entries)
+	slices.SortFunc(entries, func(a, b *MempoolEntry) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+	for _, e := range entries {
+		pool.put(e)
+	}
+}
+
+// makeRoom evicts the packages paying the lowest fee rate until entry fits
+// and returns the evicted entries. Nothing is evicted for a tx that does not
+// pay a higher fee rate than every package it pushes out, or that would lose
+// one of its own parents.
+func (pool *Mempool) makeRoom(entry *MempoolEntry) ([]*MempoolEntry, error) {
+	if entry.Size > pool.cfg.MaxSize {
+		return nil, fmt.Errorf("%w: tx (%d) bytes max (%d)", ErrMempoolFull, entry.Size, pool.cfg.MaxSize)
+	}
+
+	parents := make(map[TXHash]bool)
+	for _, input := range entry.Tx.Inputs {
+		parents[hex.EncodeToString(input.PrevTxHash)] = true
+	}
+
+	var (
+		evicted []*MempoolEntry
+		err     error
+	)
+	for len(pool.txx)+1 > pool.cfg.MaxTxs || pool.size+entry.Size > pool.cfg.MaxSize {
+		if len(pool.evict) == 0 {
+			err = fmt.Errorf("%w: nothing left to evict", ErrMempoolFull)
+			break
+		}
+		victim := pool.evict[0]
+		if rate := feeRate(victim.descFee, victim.descSize); entry.FeeRate() <= rate {
+			err = fmt.Errorf("%w: fee rate (%d) not above (%d) of %s and its descendants", ErrMempoolFull, entry.FeeRate(), rate, victim.Hash)
+			break
+		}
+		pkg := pool.withDescendants(victim)
+		if i := slices.IndexFunc(pkg, func(e *MempoolEntry) bool { return parents[e.Hash] }); i >= 0 {
+			err = fmt.Errorf("%w: would evict parent %s", ErrMempoolFull, pkg[i].Hash)
+			break
+		}
+		evicted = append(evicted, pool.removeAll(pkg)...)
+	}
+	if err != nil {
+		pool.restore(evicted)
+		return nil, err
+	}
+
+	return evicted, nil
+}
+
+// withDescendants returns entry and every mempool tx spending its outputs,
+// directly or through other mempool txs.
+func (pool *Mempool) withDescendants(entry *MempoolEntry) []*MempoolEntry {
+	var (
+		entries = []*MempoolEntry{entry}
+		seen    = map[TXHash]bool{entry.Hash: true}
+	)
+	for i := 0; i < len(entries); i++ {
+		for hash, child := range entries[i].children {
+			if !seen[hash] {
+				seen[hash] = true
+				entries = append(entries, child)
+			}
+		}
+	}
+
+	return entries
+}
+
+// ancestors returns the mempool txs entry spends from, directly or through
+// other mempool txs.
+func (pool *Mempool) ancestors(entry *MempoolEntry) []*MempoolEntry {
+	var (
+		entries []*MempoolEntry
+		queue   = []*MempoolEntry{entry}
+		seen    = map[TXHash]bool{entry.Hash: true}
+	)
+	for len(queue) > 0 {
+		e := queue[0]
+		queue = queue[1:]
+		for hash, parent := range e.parents {
+			if !seen[hash] {
+				seen[hash] = true
+				entries = append(entries, parent)
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	return entries
+}
+
+// Ancestors returns the hashes of the mempool txs the tx with the given hash
+// depends on.
+func (pool *Mempool) Ancestors(hash TXHash) []TXHash {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	entry, ok := pool.txx[hash]
+	if !ok {
+		return nil
+	}
+	return entryHashes(pool.ancestors(entry))
+}
+
+// Descendants returns the hashes of the mempool txs depending on the tx with
+// the given hash.
+func (pool *Mempool) Descendants(hash TXHash) []TXHash {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	entry, ok := pool.txx[hash]
+	if !ok {
+		return nil
+	}
+	return entryHashes(pool.withDescendants(entry)[1:])
+}
+
+func entryHashes(entries []*MempoolEntry) []TXHash {
+	hashes := make([]TXHash, len(entries))
+	for i, e := range entries {
+		hashes[i] = e.Hash
+	}
+	return hashes
+}
+
+// Expire drops txs that have been waiting longer than the configured expiry,
+// together with their descendants, and returns how many were dropped.
+func (pool *Mempool) Expire() int {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	return pool.expire(time.Now())
+}
+
+func (pool *Mempool) expire(now time.Time) int {
+	expired := 0
+	for _, entry := range pool.txx {
+		if now.Sub(entry.Added) <= pool.cfg.Expiry {
+			continue
+		}
+		if _, ok := pool.txx[entry.Hash]; !ok {
+			// already gone with an expired ancestor
+			continue
+		}
+		for _, e := range pool.removeAll(pool.withDescendants(entry)) {
+			pool.notify(MempoolEvent{Entry: e, Removed: true, Reason: RemovedExpired})
+			expired++
+		}
+	}
+
+	return expired
+}
+
+// removeAll takes out entries, which hold every mempool descendant of each of
+// them, children before parents, and returns them in that order.
+func (pool *Mempool) removeAll(entries []*MempoolEntry) []*MempoolEntry {
+	entries = slices.Clone(entries)
+	slices.SortFunc(entries, func(a, b *MempoolEntry) int {
+		return cmp.Compare(b.seq, a.seq)
+	})
+	for _, e := range entries {
+		pool.remove(e)
+	}
+	return entries
+}
+
+// remove takes entry out of the mempool. Either its mempool ancestors or its
+// mempool descendants must be gone already, for the package totals of the
+// entries left to stay right.
+func (pool *Mempool) remove(entry *MempoolEntry) {
+	for _, ancestor := range pool.ancestors(entry) {
+		ancestor.descFee -= entry.Fee
+		ancestor.descSize -= entry.Size
+		heap.Fix(&pool.evict, ancestor.evictIndex)
+	}
+	for _, descendant := range pool.withDescendants(entry)[1:] {
+		descendant.ancFee -= entry.Fee
+		descendant.ancSize -= entry.Size
+		descendant.ancCount--
+	}
+	heap.Remove(&pool.evict, entry.evictIndex)
+
+	delete(pool.txx, entry.Hash)
+	pool.size -= entry.Size
+
+	for hash, parent := range entry.parents {
+		delete(parent.children, entry.Hash)
+		delete(entry.parents, hash)
+	}
+	for hash, child := range entry.children {
+		delete(child.parents, entry.Hash)
+		delete(entry.children, hash)
+	}
+
+	for _, input := range entry.Tx.Inputs {
+		key := utxoKey(hex.EncodeToString(input.PrevTxHash), int(input.PrevOutIndex))
+		if pool.spends[key] == entry.Hash {
+			delete(pool.spends, key)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package node
+
+import (
+	"sort"
+)
+
+// mempoolSubBuffer is how many events a subscriber may lag behind before it
+// gets dropped.
+const mempoolSubBuffer = 256
+
+// feeRateBuckets are the lower bounds, in fee per 1000 bytes, of the buckets
+// of the mempool fee histogram.
+var feeRateBuckets = []int64{0, 1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000}
+
+// RemovalReason tells why a tx left the mempool.
+type RemovalReason int
+
+const (
+	RemovedMined RemovalReason = iota + 1
+	RemovedReplaced
+	RemovedEvicted
+	RemovedExpired
+	// RemovedReset is sent for every tx when the mempool is cleared to be
+	// revalidated; the ones still valid are added right back.
+	RemovedReset
+	RemovedConflict
+)
+
+// MempoolEvent is sent to subscribers whenever a tx enters or leaves the
+// mempool.
+type MempoolEvent struct {
+	Entry   *MempoolEntry
+	Removed bool
+	Reason  RemovalReason
+}
+
+type FeeRateBucket struct {
+	MinFeeRate int64
+	Count      int
+	Size       int
+}
+
+type MempoolStats struct {
+	Count      int
+	Size       int
+	MinFeeRate int64
+	Histogram  []FeeRateBucket
+}
+
+// Subscribe returns a channel receiving every mempool event from now on and a
+// func to unsubscribe. A subscriber that does not keep up has its channel
+// closed.
+func (pool *Mempool) Subscribe() (<-chan MempoolEvent, func()) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	id := pool.nextSub
+	pool.nextSub++
+	ch := make(chan MempoolEvent, mempoolSubBuffer)
+	pool.subs[id] = ch
+
+	return ch, func() {
+		pool.lock.Lock()
+		defer pool.lock.Unlock()
+
+		if ch, ok := pool.subs[id]; ok {
+			delete(pool.subs, id)
+			close(ch)
+		}
+	}
+}
+
+func (pool *Mempool) notify(ev MempoolEvent) {
+	for id, ch := range pool.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(pool.subs, id)
+			close(ch)
+		}
+	}
+}
+
+// Entries returns the mempool entries in arrival order.
+func (pool *Mempool) Entries() []*MempoolEntry {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	entries := make([]*MempoolEntry, 0, len(pool.txx))
+	for _, entry := range pool.txx {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seq < entries[j].seq
+	})
+
+	return entries
+}
+
+func (pool *Mempool) Entry(hash TXHash) (*MempoolEntry, bool) {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	entry, ok := pool.txx[hash]
+	return entry, ok
+}
+
+// Stats returns the size of the mempool and a histogram of the fee rates it
+// holds.
+func (pool *Mempool) Stats() MempoolStats {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	stats := MempoolStats{
+		Count:      len(pool.txx),
+		Size:       pool.size,
+		MinFeeRate: pool.minFeeRate(),
+		Histogram:  make([]FeeRateBucket, len(feeRateBuckets)),
+	}
+	for i, rate := range feeRateBuckets {
+		stats.Histogram[i].MinFeeRate = rate
+	}
+	for _, entry := range pool.txx {
+		i := sort.Search(len(feeRateBuckets), func(i int) bool {
+			return feeRateBuckets[i] > entry.FeeRate()
+		}) - 1
+		// negative fee rates can't get in, but keep them out of the way
+		if i < 0 {
+			i = 0
+		}
+		stats.Histogram[i].Count++
+		stats.Histogram[i].Size += entry.Size
+	}
+
+	return stats
+}
@@ -0,0 +1,173 @@
+package node
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/pdrm26/blocker/crypto"
+	"github.com/pdrm26/blocker/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestMempoolSubscribe(t *testing.T) {
+	var (
+		pool        = NewMempool()
+		privKey     = crypto.NewPrivateKey()
+		prevTx      = orphanTX(t, privKey, 0)
+		original    = spendOutput(t, privKey, prevTx, 0, 80)
+		replacement = spendOutput(t, privKey, prevTx, 0, 70)
+	)
+
+	events, unsubscribe := pool.Subscribe()
+
+	assert.Nil(t, pool.Add(original, 10))
+	_, err := pool.Replace(replacement, 20)
+	assert.Nil(t, err)
+	pool.Remove([]*proto.Transaction{replacement})
+
+	want := []MempoolEvent{
+		{Entry: &MempoolEntry{Hash: hashOf(original)}},
+		{Entry: &MempoolEntry{Hash: hashOf(original)}, Removed: true, Reason: RemovedReplaced},
+		{Entry: &MempoolEntry{Hash: hashOf(replacement)}},
+		{Entry: &MempoolEntry{Hash: hashOf(replacement)}, Removed: true, Reason: RemovedMined},
+	}
+	for _, w := range want {
+		ev := <-events
+		assert.Equal(t, w.Entry.Hash, ev.Entry.Hash)
+		assert.Equal(t, w.Removed, ev.Removed)
+		assert.Equal(t, w.Reason, ev.Reason)
+	}
+
+	unsubscribe()
+	_, ok := <-events
+	assert.False(t, ok)
+	// unsubscribing twice is fine
+	unsubscribe()
+}
+
+func TestMempoolDropsSlowSubscriber(t *testing.T) {
+	var (
+		pool    = NewMempool()
+		privKey = crypto.NewPrivateKey()
+	)
+
+	events, unsubscribe := pool.Subscribe()
+	defer unsubscribe()
+
+	for i := 0; i <= mempoolSubBuffer; i++ {
+		assert.Nil(t, pool.Add(orphanTX(t, privKey, i), 10))
+	}
+
+	received := 0
+	for range events {
+		received++
+	}
+	assert.Equal(t, mempoolSubBuffer, received)
+}
+
+func TestMempoolStats(t *testing.T) {
+	var (
+		pool    = NewMempool()
+		privKey = crypto.NewPrivateKey()
+		cheap   = orphanTX(t, privKey, 0)
+	)
+
+	assert.Nil(t, pool.Add(cheap, 1))
+	assert.Nil(t, pool.Add(orphanTX(t, privKey, 1), 1000))
+	assert.Nil(t, pool.Add(orphanTX(t, privKey, 2), 1000))
+
+	stats := pool.Stats()
+	assert.Equal(t, 3, stats.Count)
+	assert.Equal(t, pool.Size(), stats.Size)
+	assert.Equal(t, int64(minRelayFeeRate), stats.MinFeeRate)
+	assert.Equal(t, len(feeRateBuckets), len(stats.Histogram))
+
+	counts := make(map[int64]int)
+	for _, bucket := range stats.Histogram {
+		counts[bucket.MinFeeRate] = bucket.Count
+	}
+	// cheap pays a few units per 1000 bytes, the others a few thousand
+	rate := pool.txx[hashOf(cheap)].FeeRate()
+	assert.True(t, rate >= 2 && rate < 10)
+	assert.Equal(t, 1, counts[2]+counts[5])
+	assert.Equal(t, 2, counts[2000]+counts[5000])
+}
+
+func TestMempoolRPCs(t *testing.T) {
+	var (
+		n       = NewNode(ServerConfig{})
+		privKey = genesisPrivKey(t)
+		tx      = spendOutput(t, privKey, genesisTX(t, n.chain), 0, 990)
+		ctx     = context.Background()
+	)
+	assert.Nil(t, n.acceptTransaction(tx))
+
+	list, err := n.ListMempool(ctx, &emptypb.Empty{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(list.Entries))
+	assert.Equal(t, int64(10), list.Entries[0].Fee)
+	assert.Nil(t, list.Entries[0].Transaction)
+
+	entry, err := n.GetMempoolEntry(ctx, &proto.GetTransactionRequest{Hash: list.Entries[0].Hash})
+	assert.Nil(t, err)
+	assert.Equal(t, hashOf(tx), hashOf(entry.Transaction))
+	assert.Equal(t, feeRate(10, int(entry.Size)), entry.FeeRate)
+
+	_, err = n.GetMempoolEntry(ctx, &proto.GetTransactionRequest{Hash: []byte("missing")})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	stats, err := n.GetMempoolStats(ctx, &emptypb.Empty{})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), stats.Count)
+	assert.Equal(t, int64(entry.Size), stats.Size)
+}
+
+func TestSubscribeMempoolStream(t *testing.T) {
+	var (
+		n       = NewNode(ServerConfig{})
+		privKey = genesisPrivKey(t)
+		tx      = spendOutput(t, privKey, genesisTX(t, n.chain), 0, 990)
+		ln      = bufconn.Listen(1 << 20)
+		server  = grpc.NewServer()
+	)
+	proto.RegisterNodeServer(server, n)
+	go server.Serve(ln)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := proto.NewNodeClient(conn).SubscribeMempool(ctx, &emptypb.Empty{})
+	assert.Nil(t, err)
+	// the subscription is in place once the stream headers are in
+	_, err = stream.Header()
+	assert.Nil(t, err)
+
+	assert.Nil(t, n.acceptTransaction(tx))
+	n.mempool.Remove([]*proto.Transaction{tx})
+
+	ev, err := stream.Recv()
+	assert.Nil(t, err)
+	assert.False(t, ev.Removed)
+	assert.Equal(t, int64(10), ev.Entry.Fee)
+
+	ev, err = stream.Recv()
+	assert.Nil(t, err)
+	assert.True(t, ev.Removed)
+	assert.Equal(t, proto.RemovalReason_REMOVAL_MINED, ev.Reason)
+}
@@ -0,0 +1,494 @@
+package node
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/pdrm26/blocker/crypto"
+	"github.com/pdrm26/blocker/proto"
+	"github.com/pdrm26/blocker/types"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// spendOutput returns a tx signed by privKey that spends the given output of
+// prevTx into outputs of the given amounts, all paying back to privKey.
+func spendOutput(t *testing.T, privKey *crypto.PrivateKey, prevTx *proto.Transaction, outIndex int, amounts ...int64) *proto.Transaction {
+	tx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   types.HashTransaction(prevTx),
+				PrevOutIndex: uint32(outIndex),
+				PublicKey:    privKey.Public().Bytes(),
+			},
+		},
+	}
+	for _, amount := range amounts {
+		tx.Outputs = append(tx.Outputs, &proto.TxOutput{
+			Amount:  amount,
+			Address: privKey.Public().Address().Bytes(),
+		})
+	}
+	tx.Inputs[0].Signature = types.SignTransaction(tx, privKey).Bytes()
+
+	return tx
+}
+
+func hashOf(tx *proto.Transaction) TXHash {
+	return hex.EncodeToString(types.HashTransaction(tx))
+}
+
+func genesisTX(t *testing.T, chain *Chain) *proto.Transaction {
+	tx, err := chain.txStore.Get(genesisTXHash)
+	assert.Nil(t, err)
+	return tx
+}
+
+func TestMempoolAddConflict(t *testing.T) {
+	var (
+		pool    = NewMempool()
+		chain   = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		privKey = genesisPrivKey(t)
+		tx      = spendOutput(t, privKey, genesisTX(t, chain), 0, 990)
+	)
+
+	assert.Nil(t, pool.Add(tx, 10))
+	assert.ErrorIs(t, pool.Add(tx, 10), ErrDuplicateTx)
+	assert.ErrorIs(t, pool.Add(spendOutput(t, privKey, genesisTX(t, chain), 0, 980), 20), ErrMempoolConflict)
+	assert.Equal(t, 1, pool.Len())
+
+	spender, ok := pool.SpentBy(utxoKey(genesisTXHash, 0))
+	assert.True(t, ok)
+	assert.Equal(t, hashOf(tx), spender)
+
+	output, ok := pool.Output(hashOf(tx), 0)
+	assert.True(t, ok)
+	assert.Equal(t, int64(990), output.Amount)
+	_, ok = pool.Output(hashOf(tx), 1)
+	assert.False(t, ok)
+}
+
+func TestMempoolClearKeepsArrivalOrder(t *testing.T) {
+	var (
+		pool    = NewMempool()
+		chain   = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		privKey = genesisPrivKey(t)
+		parent  = spendOutput(t, privKey, genesisTX(t, chain), 0, 990)
+	)
+
+	txs := []*proto.Transaction{parent}
+	for i := 0; i < 20; i++ {
+		txs = append(txs, spendOutput(t, privKey, txs[len(txs)-1], 0, int64(980-i*10)))
+	}
+	for _, tx := range txs {
+		assert.Nil(t, pool.Add(tx, 10))
+	}
+
+	assert.Equal(t, txs, pool.Clear())
+	assert.Equal(t, 0, pool.Len())
+	_, ok := pool.SpentBy(utxoKey(genesisTXHash, 0))
+	assert.False(t, ok)
+}
+
+func TestAcceptTransaction(t *testing.T) {
+	var (
+		n       = NewNode(ServerConfig{})
+		privKey = genesisPrivKey(t)
+		parent  = spendOutput(t, privKey, genesisTX(t, n.chain), 0, 990)
+	)
+
+	assert.Nil(t, n.acceptTransaction(parent))
+	assert.ErrorIs(t, n.acceptTransaction(parent), ErrDuplicateTx)
+
+	// children may spend unconfirmed outputs, and conflicting txs have to
+	// pay more than what they replace
+	child := spendOutput(t, privKey, parent, 0, 980)
+	assert.Nil(t, n.acceptTransaction(child))
+	assert.ErrorIs(t, n.acceptTransaction(spendOutput(t, privKey, parent, 0, 975, 5)), ErrReplacementRejected)
+	assert.ErrorIs(t, n.acceptTransaction(spendOutput(t, privKey, genesisTX(t, n.chain), 0, 985)), ErrReplacementRejected)
+	assert.Equal(t, 2, n.mempool.Len())
+
+	assert.ErrorIs(t, n.acceptTransaction(spendOutput(t, privKey, child, 0, 980)), ErrInsufficientFee)
+	assert.ErrorIs(t, n.acceptTransaction(spendOutput(t, privKey, child, 0, 990)), ErrInsufficientFunds)
+	assert.ErrorIs(t, n.acceptTransaction(spendOutput(t, privKey, child, 1, 10)), ErrUnknownInput)
+	assert.ErrorIs(t, n.acceptTransaction(spendOutput(t, crypto.NewPrivateKey(), child, 0, 10)), ErrInputNotOwned)
+	assert.ErrorIs(t, n.acceptTransaction(&proto.Transaction{Version: 1}), ErrNoInputs)
+
+	unsigned := spendOutput(t, privKey, child, 0, 970)
+	unsigned.Inputs[0].Signature = nil
+	assert.ErrorIs(t, n.acceptTransaction(unsigned), ErrInvalidSignature)
+
+	assert.Equal(t, 2, n.mempool.Len())
+}
+
+func TestReplaceByFee(t *testing.T) {
+	var (
+		n       = NewNode(ServerConfig{})
+		privKey = genesisPrivKey(t)
+		parent  = spendOutput(t, privKey, genesisTX(t, n.chain), 0, 990)
+		child   = spendOutput(t, privKey, parent, 0, 980)
+	)
+
+	assert.Nil(t, n.acceptTransaction(parent))
+	assert.Nil(t, n.acceptTransaction(child))
+
+	// a higher fee rate alone is not enough, it has to beat parent and child
+	assert.ErrorIs(t, n.acceptTransaction(spendOutput(t, privKey, genesisTX(t, n.chain), 0, 985)), ErrReplacementRejected)
+	assert.ErrorIs(t, n.acceptTransaction(spendOutput(t, privKey, genesisTX(t, n.chain), 0, 980)), ErrReplacementRejected)
+
+	replacement := spendOutput(t, privKey, genesisTX(t, n.chain), 0, 970)
+	assert.Nil(t, n.acceptTransaction(replacement))
+	assert.Equal(t, 1, n.mempool.Len())
+	assert.True(t, n.mempool.Has(replacement))
+	assert.False(t, n.mempool.Has(parent))
+	assert.False(t, n.mempool.Has(child))
+
+	spender, ok := n.mempool.SpentBy(utxoKey(genesisTXHash, 0))
+	assert.True(t, ok)
+	assert.Equal(t, hashOf(replacement), spender)
+}
+
+func TestMempoolReplaceRules(t *testing.T) {
+	var (
+		pool    = NewMempool()
+		privKey = crypto.NewPrivateKey()
+		prevTx  = &proto.Transaction{
+			Version: 1,
+			Outputs: []*proto.TxOutput{
+				{Amount: 100, Address: privKey.Public().Address().Bytes()},
+				{Amount: 100, Address: privKey.Public().Address().Bytes()},
+			},
+		}
+		original = spendOutput(t, privKey, prevTx, 0, 90)
+	)
+
+	assert.Nil(t, pool.Add(original, 10))
+
+	// the replacement may not build on the tx it replaces
+	both := spendOutput(t, privKey, prevTx, 0, 50)
+	both.Inputs = append(both.Inputs, &proto.TxInput{
+		PrevTxHash:   types.HashTransaction(original),
+		PrevOutIndex: 0,
+		PublicKey:    privKey.Public().Bytes(),
+	})
+	_, err := pool.Replace(both, 150)
+	assert.ErrorIs(t, err, ErrReplacementRejected)
+
+	replaced, err := pool.Replace(spendOutput(t, privKey, prevTx, 0, 80), 20)
+	assert.Nil(t, err)
+	assert.Equal(t, []TXHash{hashOf(original)}, replaced)
+
+	// without conflicts Replace is a plain Add
+	replaced, err = pool.Replace(spendOutput(t, privKey, prevTx, 1, 90), 10)
+	assert.Nil(t, err)
+	assert.Empty(t, replaced)
+	assert.Equal(t, 2, pool.Len())
+}
+
+func TestMempoolSelectsByPackageFeeRate(t *testing.T) {
+	var (
+		pool    = NewMempool()
+		privKey = crypto.NewPrivateKey()
+		parent  = orphanTX(t, privKey, 0)
+		child   = spendOutput(t, privKey, parent, 0, 80)
+		other   = orphanTX(t, privKey, 1)
+	)
+
+	assert.Nil(t, pool.Add(parent, 1))
+	assert.Nil(t, pool.Add(other, 50))
+	assert.Nil(t, pool.Add(child, 200))
+
+	assert.Equal(t, []TXHash{hashOf(parent)}, pool.Ancestors(hashOf(child)))
+	assert.Equal(t, []TXHash{hashOf(child)}, pool.Descendants(hashOf(parent)))
+	assert.Empty(t, pool.Ancestors(hashOf(other)))
+
+	// the child pays for its parent, and pulls it in first
+	assert.Equal(t, []*proto.Transaction{parent, child, other}, pool.SelectTransactions(MaxBlockSize, 10))
+	assert.Equal(t, []*proto.Transaction{parent, child}, pool.SelectTransactions(MaxBlockSize, 2))
+	// a package that does not fit makes way for one that does
+	assert.Equal(t, []*proto.Transaction{other}, pool.SelectTransactions(MaxBlockSize, 1))
+
+	pool.Remove([]*proto.Transaction{parent})
+	assert.Empty(t, pool.Ancestors(hashOf(child)))
+	assert.Equal(t, []*proto.Transaction{child, other}, pool.SelectTransactions(MaxBlockSize, 10))
+}
+
+func TestMempoolPackageTotals(t *testing.T) {
+	var (
+		pool    = NewMempool()
+		privKey = crypto.NewPrivateKey()
+		parent  = orphanTX(t, privKey, 0)
+		child   = spendOutput(t, privKey, parent, 0, 80, 5)
+		left    = spendOutput(t, privKey, child, 0, 70)
+		right   = spendOutput(t, privKey, child, 1, 1)
+	)
+	for i, tx := range []*proto.Transaction{parent, child, left, right} {
+		assert.Nil(t, pool.Add(tx, int64(10*(i+1))))
+	}
+	entry := func(tx *proto.Transaction) *MempoolEntry { return pool.txx[hashOf(tx)] }
+
+	assert.Equal(t, int64(100), entry(parent).descFee)
+	assert.Equal(t, int64(90), entry(child).descFee)
+	assert.Equal(t, int64(60), entry(left).ancFee)
+	assert.Equal(t, 3, entry(right).ancCount)
+	assert.Equal(t, entry(parent).Size+entry(child).Size+entry(right).Size, entry(right).ancSize)
+
+	pool.Remove([]*proto.Transaction{parent})
+	assert.Equal(t, int64(50), entry(left).ancFee)
+	assert.Equal(t, 2, entry(right).ancCount)
+
+	replacement := spendOutput(t, privKey, child, 1, 0)
+	_, err := pool.Replace(replacement, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(150), entry(child).descFee)
+	assert.Equal(t, entry(child).Size+entry(left).Size+entry(replacement).Size, entry(child).descSize)
+	assert.Equal(t, int64(120), entry(replacement).ancFee)
+}
+
+func TestValidatorBlockPullsInParent(t *testing.T) {
+	var (
+		n       = NewNode(ServerConfig{PrivKey: crypto.NewPrivateKey()})
+		privKey = genesisPrivKey(t)
+		parent  = spendOutput(t, privKey, genesisTX(t, n.chain), 0, 500, 499)
+		child   = spendOutput(t, privKey, parent, 0, 400)
+		other   = spendOutput(t, privKey, parent, 1, 489)
+	)
+
+	assert.Nil(t, n.acceptTransaction(parent))
+	assert.Nil(t, n.acceptTransaction(other))
+	assert.Nil(t, n.acceptTransaction(child))
+
+	block, err := n.createBlock()
+	assert.Nil(t, err)
+	assert.Equal(t, []*proto.Transaction{parent, child, other}, block.Transactions)
+	assert.Equal(t, 0, n.mempool.Len())
+}
+
+func TestValidatorBlockFromMempool(t *testing.T) {
+	var (
+		n       = NewNode(ServerConfig{PrivKey: crypto.NewPrivateKey()})
+		privKey = genesisPrivKey(t)
+		parent  = spendOutput(t, privKey, genesisTX(t, n.chain), 0, 990)
+		child   = spendOutput(t, privKey, parent, 0, 980)
+	)
+
+	assert.Nil(t, n.acceptTransaction(parent))
+	assert.Nil(t, n.acceptTransaction(child))
+
+	block, err := n.createBlock()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n.chain.Height())
+	assert.Equal(t, 2, len(block.Transactions))
+	assert.Equal(t, 0, n.mempool.Len())
+
+	_, location, err := n.chain.GetTransaction(hashOf(child))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, location.Index)
+}
+
+func TestMempoolEvictsLowestFeeRate(t *testing.T) {
+	var (
+		pool    = NewMempoolWithConfig(MempoolConfig{MaxTxs: 3})
+		chain   = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		privKey = genesisPrivKey(t)
+		parent  = spendOutput(t, privKey, genesisTX(t, chain), 0, 990)
+		child   = spendOutput(t, privKey, parent, 0, 980)
+		other   = orphanTX(t, privKey, 0)
+	)
+
+	assert.Nil(t, pool.Add(other, 10))
+	assert.Nil(t, pool.Add(parent, 20))
+	assert.Nil(t, pool.Add(child, 30))
+
+	assert.ErrorIs(t, pool.Add(orphanTX(t, privKey, 1), 5), ErrMempoolFull)
+	assert.Equal(t, 3, pool.Len())
+
+	tx := orphanTX(t, privKey, 1)
+	assert.Nil(t, pool.Add(tx, 60))
+	assert.False(t, pool.Has(other))
+	assert.True(t, pool.Has(parent))
+
+	// the parent goes, and its child can't stay without it
+	assert.Nil(t, pool.Add(orphanTX(t, privKey, 2), 60))
+	assert.Equal(t, 2, pool.Len())
+	assert.False(t, pool.Has(parent))
+	assert.False(t, pool.Has(child))
+	assert.True(t, pool.Has(tx))
+	_, ok := pool.SpentBy(utxoKey(genesisTXHash, 0))
+	assert.False(t, ok)
+
+	// a tx never pushes out its own parent
+	cheap := orphanTX(t, privKey, 3)
+	assert.Nil(t, pool.Add(cheap, 40))
+	assert.ErrorIs(t, pool.Add(spendOutput(t, privKey, cheap, 0, 10), 1000), ErrMempoolFull)
+	assert.True(t, pool.Has(cheap))
+}
+
+func TestMempoolEvictionCountsDescendants(t *testing.T) {
+	var (
+		pool    = NewMempoolWithConfig(MempoolConfig{MaxTxs: 2})
+		chain   = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		privKey = genesisPrivKey(t)
+		parent  = spendOutput(t, privKey, genesisTX(t, chain), 0, 990)
+		child   = spendOutput(t, privKey, parent, 0, 980)
+	)
+
+	assert.Nil(t, pool.Add(parent, 10))
+	assert.Nil(t, pool.Add(child, 100))
+
+	// the parent pays little on its own, but its child pays for it
+	assert.ErrorIs(t, pool.Add(orphanTX(t, privKey, 0), 30), ErrMempoolFull)
+	assert.True(t, pool.Has(parent))
+	assert.True(t, pool.Has(child))
+	assert.Equal(t, []TXHash{hashOf(child)}, pool.Descendants(hashOf(parent)))
+
+	// a higher fee rate than the package pushes all of it out, even for
+	// less fees than the package paid
+	tx := orphanTX(t, privKey, 0)
+	assert.Nil(t, pool.Add(tx, 70))
+	assert.Equal(t, 1, pool.Len())
+	assert.Equal(t, pool.txx[hashOf(tx)].Size, pool.Size())
+}
+
+func TestMempoolExpire(t *testing.T) {
+	var (
+		pool    = NewMempoolWithConfig(MempoolConfig{Expiry: time.Hour})
+		chain   = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		privKey = genesisPrivKey(t)
+		parent  = spendOutput(t, privKey, genesisTX(t, chain), 0, 990)
+		child   = spendOutput(t, privKey, parent, 0, 980)
+		fresh   = orphanTX(t, privKey, 0)
+	)
+
+	assert.Nil(t, pool.Add(parent, 10))
+	assert.Nil(t, pool.Add(child, 10))
+	assert.Nil(t, pool.Add(fresh, 10))
+	pool.txx[hashOf(parent)].Added = time.Now().Add(-2 * time.Hour)
+
+	assert.Equal(t, 2, pool.Expire())
+	assert.Equal(t, 1, pool.Len())
+	assert.Equal(t, pool.txx[hashOf(fresh)].Size, pool.Size())
+}
+
+func TestExpiredTxNotMined(t *testing.T) {
+	var (
+		n       = NewNode(ServerConfig{PrivKey: crypto.NewPrivateKey(), Mempool: MempoolConfig{Expiry: time.Hour}})
+		privKey = genesisPrivKey(t)
+		tx      = spendOutput(t, privKey, genesisTX(t, n.chain), 0, 990)
+		orphan  = orphanTX(t, privKey, 0)
+	)
+	assert.Nil(t, n.acceptTransaction(tx))
+	assert.Nil(t, n.orphans.Add(orphan, "peer", []TXHash{"parent"}))
+	n.mempool.txx[hashOf(tx)].Added = time.Now().Add(-2 * time.Hour)
+	n.orphans.orphans[hashOf(orphan)].expires = time.Now().Add(-time.Second)
+
+	// no new tx comes in, the stale ones still go
+	n.expireTransactions()
+	assert.Equal(t, 0, n.mempool.Len())
+	assert.Equal(t, 0, n.orphans.Len())
+
+	assert.Nil(t, n.acceptTransaction(tx))
+	n.mempool.txx[hashOf(tx)].Added = time.Now().Add(-2 * time.Hour)
+	block, err := n.createBlock()
+	assert.Nil(t, err)
+	assert.Empty(t, block.Transactions)
+}
+
+func TestMempoolMinFeeRateRises(t *testing.T) {
+	var (
+		pool    = NewMempoolWithConfig(MempoolConfig{MaxTxs: 10})
+		privKey = crypto.NewPrivateKey()
+	)
+
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, pool.Add(orphanTX(t, privKey, i), 10))
+	}
+	assert.Equal(t, int64(minRelayFeeRate), pool.MinFeeRate())
+
+	assert.Nil(t, pool.Add(orphanTX(t, privKey, 4), 10))
+	assert.Equal(t, int64(2*minRelayFeeRate), pool.MinFeeRate())
+
+	assert.Nil(t, pool.Add(orphanTX(t, privKey, 5), 10))
+	assert.Equal(t, int64(4*minRelayFeeRate), pool.MinFeeRate())
+}
+
+func TestDisconnectTipReturnsTxsToMempool(t *testing.T) {
+	var (
+		n       = NewNode(ServerConfig{PrivKey: crypto.NewPrivateKey()})
+		privKey = genesisPrivKey(t)
+		parent  = spendOutput(t, privKey, genesisTX(t, n.chain), 0, 990)
+		child   = spendOutput(t, privKey, parent, 0, 980)
+	)
+
+	assert.Nil(t, n.acceptTransaction(parent))
+	_, err := n.createBlock()
+	assert.Nil(t, err)
+	assert.Nil(t, n.acceptTransaction(child))
+
+	_, err = n.DisconnectTip()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n.chain.Height())
+	assert.Equal(t, 2, n.mempool.Len())
+	assert.Equal(t, []*proto.Transaction{parent, child}, n.mempool.SelectTransactions(MaxBlockSize, MaxBlockTxs))
+
+	block, err := n.createBlock()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(block.Transactions))
+}
+
+func TestHandleBlockUpdatesMempool(t *testing.T) {
+	var (
+		validator = NewNode(ServerConfig{PrivKey: crypto.NewPrivateKey()})
+		n         = NewNode(ServerConfig{})
+		privKey   = genesisPrivKey(t)
+		mined     = spendOutput(t, privKey, genesisTX(t, n.chain), 0, 990)
+		conflict  = spendOutput(t, privKey, genesisTX(t, n.chain), 0, 980)
+		child     = spendOutput(t, privKey, conflict, 0, 970)
+		ctx       = context.Background()
+	)
+
+	assert.Nil(t, validator.acceptTransaction(mined))
+	assert.Nil(t, n.acceptTransaction(conflict))
+	assert.Nil(t, n.acceptTransaction(child))
+
+	block, err := validator.createBlock()
+	assert.Nil(t, err)
+
+	_, err = n.HandleBlock(ctx, block)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n.chain.Height())
+	assert.Equal(t, 0, n.mempool.Len())
+
+	_, err = n.HandleBlock(ctx, block)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = n.HandleBlock(ctx, &proto.Block{})
+	assert.Equal(t, "MISSING_HEADER", RejectReason(err))
+}
+
+func TestCreateBlockFailureRevalidatesMempool(t *testing.T) {
+	var (
+		n       = NewNode(ServerConfig{PrivKey: crypto.NewPrivateKey()})
+		privKey = genesisPrivKey(t)
+		valid   = spendOutput(t, privKey, genesisTX(t, n.chain), 0, 990)
+		invalid = orphanTX(t, privKey, 0)
+	)
+
+	assert.Nil(t, n.acceptTransaction(valid))
+	// got in without admission checks
+	assert.Nil(t, n.mempool.Add(invalid, 1000))
+
+	_, err := n.createBlock()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, n.chain.Height())
+	assert.Equal(t, 1, n.mempool.Len())
+	assert.True(t, n.mempool.Has(valid))
+
+	block, err := n.createBlock()
+	assert.Nil(t, err)
+	assert.Equal(t, []*proto.Transaction{valid}, block.Transactions)
+}
@@ -1,9 +1,12 @@
 package node
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
+	"fmt"
 	"net"
+	"slices"
 	"sync"
 	"time"
 
@@ -12,97 +15,153 @@ import (
 	"github.com/pdrm26/blocker/types"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	pb "google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 const blockTime = time.Second * 5
 
-type Mempool struct {
-	lock sync.RWMutex
-	txx  map[string]*proto.Transaction
-}
-
-func NewMempool() *Mempool {
-	return &Mempool{
-		txx: make(map[string]*proto.Transaction),
-	}
-}
-
-func (pool *Mempool) Clear() []*proto.Transaction {
-	pool.lock.Lock()
-	defer pool.lock.Unlock()
-
-	txs := make([]*proto.Transaction, len(pool.txx))
-	it := 0
-	for k, v := range pool.txx {
-		delete(pool.txx, k)
-		txs[it] = v
-		it++
-	}
-
-	return txs
-}
-
-func (pool *Mempool) Len() int {
-	pool.lock.RLock()
-	defer pool.lock.RUnlock()
-
-	return len(pool.txx)
-}
-
-func (pool *Mempool) Has(tx *proto.Transaction) bool {
-	pool.lock.RLock()
-	defer pool.lock.RUnlock()
-
-	hash := hex.EncodeToString(types.HashTransaction(tx))
-	_, ok := pool.txx[hash]
-	return ok
-}
-func (pool *Mempool) Add(tx *proto.Transaction) bool {
-	if pool.Has(tx) {
-		return false
-	}
+// blockReserve is the room kept free for the header, the signatures and the
+// per-tx framing when filling a block from the mempool.
+const blockReserve = 64 << 10
 
-	pool.lock.Lock()
-	defer pool.lock.Unlock()
-
-	hash := hex.EncodeToString(types.HashTransaction(tx))
-	pool.txx[hash] = tx
-	return true
-}
+// expireInterval is how often txs that waited too long are dropped from the
+// mempool and the orphan pool.
+const expireInterval = time.Minute
 
 type ServerConfig struct {
-	Version    int32
+	// Version pins the newest protocol version we speak, zero speaks
+	// ProtocolVersion.
+	Version int32
+	// Services is what we offer our peers. Zero offers all a full node
+	// can, a PrivKey adds ServiceValidator. A pruned chain offers
+	// ServicePruned instead of ServiceFullNode.
+	Services   Services
 	ListenAddr string
 	PrivKey    *crypto.PrivateKey
+	// IdentityKey proves who we are to our peers. A new one is made when
+	// it is nil.
+	IdentityKey *crypto.PrivateKey
+	Mempool     MempoolConfig
+	// BanFile is where the banned peers are saved, empty keeps them in
+	// memory only.
+	BanFile string
+	// TLS secures the connections to and from peers, nil leaves them in
+	// plaintext.
+	TLS *TLSConfig
+	// AddrBookFile is where the addresses of known nodes are saved, empty
+	// keeps them in memory only.
+	AddrBookFile string
+	// MaxOutbound is how many peers we dial ourselves, MaxInbound how many
+	// may dial us. Zero uses the defaults.
+	MaxOutbound int
+	MaxInbound  int
+	// RateLimits bounds how fast peers and clients may call us.
+	RateLimits RateLimitConfig
+	// AdminAddr is where the Admin service listens, like
+	// "127.0.0.1:3001". It only takes calls from this machine. Empty
+	// doesn't serve it.
+	AdminAddr string
 }
 
 type Node struct {
 	logger *zap.SugaredLogger
 
 	peerLock sync.RWMutex
-	peers    map[proto.NodeClient]*proto.PeerInfo
-	mempool  *Mempool
+	peers    map[NodeID]*peerConn
+	// persistent are the addresses we keep reconnecting to, and dialing
+	// the ones we are currently trying.
+	persistent map[string]bool
+	dialing    map[string]bool
+	mempool    *Mempool
+	orphans    *OrphanPool
+	chain      *Chain
+	inv        *inventory
+	addrs      *AddrBook
+	bans       *BanList
+	limiter    *rateLimiter
+	// clientCreds secure the connections we dial.
+	clientCreds credentials.TransportCredentials
 	ServerConfig
 
 	proto.UnimplementedNodeServer
 }
 
 func NewNode(serverConfig ServerConfig) *Node {
+	return newNode(serverConfig, NewChain(NewMemoryBlockStore(), NewMemoryTXStore()))
+}
+
+// NewNodeFromSnapshot creates a node that starts from a trusted UTXO
+// snapshot instead of an empty chain. The snapshot commitment must match
+// trustedCommitment.
+func NewNodeFromSnapshot(serverConfig ServerConfig, snapshot *UTXOSnapshot, trustedCommitment []byte) (*Node, error) {
+	if !bytes.Equal(snapshot.Commitment, trustedCommitment) {
+		return nil, fmt.Errorf("snapshot commitment does not match the trusted commitment")
+	}
+
+	chain, err := NewChainFromSnapshot(NewMemoryBlockStore(), NewMemoryTXStore(), snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return newNode(serverConfig, chain), nil
+}
+
+func newNode(serverConfig ServerConfig, chain *Chain) *Node {
 	logger, _ := zap.NewProduction()
+	if serverConfig.IdentityKey == nil {
+		serverConfig.IdentityKey = crypto.NewPrivateKey()
+	}
+	bans, err := NewBanList(serverConfig.BanFile)
+	if err != nil {
+		logger.Sugar().Errorw("could not load ban list, starting without bans", "path", serverConfig.BanFile, "error", err)
+		bans = newBanList(serverConfig.BanFile)
+	}
+	addrs, err := NewAddrBook(serverConfig.AddrBookFile)
+	if err != nil {
+		logger.Sugar().Errorw("could not load address book, starting empty", "path", serverConfig.AddrBookFile, "error", err)
+		addrs = newAddrBook(serverConfig.AddrBookFile)
+	}
+
 	return &Node{
-		peers:        make(map[proto.NodeClient]*proto.PeerInfo),
+		peers:        make(map[NodeID]*peerConn),
+		persistent:   make(map[string]bool),
+		dialing:      make(map[string]bool),
 		logger:       logger.Sugar(),
-		mempool:      NewMempool(),
+		mempool:      NewMempoolWithConfig(serverConfig.Mempool),
+		orphans:      NewOrphanPool(),
+		inv:          newInventory(),
+		addrs:        addrs,
+		bans:         bans,
+		limiter:      newRateLimiter(serverConfig.RateLimits),
+		chain:        chain,
 		ServerConfig: serverConfig,
 	}
 }
 
 func (n *Node) Start(listenAddr string, bootstrapNodes []string) error {
 	n.ListenAddr = listenAddr
-	opts := []grpc.ServerOption{}
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(n.rateLimitUnary),
+		grpc.ChainStreamInterceptor(n.rateLimitStream),
+		grpc.KeepaliveParams(keepalive.ServerParameters{Time: keepaliveTime, Timeout: keepaliveTimeout}),
+		// peers ping as often as we do, anything faster is turned away
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: keepaliveTime / 2, PermitWithoutStream: true}),
+	}
+	if n.TLS != nil {
+		serverCreds, clientCreds, err := n.TLS.credentials()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, grpc.Creds(serverCreds))
+		n.clientCreds = clientCreds
+	}
 	grpcServer := grpc.NewServer(opts...)
 
 	ln, err := net.Listen("tcp", listenAddr)
@@ -111,12 +170,28 @@ func (n *Node) Start(listenAddr string, bootstrapNodes []string) error {
 	}
 
 	proto.RegisterNodeServer(grpcServer, n)
+	if n.AdminAddr != "" {
+		if err := n.serveAdmin(n.AdminAddr); err != nil {
+			ln.Close()
+			return err
+		}
+	}
 
 	n.logger.Info("node running on port", listenAddr)
+	n.peerLock.Lock()
+	for _, addr := range bootstrapNodes {
+		n.persistent[addr] = true
+	}
+	n.peerLock.Unlock()
+	n.addAddrs(bootstrapNodes...)
 	if len(bootstrapNodes) > 0 {
 		go n.bootstrapNetwork(bootstrapNodes)
 	}
 
+	go n.dialLoop()
+	go n.announceLoop()
+	go n.pingLoop()
+	go n.expireLoop()
 	if n.PrivKey != nil {
 		go n.validatorLoop()
 	}
@@ -125,7 +200,7 @@ func (n *Node) Start(listenAddr string, bootstrapNodes []string) error {
 }
 
 func (n *Node) canConnectWith(addr string) bool {
-	if n.ListenAddr == addr {
+	if n.ListenAddr == addr || n.bans.IsBanned(addr) {
 		return false
 	}
 
@@ -145,36 +220,70 @@ func (n *Node) bootstrapNetwork(addrs []string) error {
 		}
 		n.logger.Debugw("dialing remote node", "we", n.ListenAddr, "remote", addr)
 
-		client, peer, err := n.dialRemoteNode(addr)
-		if err != nil {
-			return err
+		if n.isPersistent(addr) {
+			go n.connectPersistent(addr)
+			continue
 		}
-		n.addPeer(client, peer)
+		go n.dialAddr(addr)
 	}
 
 	return nil
 }
 
-func (n *Node) dialRemoteNode(addr string) (proto.NodeClient, *proto.PeerInfo, error) {
-	client, err := MakeNodeClient(addr)
+func (n *Node) isPersistent(addr string) bool {
+	n.peerLock.RLock()
+	defer n.peerLock.RUnlock()
+
+	return n.persistent[addr]
+}
+
+// dialRemoteNode connects to the node at addr and opens the stream we talk
+// to it over, running the handshake on it.
+func (n *Node) dialRemoteNode(addr string) (*peerConn, error) {
+	client, err := n.makeNodeClient(addr)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	peer, err := client.Handshake(context.Background(), n.getPeerInfo())
-	if err != nil {
-		return nil, nil, err
+	conn := client.(*nodeClient).conn
+	ctx, cancel := context.WithCancel(context.Background())
+	closeConn := func() {
+		cancel()
+		conn.Close()
 	}
 
-	return client, peer, nil
+	stream, err := client.Connect(ctx)
+	if err != nil {
+		closeConn()
+		return nil, err
+	}
+	// the stream outlives the handshake, only the handshake has a deadline
+	timer := time.AfterFunc(peerSendTimeout, cancel)
+	id, peer, err := n.handshake(stream)
+	if !timer.Stop() && err == nil {
+		err = fmt.Errorf("%w: timed out", ErrHandshakeFailed)
+	}
+	if err == nil {
+		err = n.checkPeerList(addr, peer)
+	}
+	if err != nil {
+		closeConn()
+		return nil, err
+	}
 
+	p := n.newPeerConn(id, stream, peer)
+	p.onClose = closeConn
+	return p, nil
 }
 
 func (n *Node) getPeerInfo() *proto.PeerInfo {
 	return &proto.PeerInfo{
-		ProtocolVersion: 1,
-		BlockHeight:     0,
-		ListenAddr:      n.ListenAddr,
-		PeerList:        n.getPeerList(),
+		ProtocolVersion:    n.protocolVersion(),
+		MinProtocolVersion: MinProtocolVersion,
+		Services:           uint64(n.services()),
+		BlockHeight:        0,
+		ListenAddr:         n.ListenAddr,
+		PeerList:           n.getPeerList(),
+		PublicKey:          n.IdentityKey.Public().Bytes(),
 	}
 }
 
@@ -184,89 +293,510 @@ func (n *Node) getPeerList() []string {
 
 	peers := []string{}
 	for _, peer := range n.peers {
-		peers = append(peers, peer.ListenAddr)
+		// peers we can't dial, like ones behind a NAT, don't listen
+		if peer.info.ListenAddr != "" && len(peers) < maxGetAddrs {
+			peers = append(peers, peer.addr)
+		}
 	}
 
 	return peers
 }
 
-func (n *Node) addPeer(p proto.NodeClient, peerInfo *proto.PeerInfo) {
+// addPeer adds p, replacing the connection we had to the same peer before,
+// and starts talking to it. The peers it knows go into the address book, to
+// be dialed when we need more.
+func (n *Node) addPeer(p *peerConn) {
+	n.addAddrs(p.info.PeerList...)
+
 	n.peerLock.Lock()
 	defer n.peerLock.Unlock()
 
-	n.peers[p] = peerInfo
-
-	if len(peerInfo.PeerList) > 0 {
-		go n.bootstrapNetwork(peerInfo.PeerList)
+	if old, ok := n.peers[p.id]; ok {
+		old.close()
+		n.inv.forget(old.id)
 	}
+	n.peers[p.id] = p
+	go n.peerWorker(p)
+	go n.peerReader(p)
 
 	n.logger.Debugw(
 		"new peer successfully connected",
 		"we", n.ListenAddr,
-		"remoteNode", peerInfo.ListenAddr,
-		"height", peerInfo.BlockHeight,
+		"remoteNode", p.addr,
+		"id", p.id,
+		"inbound", p.inbound,
+		"version", p.version,
+		"services", p.services,
+		"height", p.info.BlockHeight,
 	)
 }
 
-func (n *Node) removePeer(p proto.NodeClient) {
+func (n *Node) removePeer(id NodeID) {
+	if p, ok := n.peerConn(id); ok {
+		n.dropPeer(p)
+	}
+}
+
+// dropPeer closes p and removes it, unless it was replaced by a newer
+// connection to the same peer already.
+func (n *Node) dropPeer(p *peerConn) {
 	n.peerLock.Lock()
 	defer n.peerLock.Unlock()
 
-	delete(n.peers, p)
+	p.close()
+	if n.peers[p.id] != p {
+		return
+	}
+	n.inv.forget(p.id)
+	delete(n.peers, p.id)
+	n.logger.Infow("peer disconnected", "remote", p.addr, "latency", time.Duration(p.latency.Load()),
+		"sent", p.sent.Load(), "failed", p.failed.Load(), "dropped", p.dropped.Load())
+
+	if n.persistent[p.addr] {
+		go n.connectPersistent(p.addr)
+	}
 }
 
-func (n *Node) Handshake(ctx context.Context, incomingPeerInfo *proto.PeerInfo) (*proto.PeerInfo, error) {
-	client, err := MakeNodeClient(incomingPeerInfo.ListenAddr)
-	if err != nil {
-		return nil, err
+// peerSnapshot returns the current peers, safe to range over while peers
+// come and go.
+func (n *Node) peerSnapshot() []*peerConn {
+	n.peerLock.RLock()
+	defer n.peerLock.RUnlock()
+
+	peers := make([]*peerConn, 0, len(n.peers))
+	for _, conn := range n.peers {
+		peers = append(peers, conn)
 	}
 
-	n.addPeer(client, incomingPeerInfo)
+	return peers
+}
+
+func (n *Node) peerConn(id NodeID) (*peerConn, bool) {
+	n.peerLock.RLock()
+	defer n.peerLock.RUnlock()
+
+	conn, ok := n.peers[id]
+	return conn, ok
+}
+
+func (n *Node) peersOnHost(host string) []*peerConn {
+	n.peerLock.RLock()
+	defer n.peerLock.RUnlock()
 
-	return n.getPeerInfo(), nil
+	var peers []*peerConn
+	for _, conn := range n.peers {
+		if addrHost(conn.addr) == host {
+			peers = append(peers, conn)
+		}
+	}
 
+	return peers
 }
 
 func (n *Node) HandleTransaction(ctx context.Context, tx *proto.Transaction) (*emptypb.Empty, error) {
-	peer, ok := peer.FromContext(ctx)
-	if !ok {
-		panic("Peer not found in context")
+	// by host, so a client can't pass for a new peer with every connection
+	from := "unknown"
+	if host, ok := remoteHost(ctx); ok {
+		from = host
 	}
 
-	if n.mempool.Add(tx) {
+	if err := n.processTransaction(tx, from); err != nil {
 		hash := hex.EncodeToString(types.HashTransaction(tx))
-		n.logger.Infow("received tx", "from", peer.Addr, "txHash", hash, "we", n.ListenAddr)
-		go func() {
-			if err := n.broadcast(tx); err != nil {
-				n.logger.Errorw("broadcast error", "error", err)
+		n.logger.Debugw("rejected tx", "from", from, "txHash", hash, "error", err, "we", n.ListenAddr)
+		return nil, toStatusError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// processTransaction admits tx to the mempool and relays it, or holds it in
+// the orphan pool while some of its parents are missing.
+func (n *Node) processTransaction(tx *proto.Transaction, from string) error {
+	hash := hex.EncodeToString(types.HashTransaction(tx))
+	if missing := n.missingParents(tx); len(missing) > 0 {
+		return n.addOrphan(tx, from, missing)
+	}
+
+	if err := n.acceptTransaction(tx); err != nil {
+		return err
+	}
+
+	n.logger.Infow("received tx", "from", from, "txHash", hash, "we", n.ListenAddr)
+	n.relay(tx)
+	n.processOrphans(hash)
+
+	return nil
+}
+
+// missingParents returns the hashes of the txs spent by tx that are neither
+// in the mempool nor on the chain.
+func (n *Node) missingParents(tx *proto.Transaction) []TXHash {
+	var missing []TXHash
+	seen := make(map[TXHash]bool)
+	for _, input := range tx.Inputs {
+		prevHash := hex.EncodeToString(input.PrevTxHash)
+		if seen[prevHash] {
+			continue
+		}
+		seen[prevHash] = true
+
+		if _, ok := n.mempool.Get(prevHash); ok {
+			continue
+		}
+		if _, _, err := n.chain.GetTransaction(prevHash); err == nil {
+			continue
+		}
+		// chains started from a snapshot only know the outputs
+		if n.chain.HasUTXO(utxoKey(prevHash, int(input.PrevOutIndex))) {
+			continue
+		}
+		missing = append(missing, prevHash)
+	}
+
+	return missing
+}
+
+// addOrphan holds tx in the orphan pool. Only the checks that do not need
+// the parents are run, so garbage is not kept around.
+func (n *Node) addOrphan(tx *proto.Transaction, from string, missing []TXHash) error {
+	if err := validateTransactionFormat(tx); err != nil {
+		return err
+	}
+	if !types.VerifyTransaction(tx) {
+		return ErrInvalidSignature
+	}
+	if err := n.orphans.Add(tx, from, missing); err != nil {
+		return err
+	}
+
+	n.logger.Debugw("holding orphan tx", "from", from, "txHash", hex.EncodeToString(types.HashTransaction(tx)), "missing", missing)
+	return nil
+}
+
+// processOrphans re-evaluates the orphans that were waiting for parent, and
+// in turn the orphans waiting for any of them that got accepted.
+func (n *Node) processOrphans(parent TXHash) {
+	queue := []TXHash{parent}
+	for len(queue) > 0 {
+		parent, queue = queue[0], queue[1:]
+
+		txs, peers := n.orphans.TakeChildren(parent)
+		for i, tx := range txs {
+			hash := hex.EncodeToString(types.HashTransaction(tx))
+			if missing := n.missingParents(tx); len(missing) > 0 {
+				if err := n.orphans.Add(tx, peers[i], missing); err != nil {
+					n.logger.Debugw("dropped orphan tx", "txHash", hash, "error", err)
+				}
+				continue
+			}
+
+			if err := n.acceptTransaction(tx); err != nil {
+				n.logger.Debugw("rejected orphan tx", "txHash", hash, "error", err)
+				continue
 			}
-		}()
+			n.relay(tx)
+			queue = append(queue, hash)
+		}
 	}
+}
+
+func (n *Node) HandleBlock(ctx context.Context, block *proto.Block) (*emptypb.Empty, error) {
+	from := "unknown"
+	if host, ok := remoteHost(ctx); ok {
+		from = host
+	}
+
+	if err := n.processBlock(block); err != nil {
+		n.logger.Debugw("rejected block", "from", from, "error", err, "we", n.ListenAddr)
+		return nil, toStatusError(err)
+	}
+
+	n.logger.Infow("received block", "from", from, "height", block.Header.Height, "txLen", len(block.Transactions), "we", n.ListenAddr)
+	n.relay(block)
 
 	return &emptypb.Empty{}, nil
 }
 
-func MakeNodeClient(targetAddr string) (proto.NodeClient, error) {
-	conn, err := grpc.NewClient(targetAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+// processBlock adds a block from a peer to the chain.
+func (n *Node) processBlock(block *proto.Block) error {
+	if block.Header != nil && n.chain.HasBlock(types.HashBlock(block)) {
+		return ErrDuplicateBlock
+	}
+	if err := n.chain.AddBlock(block); err != nil {
+		return err
+	}
+	n.blockConnected(block)
+
+	return nil
+}
+
+// DisconnectTip takes the tip block off the chain and puts its txs back into
+// the mempool.
+func (n *Node) DisconnectTip() (*proto.Block, error) {
+	block, err := n.chain.DisconnectTip()
 	if err != nil {
 		return nil, err
 	}
+	n.reinject(block.Transactions)
+
+	return block, nil
+}
+
+// reinject puts txs back in front of the mempool txs and runs all of them
+// through admission again against the chain tip. Whatever is no longer valid
+// is dropped.
+func (n *Node) reinject(txs []*proto.Transaction) {
+	pending := append(slices.Clone(txs), n.mempool.Clear()...)
+	for _, tx := range pending {
+		if err := n.acceptTransaction(tx); err != nil {
+			n.logger.Debugw("dropped mempool tx", "txHash", hex.EncodeToString(types.HashTransaction(tx)), "error", err)
+		}
+	}
+}
+
+// blockConnected is called after a block was added to the chain. Its txs
+// and the ones conflicting with them leave the mempool, and orphans waiting
+// for its txs get another chance.
+func (n *Node) blockConnected(block *proto.Block) {
+	n.mempool.Remove(block.Transactions)
+	for _, tx := range block.Transactions {
+		n.processOrphans(hex.EncodeToString(types.HashTransaction(tx)))
+	}
+}
+
+func (n *Node) expireLoop() {
+	ticker := time.NewTicker(expireInterval)
+
+	for {
+		<-ticker.C
+		n.expireTransactions()
+	}
+}
+
+// expireTransactions drops the mempool txs and orphans that waited too long,
+// even when no new tx comes in to push them out.
+func (n *Node) expireTransactions() {
+	if expired := n.mempool.Expire(); expired > 0 {
+		n.logger.Debugw("expired mempool txs", "count", expired)
+	}
+	if expired := n.orphans.Expire(); expired > 0 {
+		n.logger.Debugw("expired orphans", "count", expired)
+	}
+}
+
+// acceptTransaction runs the admission checks against the chain tip plus the
+// pending mempool spends, and adds tx to the mempool if it passes. Only txs
+// accepted here are ever relayed.
+func (n *Node) acceptTransaction(tx *proto.Transaction) error {
+	hash := hex.EncodeToString(types.HashTransaction(tx))
+	if n.mempool.Has(tx) {
+		return ErrDuplicateTx
+	}
+	if _, _, err := n.chain.GetTransaction(hash); err == nil {
+		return ErrDuplicateTx
+	}
+
+	if len(tx.Inputs) == 0 {
+		return ErrNoInputs
+	}
+
+	var (
+		conflicts   bool
+		unconfirmed = make(map[string]*UTXO)
+	)
+	for _, input := range tx.Inputs {
+		prevHash := hex.EncodeToString(input.PrevTxHash)
+		key := utxoKey(prevHash, int(input.PrevOutIndex))
+		// a tx spending the same output may replace the mempool one if it
+		// pays more, see Mempool.Replace
+		if _, ok := n.mempool.SpentBy(key); ok {
+			conflicts = true
+		}
+		if utxo, ok := n.mempool.Output(prevHash, int(input.PrevOutIndex)); ok {
+			unconfirmed[key] = utxo
+		}
+	}
+
+	fee, err := n.chain.checkTransaction(tx, unconfirmed)
+	if err != nil {
+		return err
+	}
+	rate, minRate := feeRate(fee, pb.Size(tx)), n.mempool.MinFeeRate()
+	if rate < minRate {
+		return fmt.Errorf("%w: fee rate (%d) minimum (%d)", ErrInsufficientFee, rate, minRate)
+	}
+
+	if !conflicts {
+		return n.mempool.Add(tx, fee)
+	}
+
+	replaced, err := n.mempool.Replace(tx, fee)
+	if err != nil {
+		return err
+	}
+	n.logger.Infow("replaced mempool txs", "txHash", hash, "replaced", replaced, "we", n.ListenAddr)
 
-	return proto.NewNodeClient(conn), nil
+	return nil
+}
+
+func (n *Node) GetTransaction(ctx context.Context, req *proto.GetTransactionRequest) (*proto.TransactionInfo, error) {
+	hash := hex.EncodeToString(req.Hash)
+
+	if tx, location, err := n.chain.GetTransaction(hash); err == nil {
+		blockHash, err := hex.DecodeString(location.BlockHash)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		return &proto.TransactionInfo{
+			Transaction:   tx,
+			Confirmed:     true,
+			BlockHash:     blockHash,
+			BlockHeight:   int32(location.Height),
+			Index:         int32(location.Index),
+			Confirmations: int32(n.chain.Confirmations(location)),
+		}, nil
+	}
+
+	if tx, ok := n.mempool.Get(hash); ok {
+		return &proto.TransactionInfo{
+			Transaction: tx,
+			InMempool:   true,
+		}, nil
+	}
+
+	return nil, status.Errorf(codes.NotFound, "transaction %s not found", hash)
+}
+
+func (n *Node) GetUTXOProof(ctx context.Context, req *proto.UTXOProofRequest) (*proto.UTXOProof, error) {
+	if !n.services().Has(ServiceLightServer) {
+		return nil, status.Error(codes.Unimplemented, "node does not serve light clients")
+	}
+	key := utxoKey(hex.EncodeToString(req.TxHash), int(req.OutIndex))
+	proof, err := n.chain.ProveUTXO(key, int(req.Height))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	return &proto.UTXOProof{
+		Utxo: &proto.UTXO{
+			Hash:     req.TxHash,
+			OutIndex: req.OutIndex,
+			Amount:   proof.UTXO.Amount,
+			Address:  proof.UTXO.Address,
+			Height:   int32(proof.UTXO.Height),
+		},
+		Header:   proof.Header,
+		Siblings: proof.Siblings,
+	}, nil
 }
 
-func (n *Node) broadcast(msg any) error {
-	for peer := range n.peers {
-		switch v := msg.(type) {
-		case *proto.Transaction:
-			_, err := peer.HandleTransaction(context.Background(), v)
+func (n *Node) ListMempool(ctx context.Context, _ *emptypb.Empty) (*proto.MempoolList, error) {
+	entries := n.mempool.Entries()
+	list := &proto.MempoolList{Entries: make([]*proto.MempoolEntry, len(entries))}
+	for i, entry := range entries {
+		list.Entries[i] = mempoolEntryProto(entry)
+	}
+
+	return list, nil
+}
+
+func (n *Node) GetMempoolEntry(ctx context.Context, req *proto.GetTransactionRequest) (*proto.MempoolEntry, error) {
+	hash := hex.EncodeToString(req.Hash)
+	entry, ok := n.mempool.Entry(hash)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "transaction %s not in mempool", hash)
+	}
+
+	info := mempoolEntryProto(entry)
+	info.Transaction = entry.Tx
+	return info, nil
+}
+
+func (n *Node) GetMempoolStats(ctx context.Context, _ *emptypb.Empty) (*proto.MempoolStats, error) {
+	stats := n.mempool.Stats()
+	info := &proto.MempoolStats{
+		Count:      int32(stats.Count),
+		Size:       int64(stats.Size),
+		MinFeeRate: stats.MinFeeRate,
+		Histogram:  make([]*proto.FeeRateBucket, len(stats.Histogram)),
+	}
+	for i, bucket := range stats.Histogram {
+		info.Histogram[i] = &proto.FeeRateBucket{
+			MinFeeRate: bucket.MinFeeRate,
+			Count:      int32(bucket.Count),
+			Size:       int64(bucket.Size),
+		}
+	}
+
+	return info, nil
+}
+
+// SubscribeMempool streams mempool additions and removals until the client
+// goes away or falls too far behind.
+func (n *Node) SubscribeMempool(_ *emptypb.Empty, stream grpc.ServerStreamingServer[proto.MempoolEvent]) error {
+	events, unsubscribe := n.mempool.Subscribe()
+	defer unsubscribe()
+
+	// let the client know it is subscribed before the first event
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "mempool subscriber fell behind")
+			}
+			err := stream.Send(&proto.MempoolEvent{
+				Removed: ev.Removed,
+				Reason:  proto.RemovalReason(ev.Reason),
+				Entry:   mempoolEntryProto(ev.Entry),
+			})
 			if err != nil {
 				return err
 			}
 		}
 	}
+}
 
-	return nil
+func mempoolEntryProto(entry *MempoolEntry) *proto.MempoolEntry {
+	return &proto.MempoolEntry{
+		Hash:    types.HashTransaction(entry.Tx),
+		Fee:     entry.Fee,
+		Size:    int32(entry.Size),
+		FeeRate: entry.FeeRate(),
+		Added:   entry.Added.Unix(),
+	}
+}
+
+func MakeNodeClient(targetAddr string) (proto.NodeClient, error) {
+	return MakeNodeClientWithCredentials(targetAddr, insecure.NewCredentials())
+}
+
+// MakeNodeClientWithCredentials connects to the node at targetAddr over a
+// transport secured by creds, like the TLS ones of a TLSConfig.
+func MakeNodeClientWithCredentials(targetAddr string, creds credentials.TransportCredentials) (proto.NodeClient, error) {
+	conn, err := grpc.NewClient(targetAddr,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{Time: keepaliveTime, Timeout: keepaliveTimeout, PermitWithoutStream: true}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &nodeClient{NodeClient: proto.NewNodeClient(conn), conn: conn}, nil
+}
+
+// makeNodeClient connects to a peer with the transport security we were
+// configured with.
+func (n *Node) makeNodeClient(targetAddr string) (proto.NodeClient, error) {
+	if n.clientCreds == nil {
+		return MakeNodeClient(targetAddr)
+	}
+	return MakeNodeClientWithCredentials(targetAddr, n.clientCreds)
 }
 
 func (n *Node) validatorLoop() {
@@ -275,7 +805,32 @@ func (n *Node) validatorLoop() {
 
 	for {
 		<-ticker.C
-		txs := n.mempool.Clear()
-		n.logger.Infow("time to create a new block", "txLen", len(txs))
+		block, err := n.createBlock()
+		if err != nil {
+			n.logger.Errorw("failed to create block", "error", err)
+			continue
+		}
+		n.logger.Infow("new block", "height", block.Header.Height, "txLen", len(block.Transactions))
 	}
 }
+
+// createBlock fills a block signed by this validator with the best paying
+// mempool txs and adds it to the chain.
+func (n *Node) createBlock() (*proto.Block, error) {
+	n.expireTransactions()
+	txs := n.mempool.SelectTransactions(MaxBlockSize-blockReserve, MaxBlockTxs)
+	block, err := n.chain.NewBlockTemplate(txs)
+	if err == nil {
+		types.SignBlock(n.PrivKey, block)
+		err = n.chain.AddBlock(block)
+	}
+	if err != nil {
+		// some mempool tx is no longer valid, don't build on it again
+		n.reinject(nil)
+		return nil, err
+	}
+	n.blockConnected(block)
+	n.relay(block)
+
+	return block, nil
+}
@@ -0,0 +1,164 @@
+package node
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pdrm26/blocker/proto"
+	"github.com/pdrm26/blocker/types"
+)
+
+const (
+	maxOrphans        = 100
+	maxOrphansPerHost = 10
+	orphanTTL         = 20 * time.Minute
+)
+
+type orphan struct {
+	tx      *proto.Transaction
+	hash    TXHash
+	peer    string
+	missing []TXHash
+	expires time.Time
+}
+
+// OrphanPool holds transactions whose parents we have not seen yet, keyed by
+// the missing parents, until the parents show up or the orphans expire. The
+// orphans are counted against the host of the peer that sent them, so a peer
+// can't get more room by connecting again from another port.
+type OrphanPool struct {
+	lock     sync.Mutex
+	orphans  map[TXHash]*orphan
+	byParent map[TXHash]map[TXHash]struct{}
+	perHost  map[string]int
+}
+
+func NewOrphanPool() *OrphanPool {
+	return &OrphanPool{
+		orphans:  make(map[TXHash]*orphan),
+		byParent: make(map[TXHash]map[TXHash]struct{}),
+		perHost:  make(map[string]int),
+	}
+}
+
+func (p *OrphanPool) Len() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return len(p.orphans)
+}
+
+func (p *OrphanPool) Has(hash TXHash) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	_, ok := p.orphans[hash]
+	return ok
+}
+
+// Add holds tx until the missing parents arrive. The peers on a host may
+// only have maxOrphansPerHost orphans at a time; once the pool itself is full
+// the orphan closest to expiry makes room.
+func (p *OrphanPool) Add(tx *proto.Transaction, peer string, missing []TXHash) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+	p.expire(now)
+
+	hash := hex.EncodeToString(types.HashTransaction(tx))
+	if _, ok := p.orphans[hash]; ok {
+		return ErrDuplicateTx
+	}
+	host := addrHost(peer)
+	if p.perHost[host] >= maxOrphansPerHost {
+		return fmt.Errorf("%w: host %s holds (%d)", ErrTooManyOrphans, host, p.perHost[host])
+	}
+
+	if len(p.orphans) >= maxOrphans {
+		var oldest *orphan
+		for _, o := range p.orphans {
+			if oldest == nil || o.expires.Before(oldest.expires) {
+				oldest = o
+			}
+		}
+		p.remove(oldest)
+	}
+
+	o := &orphan{
+		tx:      tx,
+		hash:    hash,
+		peer:    peer,
+		missing: missing,
+		expires: now.Add(orphanTTL),
+	}
+	p.orphans[hash] = o
+	p.perHost[host]++
+	for _, parent := range missing {
+		if p.byParent[parent] == nil {
+			p.byParent[parent] = make(map[TXHash]struct{})
+		}
+		p.byParent[parent][hash] = struct{}{}
+	}
+
+	return nil
+}
+
+// TakeChildren removes and returns the orphans waiting for parent, together
+// with the peers that sent them.
+func (p *OrphanPool) TakeChildren(parent TXHash) ([]*proto.Transaction, []string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var (
+		txs   []*proto.Transaction
+		peers []string
+	)
+	for hash := range p.byParent[parent] {
+		o := p.orphans[hash]
+		txs = append(txs, o.tx)
+		peers = append(peers, o.peer)
+		p.remove(o)
+	}
+
+	return txs, peers
+}
+
+// Expire drops orphans that have waited too long and returns how many.
+func (p *OrphanPool) Expire() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.expire(time.Now())
+}
+
+func (p *OrphanPool) expire(now time.Time) int {
+	expired := 0
+	for _, o := range p.orphans {
+		if now.After(o.expires) {
+			p.remove(o)
+			expired++
+		}
+	}
+
+	return expired
+}
+
+func (p *OrphanPool) remove(o *orphan) {
+	delete(p.orphans, o.hash)
+
+	host := addrHost(o.peer)
+	p.perHost[host]--
+	if p.perHost[host] <= 0 {
+		delete(p.perHost, host)
+	}
+
+	for _, parent := range o.missing {
+		delete(p.byParent[parent], o.hash)
+		if len(p.byParent[parent]) == 0 {
+			delete(p.byParent, parent)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pdrm26/blocker/crypto"
+	"github.com/pdrm26/blocker/proto"
+	"github.com/pdrm26/blocker/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// orphanTX returns a tx spending output n of a tx nobody has seen.
+func orphanTX(t *testing.T, privKey *crypto.PrivateKey, n int) *proto.Transaction {
+	prevTx := &proto.Transaction{
+		Version: 1,
+		Outputs: []*proto.TxOutput{{Amount: 100, Address: privKey.Public().Address().Bytes()}},
+	}
+	return spendOutput(t, privKey, prevTx, n, 90)
+}
+
+func TestOrphanPoolPerPeerLimit(t *testing.T) {
+	var (
+		pool    = NewOrphanPool()
+		privKey = crypto.NewPrivateKey()
+	)
+
+	for i := 0; i < maxOrphansPerHost; i++ {
+		assert.Nil(t, pool.Add(orphanTX(t, privKey, i), "peer1", []TXHash{"parent"}))
+	}
+	tx := orphanTX(t, privKey, maxOrphansPerHost)
+	assert.ErrorIs(t, pool.Add(tx, "peer1", []TXHash{"parent"}), ErrTooManyOrphans)
+	assert.Nil(t, pool.Add(tx, "peer2", []TXHash{"parent"}))
+	assert.ErrorIs(t, pool.Add(tx, "peer2", []TXHash{"parent"}), ErrDuplicateTx)
+	assert.Equal(t, maxOrphansPerHost+1, pool.Len())
+}
+
+func TestOrphanPoolLimitsPeersOnAHost(t *testing.T) {
+	var (
+		pool    = NewOrphanPool()
+		privKey = crypto.NewPrivateKey()
+	)
+
+	// stream peers are known by the port they listen on, another claim is
+	// still the same host
+	for i := 0; i < maxOrphansPerHost; i++ {
+		assert.Nil(t, pool.Add(orphanTX(t, privKey, i), fmt.Sprintf("10.0.0.1:%d", 4000+i), []TXHash{"parent"}))
+	}
+	tx := orphanTX(t, privKey, maxOrphansPerHost)
+	assert.ErrorIs(t, pool.Add(tx, "10.0.0.1:5000", []TXHash{"parent"}), ErrTooManyOrphans)
+	assert.Nil(t, pool.Add(tx, "10.0.0.2:5000", []TXHash{"parent"}))
+
+	txs, peers := pool.TakeChildren("parent")
+	assert.Len(t, txs, maxOrphansPerHost+1)
+	assert.Contains(t, peers, "10.0.0.1:4000")
+	assert.Empty(t, pool.perHost)
+}
+
+func TestOrphanLimitPerHost(t *testing.T) {
+	var (
+		n       = NewNode(ServerConfig{ListenAddr: "a"})
+		privKey = crypto.NewPrivateKey()
+	)
+
+	// a new connection for every call still counts as the same sender
+	for i := 0; i < maxOrphansPerHost; i++ {
+		_, err := n.HandleTransaction(callerContext(fmt.Sprintf("10.0.0.1:%d", 4000+i)), orphanTX(t, privKey, i))
+		assert.Nil(t, err)
+	}
+	_, err := n.HandleTransaction(callerContext("10.0.0.1:5000"), orphanTX(t, privKey, maxOrphansPerHost))
+	assert.Equal(t, "TOO_MANY_ORPHANS", RejectReason(err))
+
+	_, err = n.HandleTransaction(callerContext("10.0.0.2:4000"), orphanTX(t, privKey, maxOrphansPerHost))
+	assert.Nil(t, err)
+}
+
+func TestOrphanPoolEvictsWhenFull(t *testing.T) {
+	var (
+		pool    = NewOrphanPool()
+		privKey = crypto.NewPrivateKey()
+		first   TXHash
+	)
+
+	for i := 0; i < maxOrphans; i++ {
+		tx := orphanTX(t, privKey, i)
+		if i == 0 {
+			first = hashOf(tx)
+		}
+		peer := string(rune('a' + i/maxOrphansPerHost))
+		assert.Nil(t, pool.Add(tx, peer, []TXHash{"parent"}))
+	}
+
+	tx := orphanTX(t, privKey, maxOrphans)
+	assert.Nil(t, pool.Add(tx, "late", []TXHash{"parent"}))
+	assert.Equal(t, maxOrphans, pool.Len())
+	assert.False(t, pool.Has(first))
+	assert.True(t, pool.Has(hashOf(tx)))
+}
+
+func TestOrphanPoolTakeChildren(t *testing.T) {
+	var (
+		pool    = NewOrphanPool()
+		privKey = crypto.NewPrivateKey()
+		tx1     = orphanTX(t, privKey, 0)
+		tx2     = orphanTX(t, privKey, 1)
+	)
+
+	assert.Nil(t, pool.Add(tx1, "peer1", []TXHash{"a", "b"}))
+	assert.Nil(t, pool.Add(tx2, "peer2", []TXHash{"b"}))
+
+	txs, peers := pool.TakeChildren("a")
+	assert.Equal(t, []*proto.Transaction{tx1}, txs)
+	assert.Equal(t, []string{"peer1"}, peers)
+
+	// tx1 is gone from every parent it was waiting for
+	txs, _ = pool.TakeChildren("b")
+	assert.Equal(t, []*proto.Transaction{tx2}, txs)
+	assert.Equal(t, 0, pool.Len())
+	assert.Empty(t, pool.byParent)
+	assert.Empty(t, pool.perHost)
+}
+
+func TestOrphanPoolExpire(t *testing.T) {
+	var (
+		pool    = NewOrphanPool()
+		privKey = crypto.NewPrivateKey()
+		stale   = orphanTX(t, privKey, 0)
+	)
+
+	assert.Nil(t, pool.Add(stale, "peer1", []TXHash{"parent"}))
+	assert.Nil(t, pool.Add(orphanTX(t, privKey, 1), "peer1", []TXHash{"parent"}))
+	pool.orphans[hashOf(stale)].expires = time.Now().Add(-time.Second)
+
+	assert.Equal(t, 1, pool.Expire())
+	assert.Equal(t, 1, pool.Len())
+	assert.False(t, pool.Has(hashOf(stale)))
+}
+
+func TestNodeProcessesOrphansFromMempool(t *testing.T) {
+	var (
+		n          = NewNode(ServerConfig{})
+		privKey    = genesisPrivKey(t)
+		parent     = spendOutput(t, privKey, genesisTX(t, n.chain), 0, 990)
+		child      = spendOutput(t, privKey, parent, 0, 980)
+		grandchild = spendOutput(t, privKey, child, 0, 970)
+	)
+
+	assert.Nil(t, n.processTransaction(grandchild, "peer1"))
+	assert.Nil(t, n.processTransaction(child, "peer1"))
+	assert.Equal(t, 2, n.orphans.Len())
+	assert.Equal(t, 0, n.mempool.Len())
+
+	unsigned := spendOutput(t, privKey, child, 0, 960)
+	unsigned.Inputs[0].Signature = nil
+	assert.ErrorIs(t, n.processTransaction(unsigned, "peer1"), ErrInvalidSignature)
+
+	assert.Nil(t, n.processTransaction(parent, "peer2"))
+	assert.Equal(t, 0, n.orphans.Len())
+	assert.Equal(t, 3, n.mempool.Len())
+	assert.True(t, n.mempool.Has(grandchild))
+}
+
+func TestNodeProcessesOrphansFromBlock(t *testing.T) {
+	var (
+		n       = NewNode(ServerConfig{PrivKey: crypto.NewPrivateKey()})
+		privKey = genesisPrivKey(t)
+		parent  = spendOutput(t, privKey, genesisTX(t, n.chain), 0, 990)
+		child   = spendOutput(t, privKey, parent, 0, 980)
+	)
+
+	assert.Nil(t, n.processTransaction(child, "peer1"))
+	assert.Equal(t, 1, n.orphans.Len())
+
+	block, err := n.chain.NewBlockTemplate([]*proto.Transaction{parent})
+	assert.Nil(t, err)
+	types.SignBlock(crypto.NewPrivateKey(), block)
+	assert.Nil(t, n.chain.AddBlock(block))
+	n.blockConnected(block)
+
+	assert.Equal(t, 0, n.orphans.Len())
+	assert.True(t, n.mempool.Has(child))
+}
@@ -0,0 +1,388 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pdrm26/blocker/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// peerQueueSize is how many outbound messages may wait for a peer
+	// before new ones are dropped.
+	peerQueueSize = 256
+	// peerSendTimeout bounds the handshake and every request we make to a
+	// peer.
+	peerSendTimeout = 5 * time.Second
+	// maxPeerFailures is how many requests in a row may fail before the
+	// peer is dropped.
+	maxPeerFailures = 5
+
+	// pingInterval is how often every peer gets pinged.
+	pingInterval = 30 * time.Second
+	// keepaliveTime is how long a connection may sit idle before gRPC pings
+	// it, keepaliveTimeout how long that ping may go unanswered before the
+	// connection is closed. A dead connection then ends its streams even
+	// when we have nothing to send.
+	keepaliveTime    = pingInterval
+	keepaliveTimeout = peerSendTimeout
+
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 5 * time.Minute
+)
+
+// peerStream is the stream a peer connection runs over, our end of Connect
+// whether we dialed the peer or it dialed us.
+type peerStream interface {
+	Send(*proto.Envelope) error
+	Recv() (*proto.Envelope, error)
+	Context() context.Context
+}
+
+// peerConn is our side of the connection to a peer. Outbound messages are
+// queued and written to the stream by a worker of their own, so a slow or
+// dead peer only holds up itself.
+type peerConn struct {
+	id     NodeID
+	stream peerStream
+	// addr is the address the peer is known by, the one we dialed or see
+	// peerAddr. Bans go by it, inventory by id.
+	addr string
+	info *proto.PeerInfo
+	// inbound is set for peers that dialed us.
+	inbound bool
+	// version is the protocol version we agreed on, services what the
+	// peer offers.
+	version  int32
+	services Services
+	queue    chan *proto.Envelope
+	quit     chan struct{}
+	once     sync.Once
+	// onClose tears down the stream. Streams of peers that dialed us end
+	// when Connect returns, once quit is closed.
+	onClose func()
+	// stopped is closed once the worker no longer starts writes to the
+	// stream. A write it gave up on is left to fail with the stream.
+	stopped chan struct{}
+
+	// requests are the ones waiting for a response, by id.
+	reqLock  sync.Mutex
+	nextID   uint64
+	requests map[uint64]chan *proto.Envelope
+
+	// failures counts the requests that failed in a row.
+	failures atomic.Int32
+	sent     atomic.Int64
+	failed   atomic.Int64
+	dropped  atomic.Int64
+	// latency is the round trip time of the last ping.
+	latency atomic.Int64
+}
+
+// stats returns what p has done so far, for the Admin service.
+func (p *peerConn) stats() *proto.PeerStats {
+	return &proto.PeerStats{
+		Id:      string(p.id),
+		Addr:    p.addr,
+		Inbound: p.inbound,
+		Version: p.version,
+		Latency: p.latency.Load(),
+		Sent:    p.sent.Load(),
+		Failed:  p.failed.Load(),
+		Dropped: p.dropped.Load(),
+	}
+}
+
+// newPeerConn makes the connection to the peer with the given id over
+// stream, using the version we agreed on in the handshake.
+func (n *Node) newPeerConn(id NodeID, stream peerStream, info *proto.PeerInfo) *peerConn {
+	p := &peerConn{
+		id:       id,
+		stream:   stream,
+		addr:     info.ListenAddr,
+		info:     info,
+		services: Services(info.Services),
+		queue:    make(chan *proto.Envelope, peerQueueSize),
+		quit:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		requests: make(map[uint64]chan *proto.Envelope),
+	}
+	// peers we could not agree with never get here, see the handshake
+	p.version, _ = n.negotiateVersion(info)
+
+	return p
+}
+
+// send queues env for the peer. A full queue drops env and returns false.
+func (p *peerConn) send(env *proto.Envelope) bool {
+	select {
+	case p.queue <- env:
+		return true
+	default:
+		p.dropped.Add(1)
+		return false
+	}
+}
+
+func (p *peerConn) close() {
+	p.once.Do(func() {
+		close(p.quit)
+		if p.onClose != nil {
+			p.onClose()
+		}
+	})
+}
+
+// request sends req and waits for the response to it.
+func (p *peerConn) request(req *proto.Envelope) (*proto.Envelope, error) {
+	resp := make(chan *proto.Envelope, 1)
+	p.reqLock.Lock()
+	p.nextID++
+	req.Id = p.nextID
+	p.requests[req.Id] = resp
+	p.reqLock.Unlock()
+
+	defer func() {
+		p.reqLock.Lock()
+		delete(p.requests, req.Id)
+		p.reqLock.Unlock()
+	}()
+
+	if !p.send(req) {
+		return nil, status.Error(codes.ResourceExhausted, "peer queue full")
+	}
+
+	timer := time.NewTimer(peerSendTimeout)
+	defer timer.Stop()
+
+	select {
+	case env := <-resp:
+		if env.Error != nil {
+			return nil, status.Error(codes.Code(env.Error.Code), env.Error.Message)
+		}
+		return env, nil
+	case <-timer.C:
+		return nil, status.Error(codes.DeadlineExceeded, "peer did not respond")
+	case <-p.quit:
+		return nil, status.Error(codes.Unavailable, "peer disconnected")
+	}
+}
+
+// resolve hands a response to the request waiting for it. Responses that
+// come too late are dropped.
+func (p *peerConn) resolve(env *proto.Envelope) {
+	p.reqLock.Lock()
+	resp, ok := p.requests[env.Id]
+	delete(p.requests, env.Id)
+	p.reqLock.Unlock()
+
+	if ok {
+		resp <- env
+	}
+}
+
+// respond queues the response to the request with the given id, or the
+// error it failed with.
+func (p *peerConn) respond(id uint64, resp *proto.Envelope, err error) {
+	if err != nil {
+		st, _ := status.FromError(toStatusError(err))
+		resp = &proto.Envelope{Error: &proto.PeerError{Code: uint32(st.Code()), Message: st.Message()}}
+	}
+	resp.Id = id
+	resp.Response = true
+	p.send(resp)
+}
+
+// peerWorker writes the messages queued for p to its stream until p is
+// closed. The stream is broken once a write fails or takes longer than
+// peerSendTimeout, so p is dropped. Writes run in a goroutine of their own,
+// so a peer that stopped reading can't keep the worker from seeing quit.
+func (n *Node) peerWorker(p *peerConn) {
+	defer close(p.stopped)
+
+	var (
+		sends   = make(chan *proto.Envelope)
+		results = make(chan error, 1)
+	)
+	// a write still stuck once we are done fails when the stream ends
+	defer close(sends)
+	go func() {
+		for env := range sends {
+			err := p.stream.Send(env)
+			if err == nil {
+				p.sent.Add(1)
+			}
+			results <- err
+		}
+	}()
+
+	for {
+		select {
+		case <-p.quit:
+			return
+		case env := <-p.queue:
+			sends <- env
+			if err := p.awaitSend(results); err != nil {
+				n.logger.Debugw("send to peer failed", "remote", p.addr, "error", err)
+				n.dropPeer(p)
+				return
+			}
+		}
+	}
+}
+
+// awaitSend waits for the write under way to p to finish, for at most
+// peerSendTimeout.
+func (p *peerConn) awaitSend(results <-chan error) error {
+	timer := time.NewTimer(peerSendTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-results:
+		return err
+	case <-timer.C:
+		return status.Error(codes.DeadlineExceeded, "peer did not take the message")
+	case <-p.quit:
+		return status.Error(codes.Unavailable, "peer disconnected")
+	}
+}
+
+// peerReader handles what p sends until its stream ends.
+func (n *Node) peerReader(p *peerConn) {
+	for {
+		env, err := p.stream.Recv()
+		if err != nil {
+			n.logger.Debugw("peer stream ended", "remote", p.addr, "error", err)
+			n.dropPeer(p)
+			return
+		}
+		n.handleEnvelope(p, env)
+	}
+}
+
+// peerResult counts the outcome of a request to p, and drops p once its
+// requests kept failing for maxPeerFailures in a row.
+func (n *Node) peerResult(p *peerConn, err error) {
+	if err == nil {
+		p.failures.Store(0)
+		return
+	}
+
+	p.failed.Add(1)
+	if status.Code(err) == codes.DeadlineExceeded {
+		n.misbehaving(p.addr, misbehaviorSlow)
+	}
+	if p.failures.Add(1) >= maxPeerFailures {
+		n.logger.Infow("dropping failing peer", "remote", p.addr, "failures", p.failures.Load())
+		n.dropPeer(p)
+	}
+}
+
+// peerRequest sends req to p and waits for the response, and counts the
+// outcome against p.
+func (n *Node) peerRequest(p *peerConn, req *proto.Envelope) (*proto.Envelope, error) {
+	resp, err := p.request(req)
+	n.peerResult(p, err)
+	return resp, err
+}
+
+// nodeClient is a NodeClient that keeps its connection around, so it can be
+// closed.
+type nodeClient struct {
+	proto.NodeClient
+	conn *grpc.ClientConn
+}
+
+func (n *Node) pingLoop() {
+	ticker := time.NewTicker(pingInterval)
+
+	for {
+		<-ticker.C
+		for _, p := range n.peerSnapshot() {
+			go n.ping(p)
+		}
+	}
+}
+
+// ping measures the round trip time to p. Unanswered pings count as
+// failures, so a peer that stopped responding gets dropped.
+func (n *Node) ping(p *peerConn) error {
+	nonce := rand.Uint64()
+	start := time.Now()
+
+	resp, err := n.peerRequest(p, &proto.Envelope{Payload: &proto.Envelope_Ping{
+		Ping: &proto.PingMessage{Nonce: nonce, Timestamp: start.UnixNano()},
+	}})
+	if err != nil {
+		return err
+	}
+	if pong := resp.GetPong(); pong == nil || pong.Nonce != nonce {
+		n.misbehaving(p.addr, misbehaviorProtocol)
+		return fmt.Errorf("peer answered ping (%d) with %v", nonce, resp.Payload)
+	}
+	p.latency.Store(int64(time.Since(start)))
+
+	return nil
+}
+
+// reconnectDelay returns how long to wait before the given reconnect
+// attempt, doubling from minReconnectDelay up to maxReconnectDelay.
+func reconnectDelay(attempt int) time.Duration {
+	delay := minReconnectDelay
+	for i := 0; i < attempt && delay < maxReconnectDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxReconnectDelay)
+}
+
+// connectPersistent dials addr until it is connected, backing off between
+// the attempts. Only one loop runs per address.
+func (n *Node) connectPersistent(addr string) {
+	n.peerLock.Lock()
+	if n.dialing[addr] {
+		n.peerLock.Unlock()
+		return
+	}
+	n.dialing[addr] = true
+	n.peerLock.Unlock()
+
+	defer func() {
+		n.peerLock.Lock()
+		delete(n.dialing, addr)
+		n.peerLock.Unlock()
+	}()
+
+	for attempt := 0; ; attempt++ {
+		// try again once the ban is over
+		if until, ok := n.bans.BannedUntil(addr); ok {
+			time.Sleep(time.Until(until))
+			continue
+		}
+		if !n.canConnectWith(addr) {
+			return
+		}
+
+		n.addrs.Attempt(addr)
+		p, err := n.dialRemoteNode(addr)
+		if err == nil {
+			n.addrs.Good(addr)
+			n.addPeer(p)
+			return
+		}
+		n.addrs.Failed(addr)
+
+		delay := reconnectDelay(attempt)
+		// spread out the retries of nodes that lost the same peer
+		delay += rand.N(delay / 5)
+		n.logger.Debugw("dial failed, retrying", "remote", addr, "error", err, "in", delay)
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,223 @@
+package node
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pdrm26/blocker/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// stuckStream never takes a message until release is closed, like a peer
+// that stopped reading.
+type stuckStream struct {
+	*fakeStream
+	release chan struct{}
+}
+
+func newStuckStream() *stuckStream {
+	s, _ := newPipe()
+	return &stuckStream{fakeStream: s, release: make(chan struct{})}
+}
+
+func (s *stuckStream) Send(env *proto.Envelope) error {
+	select {
+	case <-s.release:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// brokenStream fails every send.
+type brokenStream struct {
+	*fakeStream
+}
+
+func (s *brokenStream) Send(*proto.Envelope) error {
+	return errors.New("connection reset")
+}
+
+// answerWithErrors answers every request that comes out of s with an error.
+func answerWithErrors(s *fakeStream) {
+	for {
+		env, err := s.Recv()
+		if err != nil {
+			return
+		}
+		s.Send(&proto.Envelope{Id: env.Id, Response: true, Error: &proto.PeerError{Code: uint32(codes.Internal), Message: "broken"}})
+	}
+}
+
+func TestPeerQueueDropsWhenFull(t *testing.T) {
+	var (
+		n     = NewNode(ServerConfig{ListenAddr: "a"})
+		stuck = newStuckStream()
+	)
+	defer close(stuck.release)
+	conn := addStream(n, "stuck", stuck, &proto.PeerInfo{ListenAddr: "stuck"})
+
+	// one is taken by the worker, peerQueueSize wait behind it
+	accepted := 0
+	for i := 0; i < peerQueueSize+10; i++ {
+		if conn.send(&proto.Envelope{}) {
+			accepted++
+		}
+	}
+	assert.LessOrEqual(t, accepted, peerQueueSize+1)
+	assert.GreaterOrEqual(t, conn.dropped.Load(), int64(9))
+}
+
+func TestSlowPeerDoesNotBlockOthers(t *testing.T) {
+	var (
+		a       = NewNode(ServerConfig{ListenAddr: "a"})
+		b       = NewNode(ServerConfig{ListenAddr: "b"})
+		stuck   = newStuckStream()
+		privKey = genesisPrivKey(t)
+		tx      = spendOutput(t, privKey, genesisTX(t, a.chain), 0, 990)
+	)
+	defer close(stuck.release)
+	addStream(a, "stuck", stuck, &proto.PeerInfo{ListenAddr: "stuck"})
+	connect(a, b)
+
+	assert.Nil(t, a.processTransaction(tx, "client"))
+	a.flushInventory()
+
+	assert.Eventually(t, func() bool { return b.mempool.Has(tx) }, time.Second, 10*time.Millisecond)
+}
+
+func TestBrokenStreamIsDropped(t *testing.T) {
+	var (
+		n       = NewNode(ServerConfig{ListenAddr: "a"})
+		s, _    = newPipe()
+		conn    = addStream(n, "broken", &brokenStream{s}, &proto.PeerInfo{ListenAddr: "broken"})
+		dropped = func() bool {
+			_, ok := n.peerConn("broken")
+			return !ok
+		}
+	)
+
+	assert.True(t, conn.send(&proto.Envelope{}))
+	assert.Eventually(t, dropped, time.Second, 10*time.Millisecond)
+	assert.Empty(t, n.getPeerList())
+}
+
+func TestClosedStreamIsDropped(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{ListenAddr: "a"})
+		b = NewNode(ServerConfig{ListenAddr: "b"})
+	)
+	toB, _ := connect(a, b)
+
+	toB.cancel()
+	assert.Eventually(t, func() bool {
+		return len(a.getPeerList()) == 0 && len(b.getPeerList()) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestReplacedPeerStays(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{ListenAddr: "a"})
+		b = NewNode(ServerConfig{ListenAddr: "b"})
+	)
+	old, _ := newPipe()
+	addStream(a, b.ID(), old, b.getPeerInfo())
+	connect(a, b)
+
+	// the old stream ending doesn't take the new one with it
+	time.Sleep(50 * time.Millisecond)
+	_, ok := a.peerConn(b.ID())
+	assert.True(t, ok)
+	assert.Error(t, old.ctx.Err())
+}
+
+func TestPeerFailuresResetOnSuccess(t *testing.T) {
+	var (
+		n    = NewNode(ServerConfig{ListenAddr: "a"})
+		conn = n.newPeerConn("flaky", nil, &proto.PeerInfo{ListenAddr: "flaky"})
+	)
+
+	for i := 0; i < maxPeerFailures-1; i++ {
+		n.peerResult(conn, errors.New("timeout"))
+	}
+	n.peerResult(conn, nil)
+	n.peerResult(conn, errors.New("timeout"))
+
+	assert.Equal(t, int32(1), conn.failures.Load())
+	assert.Equal(t, int64(maxPeerFailures), conn.failed.Load())
+}
+
+func TestPingEcho(t *testing.T) {
+	var (
+		n           = NewNode(ServerConfig{ListenAddr: "a"})
+		toN, remote = newPipe()
+	)
+	addStream(n, "b", toN, &proto.PeerInfo{ListenAddr: "b"})
+
+	assert.Nil(t, remote.Send(&proto.Envelope{Id: 3, Payload: &proto.Envelope_Ping{
+		Ping: &proto.PingMessage{Nonce: 42, Timestamp: 7},
+	}}))
+	resp, err := remote.Recv()
+	assert.Nil(t, err)
+	assert.True(t, resp.Response)
+	assert.Equal(t, uint64(3), resp.Id)
+	assert.Equal(t, uint64(42), resp.GetPong().Nonce)
+	assert.Equal(t, int64(7), resp.GetPong().Timestamp)
+}
+
+func TestPingRecordsLatency(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{ListenAddr: "a"})
+		b = NewNode(ServerConfig{ListenAddr: "b"})
+	)
+	connect(a, b)
+	conn, _ := a.peerConn(b.ID())
+
+	assert.Nil(t, a.ping(conn))
+	assert.Greater(t, conn.latency.Load(), int64(0))
+	assert.Equal(t, int64(1), conn.sent.Load())
+}
+
+func TestUnresponsivePeerIsDropped(t *testing.T) {
+	var (
+		n           = NewNode(ServerConfig{ListenAddr: "a"})
+		toN, remote = newPipe()
+	)
+	conn := addStream(n, "broken", toN, &proto.PeerInfo{ListenAddr: "broken"})
+	go answerWithErrors(remote)
+
+	for i := 0; i < maxPeerFailures; i++ {
+		assert.NotNil(t, n.ping(conn))
+	}
+
+	_, ok := n.peerConn("broken")
+	assert.False(t, ok)
+}
+
+func TestRequestEndsWithPeer(t *testing.T) {
+	var (
+		n    = NewNode(ServerConfig{ListenAddr: "a"})
+		s, _ = newPipe()
+	)
+	conn := addStream(n, "silent", s, &proto.PeerInfo{ListenAddr: "silent"})
+
+	// nobody answers, the request gives up once the peer is gone
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		n.removePeer("silent")
+	}()
+	_, err := conn.request(&proto.Envelope{Payload: &proto.Envelope_Ping{Ping: &proto.PingMessage{}}})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Empty(t, conn.requests)
+}
+
+func TestReconnectDelay(t *testing.T) {
+	assert.Equal(t, minReconnectDelay, reconnectDelay(0))
+	assert.Equal(t, 2*minReconnectDelay, reconnectDelay(1))
+	assert.Equal(t, 8*minReconnectDelay, reconnectDelay(3))
+	assert.Equal(t, maxReconnectDelay, reconnectDelay(20))
+	assert.Equal(t, maxReconnectDelay, reconnectDelay(1000))
+}
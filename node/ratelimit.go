@@ -0,0 +1,236 @@
+package node
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/pdrm26/blocker/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// Default quotas, in calls per second with the burst allowed on top.
+var (
+	DefaultGlobalRateLimit  = RateLimit{Rate: 1000, Burst: 2000}
+	DefaultPerPeerRateLimit = RateLimit{Rate: 100, Burst: 200}
+	DefaultMethodRateLimits = map[string]RateLimit{
+		"HandleTransaction": {Rate: 20, Burst: 50},
+		"HandleBlock":       {Rate: 2, Burst: 10},
+		"Connect":           {Rate: 1, Burst: 5},
+		"SubscribeMempool":  {Rate: 1, Burst: 5},
+	}
+)
+
+// maxRateKeys is how many peer buckets we keep. Past that the ones used
+// least recently are dropped, a peer that comes back starts over full.
+const maxRateKeys = 10_000
+
+// RateLimit is a token bucket quota: Rate calls per second on average, with
+// bursts of up to Burst calls.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l RateLimit) unlimited() bool {
+	return l.Rate < 0
+}
+
+func (l RateLimit) withDefault(def RateLimit) RateLimit {
+	if l.Rate == 0 {
+		return def
+	}
+	if l.Burst <= 0 {
+		l.Burst = max(1, int(l.Rate))
+	}
+	return l
+}
+
+// RateLimitConfig bounds how fast peers and clients may call us. Zero fields
+// take the defaults, a negative Rate turns a limit off.
+type RateLimitConfig struct {
+	// Global limits the calls of everybody together.
+	Global RateLimit
+	// PerPeer limits the calls from each peer address.
+	PerPeer RateLimit
+	// Methods limits the calls from each peer address to single methods by
+	// name, like "HandleTransaction", on top of PerPeer. Methods missing
+	// here take their default.
+	Methods map[string]RateLimit
+}
+
+func (cfg RateLimitConfig) withDefaults() RateLimitConfig {
+	cfg.Global = cfg.Global.withDefault(DefaultGlobalRateLimit)
+	cfg.PerPeer = cfg.PerPeer.withDefault(DefaultPerPeerRateLimit)
+
+	methods := make(map[string]RateLimit)
+	for method, limit := range DefaultMethodRateLimits {
+		methods[method] = limit
+	}
+	for method, limit := range cfg.Methods {
+		methods[method] = limit.withDefault(DefaultMethodRateLimits[method])
+	}
+	cfg.Methods = methods
+
+	return cfg
+}
+
+type tokenBucket struct {
+	key     string
+	limit   RateLimit
+	tokens  float64
+	updated time.Time
+}
+
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	return &tokenBucket{limit: limit, tokens: float64(limit.Burst), updated: now}
+}
+
+// refill adds the tokens earned since the bucket was last used.
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = min(float64(b.limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*b.limit.Rate)
+	b.updated = now
+}
+
+// rateLimiter keeps a token bucket for every peer address, one for every
+// peer address and method, and one for everybody. The peer buckets are kept
+// in the order they were used, most recent first.
+type rateLimiter struct {
+	lock    sync.Mutex
+	cfg     RateLimitConfig
+	global  *tokenBucket
+	buckets map[string]*list.Element
+	used    *list.List
+}
+
+func newRateLimiter(cfg RateLimitConfig) *rateLimiter {
+	cfg = cfg.withDefaults()
+	return &rateLimiter{
+		cfg:     cfg,
+		global:  newTokenBucket(cfg.Global, time.Now()),
+		buckets: make(map[string]*list.Element),
+		used:    list.New(),
+	}
+}
+
+// allow reports whether addr may call method now. The call takes a token
+// from every bucket it counts against, but only if all of them have one, so
+// a rejected call costs nothing. An empty method only counts against the
+// peer and global quotas.
+func (l *rateLimiter) allow(addr, method string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var buckets []*tokenBucket
+	if limit, ok := l.cfg.Methods[method]; ok && !limit.unlimited() {
+		buckets = append(buckets, l.bucketLocked(addr+" "+method, limit))
+	}
+	if !l.cfg.PerPeer.unlimited() {
+		buckets = append(buckets, l.bucketLocked(addr, l.cfg.PerPeer))
+	}
+	if !l.cfg.Global.unlimited() {
+		buckets = append(buckets, l.global)
+	}
+
+	now := time.Now()
+	for _, b := range buckets {
+		b.refill(now)
+		if b.tokens < 1 {
+			return false
+		}
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+
+	return true
+}
+
+// bucketLocked returns the bucket of key, making it the most recently used
+// one. A new bucket pushes out the least recently used once there are
+// maxRateKeys of them.
+func (l *rateLimiter) bucketLocked(key string, limit RateLimit) *tokenBucket {
+	if e, ok := l.buckets[key]; ok {
+		l.used.MoveToFront(e)
+		return e.Value.(*tokenBucket)
+	}
+
+	b := newTokenBucket(limit, time.Now())
+	b.key = key
+	l.buckets[key] = l.used.PushFront(b)
+	for l.used.Len() > maxRateKeys {
+		oldest := l.used.Back()
+		l.used.Remove(oldest)
+		delete(l.buckets, oldest.Value.(*tokenBucket).key)
+	}
+
+	return b
+}
+
+// remoteHost returns the host a call came from. Calls are limited per host,
+// since every new connection comes from another port.
+func remoteHost(ctx context.Context) (string, bool) {
+	remote, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return addrHost(remote.Addr.String()), true
+}
+
+// addrHost returns the host of addr, or addr itself when it has no port.
+func addrHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return addr
+	}
+	return host
+}
+
+// checkRate returns ErrRateLimited if the caller behind ctx is over a quota
+// for the call to fullMethod.
+func (n *Node) checkRate(ctx context.Context, fullMethod string) error {
+	host, _ := remoteHost(ctx)
+	method := path.Base(fullMethod)
+	if !n.limiter.allow(host, method) {
+		return fmt.Errorf("%w: %s from %s", ErrRateLimited, method, host)
+	}
+	return nil
+}
+
+func (n *Node) rateLimitUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := n.checkRate(ctx, info.FullMethod); err != nil {
+		return nil, toStatusError(err)
+	}
+	return handler(ctx, req)
+}
+
+func (n *Node) rateLimitStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := n.checkRate(ss.Context(), info.FullMethod); err != nil {
+		return toStatusError(err)
+	}
+	return handler(srv, ss)
+}
+
+// allowEnvelope reports whether p may send env now. Messages on the stream
+// count against the same quotas as calls, txs and blocks against the ones
+// of the RPCs clients send them with.
+func (n *Node) allowEnvelope(p *peerConn, env *proto.Envelope) bool {
+	method := ""
+	switch env.Payload.(type) {
+	case *proto.Envelope_Tx:
+		method = "HandleTransaction"
+	case *proto.Envelope_Block:
+		method = "HandleBlock"
+	}
+
+	host, ok := remoteHost(p.stream.Context())
+	if !ok {
+		host = p.addr
+	}
+	return n.limiter.allow(host, method)
+}
@@ -0,0 +1,171 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pdrm26/blocker/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func callerContext(addr string) context.Context {
+	tcp, _ := net.ResolveTCPAddr("tcp", addr)
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: tcp})
+}
+
+func TestTokenBucket(t *testing.T) {
+	var (
+		now = time.Now()
+		b   = newTokenBucket(RateLimit{Rate: 10, Burst: 3}, now)
+	)
+	b.tokens = 0
+
+	// one token every 100ms, never more than the burst
+	b.refill(now.Add(100 * time.Millisecond))
+	assert.InDelta(t, 1, b.tokens, 0.001)
+	b.refill(now.Add(time.Hour))
+	assert.Equal(t, float64(3), b.tokens)
+}
+
+func TestRateLimitDefaults(t *testing.T) {
+	cfg := RateLimitConfig{
+		PerPeer: RateLimit{Rate: 5},
+		Methods: map[string]RateLimit{"HandleBlock": {Rate: -1}},
+	}.withDefaults()
+
+	assert.Equal(t, DefaultGlobalRateLimit, cfg.Global)
+	assert.Equal(t, RateLimit{Rate: 5, Burst: 5}, cfg.PerPeer)
+	assert.Equal(t, DefaultMethodRateLimits["HandleTransaction"], cfg.Methods["HandleTransaction"])
+	assert.True(t, cfg.Methods["HandleBlock"].unlimited())
+}
+
+func TestRateLimiterPerMethod(t *testing.T) {
+	l := newRateLimiter(RateLimitConfig{
+		Methods: map[string]RateLimit{"HandleTransaction": {Rate: 1, Burst: 2}},
+	})
+
+	assert.True(t, l.allow("1.2.3.4", "HandleTransaction"))
+	assert.True(t, l.allow("1.2.3.4", "HandleTransaction"))
+	assert.False(t, l.allow("1.2.3.4", "HandleTransaction"))
+	// other methods and other peers have their own quotas
+	assert.True(t, l.allow("1.2.3.4", "GetMempoolStats"))
+	assert.True(t, l.allow("5.6.7.8", "HandleTransaction"))
+}
+
+func TestRateLimiterPerPeer(t *testing.T) {
+	l := newRateLimiter(RateLimitConfig{PerPeer: RateLimit{Rate: 1, Burst: 2}})
+
+	assert.True(t, l.allow("1.2.3.4", "GetMempoolStats"))
+	assert.True(t, l.allow("1.2.3.4", "ListMempool"))
+	assert.False(t, l.allow("1.2.3.4", "GetTransaction"))
+	assert.True(t, l.allow("5.6.7.8", "GetTransaction"))
+}
+
+func TestRateLimiterGlobal(t *testing.T) {
+	l := newRateLimiter(RateLimitConfig{Global: RateLimit{Rate: 1, Burst: 2}})
+
+	assert.True(t, l.allow("1.2.3.4", ""))
+	assert.True(t, l.allow("5.6.7.8", ""))
+	assert.False(t, l.allow("9.9.9.9", ""))
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	l := newRateLimiter(RateLimitConfig{
+		Global:  RateLimit{Rate: -1},
+		PerPeer: RateLimit{Rate: -1},
+		Methods: map[string]RateLimit{"HandleBlock": {Rate: -1}},
+	})
+
+	for i := 0; i < 100; i++ {
+		assert.True(t, l.allow("1.2.3.4", "HandleBlock"))
+	}
+	assert.Empty(t, l.buckets)
+}
+
+func TestRateLimiterRejectedCallIsFree(t *testing.T) {
+	l := newRateLimiter(RateLimitConfig{
+		PerPeer: RateLimit{Rate: 1, Burst: 1},
+		Methods: map[string]RateLimit{"HandleTransaction": {Rate: 1, Burst: 2}},
+	})
+
+	assert.True(t, l.allow("1.2.3.4", "GetMempoolStats"))
+	// over the peer quota, the method quota stays untouched
+	assert.False(t, l.allow("1.2.3.4", "HandleTransaction"))
+	assert.Equal(t, float64(2), l.buckets["1.2.3.4 HandleTransaction"].Value.(*tokenBucket).tokens)
+}
+
+func TestRateLimiterKeepsRecentBuckets(t *testing.T) {
+	l := newRateLimiter(RateLimitConfig{
+		Global:  RateLimit{Rate: -1},
+		PerPeer: RateLimit{Rate: 1, Burst: 1},
+	})
+
+	assert.True(t, l.allow("first", ""))
+	for i := 0; i < maxRateKeys+100; i++ {
+		// first keeps being used, so it stays
+		assert.False(t, l.allow("first", ""))
+		l.allow(fmt.Sprintf("host-%d", i), "")
+	}
+
+	assert.Equal(t, maxRateKeys, len(l.buckets))
+	assert.Equal(t, maxRateKeys, l.used.Len())
+	assert.Contains(t, l.buckets, "first")
+	assert.NotContains(t, l.buckets, "host-0")
+}
+
+func TestRateLimitUnary(t *testing.T) {
+	var (
+		n = NewNode(ServerConfig{
+			ListenAddr: "a",
+			RateLimits: RateLimitConfig{Methods: map[string]RateLimit{"HandleTransaction": {Rate: 1, Burst: 1}}},
+		})
+		info    = &grpc.UnaryServerInfo{FullMethod: "/Node/HandleTransaction"}
+		handled = 0
+		handler = func(ctx context.Context, req any) (any, error) {
+			handled++
+			return nil, nil
+		}
+	)
+
+	_, err := n.rateLimitUnary(callerContext("10.0.0.1:4000"), nil, info, handler)
+	assert.Nil(t, err)
+
+	// another connection from the same host shares the quota
+	_, err = n.rateLimitUnary(callerContext("10.0.0.1:4001"), nil, info, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, "RATE_LIMITED", RejectReason(err))
+	assert.Equal(t, 1, handled)
+
+	_, err = n.rateLimitUnary(callerContext("10.0.0.2:4000"), nil, info, handler)
+	assert.Nil(t, err)
+}
+
+func TestRateLimitedEnvelope(t *testing.T) {
+	var (
+		n = NewNode(ServerConfig{
+			ListenAddr: "a",
+			RateLimits: RateLimitConfig{PerPeer: RateLimit{Rate: 1, Burst: 1}},
+		})
+		toN, remote = newPipe()
+		ping        = &proto.Envelope_Ping{Ping: &proto.PingMessage{Nonce: 1}}
+	)
+	addStream(n, "b", toN, &proto.PeerInfo{ListenAddr: "b"})
+
+	assert.Nil(t, remote.Send(&proto.Envelope{Id: 1, Payload: ping}))
+	resp, err := remote.Recv()
+	assert.Nil(t, err)
+	assert.NotNil(t, resp.GetPong())
+
+	assert.Nil(t, remote.Send(&proto.Envelope{Id: 2, Payload: ping}))
+	resp, err = remote.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), resp.Id)
+	assert.Equal(t, uint32(codes.ResourceExhausted), resp.Error.GetCode())
+}
@@ -0,0 +1,289 @@
+package node
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pdrm26/blocker/proto"
+	"github.com/pdrm26/blocker/types"
+)
+
+// UTXOSnapshot is the UTXO set as of a given block, together with the
+// headers leading up to it. A node started from a snapshot only needs the
+// blocks above Height to catch up.
+type UTXOSnapshot struct {
+	Height     int
+	BlockHash  BlockHash
+	Headers    []*proto.Header
+	UTXOs      []*UTXO
+	Commitment []byte
+}
+
+// Verify checks that the headers link up to BlockHash and that the
+// commitment matches both the UTXOs and the state root of that block. It
+// does not tell whether the headers can be trusted; the caller has to
+// compare Commitment against a known value.
+func (s *UTXOSnapshot) Verify() error {
+	if s.Height < 0 {
+		return fmt.Errorf("snapshot height (%d) is negative", s.Height)
+	}
+	if len(s.Headers) != s.Height+1 {
+		return fmt.Errorf("snapshot at height (%d) carries (%d) headers", s.Height, len(s.Headers))
+	}
+	for i, header := range s.Headers {
+		if header == nil {
+			return fmt.Errorf("snapshot header at height (%d) is missing", i)
+		}
+	}
+
+	for i := 1; i < len(s.Headers); i++ {
+		if !bytes.Equal(types.HashHeader(s.Headers[i-1]), s.Headers[i].PrevHash) {
+			return fmt.Errorf("snapshot header at height (%d) does not link to its parent", i)
+		}
+	}
+
+	tipHash := hex.EncodeToString(types.HashHeader(s.Headers[s.Height]))
+	if tipHash != s.BlockHash {
+		return fmt.Errorf("snapshot block hash %s does not match header %s", s.BlockHash, tipHash)
+	}
+
+	if !bytes.Equal(StateRoot(s.UTXOs), s.Commitment) {
+		return fmt.Errorf("snapshot commitment does not match its UTXO set")
+	}
+	if !bytes.Equal(s.Headers[s.Height].StateRoot, s.Commitment) {
+		return fmt.Errorf("snapshot commitment does not match the header state root")
+	}
+
+	return nil
+}
+
+func (s *UTXOSnapshot) Proto() *proto.UTXOSnapshot {
+	utxos := make([]*proto.UTXO, len(s.UTXOs))
+	for i, utxo := range s.UTXOs {
+		hash, _ := hex.DecodeString(utxo.Hash)
+		utxos[i] = &proto.UTXO{
+			Hash:     hash,
+			OutIndex: uint32(utxo.OutIndex),
+			Amount:   utxo.Amount,
+			Address:  utxo.Address,
+			Height:   int32(utxo.Height),
+		}
+	}
+
+	blockHash, _ := hex.DecodeString(s.BlockHash)
+	return &proto.UTXOSnapshot{
+		Height:     int32(s.Height),
+		BlockHash:  blockHash,
+		Headers:    s.Headers,
+		Utxos:      utxos,
+		Commitment: s.Commitment,
+	}
+}
+
+func SnapshotFromProto(p *proto.UTXOSnapshot) *UTXOSnapshot {
+	utxos := make([]*UTXO, len(p.Utxos))
+	for i, utxo := range p.Utxos {
+		utxos[i] = &UTXO{
+			Hash:     hex.EncodeToString(utxo.Hash),
+			OutIndex: int(utxo.OutIndex),
+			Amount:   utxo.Amount,
+			Address:  utxo.Address,
+			Height:   int(utxo.Height),
+		}
+	}
+
+	return &UTXOSnapshot{
+		Height:     int(p.Height),
+		BlockHash:  hex.EncodeToString(p.BlockHash),
+		Headers:    p.Headers,
+		UTXOs:      utxos,
+		Commitment: p.Commitment,
+	}
+}
+
+// ExportSnapshot returns the UTXO set as of the given height. Heights below
+// the tip are reached by rolling the current set back with undo records, so
+// they must not be pruned.
+func (c *Chain) ExportSnapshot(height int) (*UTXOSnapshot, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	utxos, err := c.utxoSetAt(height)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make([]*proto.Header, height+1)
+	for i := range headers {
+		headers[i] = c.headers.Get(i)
+	}
+
+	list := make([]*UTXO, 0, len(utxos))
+	for _, utxo := range utxos {
+		list = append(list, utxo)
+	}
+	list = sortUTXOs(list)
+
+	return &UTXOSnapshot{
+		Height:     height,
+		BlockHash:  hex.EncodeToString(types.HashHeader(headers[height])),
+		Headers:    headers,
+		UTXOs:      list,
+		Commitment: StateRoot(list),
+	}, nil
+}
+
+func (c *Chain) utxoSetAt(height int) (map[string]*UTXO, error) {
+	if height < 0 || height > c.headers.Height() {
+		return nil, fmt.Errorf("given height (%d) out of range - height (%d)", height, c.headers.Height())
+	}
+
+	all, err := c.utxoStore.All()
+	if err != nil {
+		return nil, err
+	}
+	utxos := make(map[string]*UTXO, len(all))
+	for _, utxo := range all {
+		utxos[utxo.Key()] = utxo
+	}
+
+	for h := c.headers.Height(); h > height; h-- {
+		block, err := c.blockByHeight(h)
+		if err != nil {
+			return nil, err
+		}
+		undo, err := c.undoStore.Get(hex.EncodeToString(types.HashBlock(block)))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tx := range block.Transactions {
+			hash := hex.EncodeToString(types.HashTransaction(tx))
+			for index := range tx.Outputs {
+				delete(utxos, utxoKey(hash, index))
+			}
+		}
+		for _, utxo := range undo.Spent {
+			utxos[utxo.Key()] = utxo
+		}
+	}
+
+	return utxos, nil
+}
+
+// NewChainFromSnapshot creates a chain whose UTXO set and headers come from
+// the snapshot instead of replaying every block. Blocks up to the snapshot
+// are not available until VerifyHistory has stored them.
+func NewChainFromSnapshot(blockStore BlockStorer, txStore TXStorer, snapshot *UTXOSnapshot) (*Chain, error) {
+	if err := snapshot.Verify(); err != nil {
+		return nil, err
+	}
+
+	chain := &Chain{
+		txStore:    txStore,
+		utxoStore:  NewMemoryUTXOStore(),
+		undoStore:  NewMemoryUndoStore(),
+		blockStore: blockStore,
+		headers:    NewHeaderList(),
+		snapshot:   snapshot,
+	}
+
+	genesisHash := types.HashBlock(chain.createGenesisBlock())
+	if !bytes.Equal(genesisHash, types.HashHeader(snapshot.Headers[0])) {
+		return nil, fmt.Errorf("snapshot is not built on our genesis block")
+	}
+
+	for _, header := range snapshot.Headers {
+		chain.headers.Add(header)
+	}
+	for _, utxo := range snapshot.UTXOs {
+		if err := chain.utxoStore.Put(utxo); err != nil {
+			return nil, err
+		}
+		chain.state = setUTXO(chain.state, utxo)
+	}
+
+	return chain, nil
+}
+
+// VerifyHistory replays the blocks up to the snapshot the chain was started
+// from, fetching each one by height, and checks that they end in the
+// snapshot's UTXO set. The blocks are then stored as if the chain had added
+// them itself and the chain no longer counts as started from a snapshot. It
+// is safe to run in the background while the chain keeps extending its tip.
+func (c *Chain) VerifyHistory(fetch func(height int) (*proto.Block, error)) error {
+	c.lock.RLock()
+	snapshot := c.snapshot
+	c.lock.RUnlock()
+	if snapshot == nil {
+		return fmt.Errorf("chain was not started from a snapshot")
+	}
+
+	replay := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	for height := 1; height <= snapshot.Height; height++ {
+		block, err := fetch(height)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(types.HashBlock(block), types.HashHeader(snapshot.Headers[height])) {
+			return fmt.Errorf("block at height (%d) does not match the snapshot headers", height)
+		}
+		if err := replay.AddBlock(block); err != nil {
+			return fmt.Errorf("block at height (%d) is invalid: %w", height, err)
+		}
+	}
+
+	replayed, err := replay.ExportSnapshot(snapshot.Height)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(replayed.Commitment, snapshot.Commitment) {
+		return fmt.Errorf("replayed history does not match the snapshot commitment")
+	}
+
+	return c.storeHistory(snapshot, replay)
+}
+
+// storeHistory copies the blocks, tx locations and undo records up to
+// snapshot from replay, then forgets the snapshot. Whatever the pruning
+// depth of the chain leaves out is not stored.
+func (c *Chain) storeHistory(snapshot *UTXOSnapshot, replay *Chain) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.snapshot != snapshot {
+		return fmt.Errorf("history of the snapshot was already verified")
+	}
+	for height := 0; height <= snapshot.Height; height++ {
+		if c.pruneDepth > 0 && height <= c.headers.Height()-c.pruneDepth {
+			continue
+		}
+		block, err := replay.blockByHeight(height)
+		if err != nil {
+			return err
+		}
+		blockHash := hex.EncodeToString(types.HashBlock(block))
+		undo, err := replay.undoStore.Get(blockHash)
+		if err != nil {
+			return err
+		}
+
+		for txIndex, tx := range block.Transactions {
+			location := &TXLocation{BlockHash: blockHash, Height: height, Index: txIndex}
+			if err := c.txStore.Put(tx, location); err != nil {
+				return err
+			}
+		}
+		if err := c.undoStore.Put(blockHash, undo); err != nil {
+			return err
+		}
+		if err := c.blockStore.Put(block); err != nil {
+			return err
+		}
+	}
+	c.snapshot = nil
+
+	return nil
+}
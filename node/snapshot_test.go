@@ -0,0 +1,131 @@
+package node
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/pdrm26/blocker/proto"
+	"github.com/pdrm26/blocker/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func chainWithSpend(t *testing.T) (*Chain, *proto.Transaction) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, chain.AddBlock(randomBlock(t, chain)))
+	}
+
+	tx := spendGenesisTX(t, chain)
+	block := randomBlock(t, chain, tx)
+	assert.Nil(t, chain.AddBlock(block))
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, chain.AddBlock(randomBlock(t, chain)))
+	}
+
+	return chain, tx
+}
+
+func TestExportSnapshot(t *testing.T) {
+	chain, tx := chainWithSpend(t)
+	txHash := hex.EncodeToString(types.HashTransaction(tx))
+
+	tip, err := chain.ExportSnapshot(chain.Height())
+	assert.Nil(t, err)
+	assert.Nil(t, tip.Verify())
+	assert.Equal(t, 2, len(tip.UTXOs))
+	assert.Equal(t, utxoKey(txHash, 0), tip.UTXOs[0].Key())
+
+	// before the spend only the genesis output exists
+	before, err := chain.ExportSnapshot(3)
+	assert.Nil(t, err)
+	assert.Nil(t, before.Verify())
+	assert.Equal(t, 1, len(before.UTXOs))
+	assert.NotEqual(t, tip.Commitment, before.Commitment)
+
+	_, err = chain.ExportSnapshot(chain.Height() + 1)
+	assert.NotNil(t, err)
+}
+
+func TestSnapshotVerifyRejectsTampering(t *testing.T) {
+	chain, _ := chainWithSpend(t)
+
+	snapshot, err := chain.ExportSnapshot(chain.Height())
+	assert.Nil(t, err)
+
+	snapshot.UTXOs[0].Amount++
+	assert.NotNil(t, snapshot.Verify())
+}
+
+func TestSnapshotVerifyRejectsNegativeHeight(t *testing.T) {
+	snapshot := &UTXOSnapshot{Height: -1}
+	assert.NotNil(t, snapshot.Verify())
+}
+
+func TestSnapshotVerifyRejectsMissingHeader(t *testing.T) {
+	chain, _ := chainWithSpend(t)
+
+	snapshot, err := chain.ExportSnapshot(chain.Height())
+	assert.Nil(t, err)
+
+	snapshot.Headers[2] = nil
+	assert.NotNil(t, snapshot.Verify())
+	snapshot.Headers[chain.Height()] = nil
+	assert.NotNil(t, snapshot.Verify())
+}
+
+func TestSnapshotProtoRoundTrip(t *testing.T) {
+	chain, _ := chainWithSpend(t)
+
+	snapshot, err := chain.ExportSnapshot(chain.Height())
+	assert.Nil(t, err)
+	assert.Equal(t, snapshot, SnapshotFromProto(snapshot.Proto()))
+}
+
+func TestNewChainFromSnapshot(t *testing.T) {
+	source, _ := chainWithSpend(t)
+
+	snapshot, err := source.ExportSnapshot(source.Height())
+	assert.Nil(t, err)
+
+	chain, err := NewChainFromSnapshot(NewMemoryBlockStore(), NewMemoryTXStore(), snapshot)
+	assert.Nil(t, err)
+	assert.Equal(t, source.Height(), chain.Height())
+
+	// only the blocks after the snapshot are needed to keep going
+	for i := 0; i < 3; i++ {
+		block := randomBlock(t, chain)
+		assert.Nil(t, chain.AddBlock(block))
+		assert.Nil(t, source.AddBlock(block))
+	}
+
+	_, err = chain.GetBlockByHeight(snapshot.Height)
+	assert.NotNil(t, err)
+
+	assert.False(t, chain.HasFullHistory())
+	assert.Nil(t, chain.VerifyHistory(source.GetBlockByHeight))
+
+	// the replayed blocks are kept, as if the chain had added them
+	assert.True(t, chain.HasFullHistory())
+	block, err := chain.GetBlockByHeight(snapshot.Height)
+	assert.Nil(t, err)
+	assert.Equal(t, types.HashHeader(snapshot.Headers[snapshot.Height]), types.HashBlock(block))
+	before, err := chain.ExportSnapshot(3)
+	assert.Nil(t, err)
+	expected, err := source.ExportSnapshot(3)
+	assert.Nil(t, err)
+	assert.Equal(t, expected.Commitment, before.Commitment)
+	assert.NotNil(t, chain.VerifyHistory(source.GetBlockByHeight))
+}
+
+func TestVerifyHistoryRejectsForeignBlocks(t *testing.T) {
+	source, _ := chainWithSpend(t)
+	other, _ := chainWithSpend(t)
+
+	snapshot, err := source.ExportSnapshot(source.Height())
+	assert.Nil(t, err)
+
+	chain, err := NewChainFromSnapshot(NewMemoryBlockStore(), NewMemoryTXStore(), snapshot)
+	assert.Nil(t, err)
+	assert.NotNil(t, chain.VerifyHistory(other.GetBlockByHeight))
+}
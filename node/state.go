@@ -0,0 +1,148 @@
+package node
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/pdrm26/blocker/proto"
+	"github.com/pdrm26/blocker/types"
+)
+
+// UTXOProof shows that a UTXO was unspent as of the block with Header.
+// Siblings is the path through the state tree, see types.SparseMerkleTree.
+type UTXOProof struct {
+	UTXO     *UTXO
+	Header   *proto.Header
+	Siblings [][]byte
+}
+
+// StateRoot returns the root of the sparse Merkle tree over the UTXOs keyed
+// by their outpoint. Every block header commits to the state root of the
+// UTXO set after the block.
+func StateRoot(utxos []*UTXO) []byte {
+	var tree types.SparseMerkleTree
+	for _, utxo := range utxos {
+		tree = setUTXO(tree, utxo)
+	}
+
+	return tree.Root()
+}
+
+func setUTXO(tree types.SparseMerkleTree, utxo *UTXO) types.SparseMerkleTree {
+	return tree.Set([]byte(utxo.Key()), encodeUTXO(utxo))
+}
+
+func VerifyUTXOProof(p *UTXOProof) bool {
+	if p == nil || p.UTXO == nil || p.Header == nil {
+		return false
+	}
+
+	return types.VerifySparseMerkleProof(p.Header.StateRoot, []byte(p.UTXO.Key()), encodeUTXO(p.UTXO), p.Siblings)
+}
+
+func encodeUTXO(utxo *UTXO) []byte {
+	hash, _ := hex.DecodeString(utxo.Hash)
+
+	buf := make([]byte, 0, len(hash)+4+8+1+len(utxo.Address)+4)
+	buf = append(buf, hash...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(utxo.OutIndex))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(utxo.Amount))
+	buf = append(buf, byte(len(utxo.Address)))
+	buf = append(buf, utxo.Address...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(utxo.Height))
+	return buf
+}
+
+func sortUTXOs(utxos []*UTXO) []*UTXO {
+	sorted := make([]*UTXO, len(utxos))
+	copy(sorted, utxos)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Key() < sorted[j].Key()
+	})
+
+	return sorted
+}
+
+// ProveUTXO builds a proof that the output with the given key was unspent as
+// of the block at height.
+func (c *Chain) ProveUTXO(key string, height int) (*UTXOProof, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	tree, changed, err := c.stateAt(height)
+	if err != nil {
+		return nil, err
+	}
+
+	utxo, ok := changed[key]
+	if !ok {
+		// untouched since height, it is whatever the store has
+		utxo, _ = c.utxoStore.Get(key)
+	}
+	siblings, found := tree.Prove([]byte(key))
+	if utxo == nil || !found {
+		return nil, fmt.Errorf("UTXO with hash [%s] is not unspent at height (%d)", key, height)
+	}
+
+	return &UTXOProof{
+		UTXO:     utxo,
+		Header:   c.headers.Get(height),
+		Siblings: siblings,
+	}, nil
+}
+
+// stateAt rolls the state tree back from the tip to the block at height with
+// the undo records of the blocks above it. It also returns the UTXOs that
+// differ from the ones in the store at that height, nil for the ones that
+// did not exist yet.
+func (c *Chain) stateAt(height int) (types.SparseMerkleTree, map[string]*UTXO, error) {
+	if height < 0 || height > c.headers.Height() {
+		return types.SparseMerkleTree{}, nil, fmt.Errorf("given height (%d) out of range - height (%d)", height, c.headers.Height())
+	}
+
+	var (
+		tree    = c.state
+		changed = make(map[string]*UTXO)
+	)
+	for h := c.headers.Height(); h > height; h-- {
+		block, err := c.blockByHeight(h)
+		if err != nil {
+			return types.SparseMerkleTree{}, nil, err
+		}
+		undo, err := c.undoStore.Get(hex.EncodeToString(types.HashBlock(block)))
+		if err != nil {
+			return types.SparseMerkleTree{}, nil, err
+		}
+
+		tree = undoState(tree, block, undo)
+		for _, tx := range block.Transactions {
+			hash := hex.EncodeToString(types.HashTransaction(tx))
+			for index := range tx.Outputs {
+				changed[utxoKey(hash, index)] = nil
+			}
+		}
+		for _, utxo := range undo.Spent {
+			changed[utxo.Key()] = utxo
+		}
+	}
+
+	return tree, changed, nil
+}
+
+// undoState takes the outputs block created out of tree and puts back the
+// ones it spent.
+func undoState(tree types.SparseMerkleTree, block *proto.Block, undo *BlockUndo) types.SparseMerkleTree {
+	for _, tx := range block.Transactions {
+		hash := hex.EncodeToString(types.HashTransaction(tx))
+		for index := range tx.Outputs {
+			tree = tree.Delete([]byte(utxoKey(hash, index)))
+		}
+	}
+	for _, utxo := range undo.Spent {
+		tree = setUTXO(tree, utxo)
+	}
+
+	return tree
+}
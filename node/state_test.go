@@ -0,0 +1,76 @@
+package node
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/pdrm26/blocker/crypto"
+	"github.com/pdrm26/blocker/types"
+	"github.com/pdrm26/blocker/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddBlockWithInvalidStateRoot(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	block := randomBlock(t, chain, spendGenesisTX(t, chain))
+
+	block.Header.StateRoot = utils.RandomHash()
+	types.SignBlock(crypto.NewPrivateKey(), block)
+	assert.NotNil(t, chain.AddBlock(block))
+}
+
+func TestAddBlockWithInvalidRootHash(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	block := randomBlock(t, chain, spendGenesisTX(t, chain))
+
+	block.Header.RootHash = utils.RandomHash()
+	types.SignBlock(crypto.NewPrivateKey(), block)
+	assert.NotNil(t, chain.AddBlock(block))
+}
+
+func TestProveUTXO(t *testing.T) {
+	chain, tx := chainWithSpend(t)
+	txHash := hex.EncodeToString(types.HashTransaction(tx))
+	genesisKey := utxoKey(genesisTXHash, 0)
+
+	proof, err := chain.ProveUTXO(utxoKey(txHash, 1), chain.Height())
+	assert.Nil(t, err)
+	assert.True(t, VerifyUTXOProof(proof))
+
+	// the genesis output was unspent before the spending block only
+	proof, err = chain.ProveUTXO(genesisKey, 3)
+	assert.Nil(t, err)
+	assert.True(t, VerifyUTXOProof(proof))
+	_, err = chain.ProveUTXO(genesisKey, chain.Height())
+	assert.NotNil(t, err)
+
+	// a proof does not carry over to another block or another amount
+	proof.Header = chain.headers.Get(chain.Height())
+	assert.False(t, VerifyUTXOProof(proof))
+	proof.Header = chain.headers.Get(3)
+	proof.UTXO = &UTXO{Hash: proof.UTXO.Hash, OutIndex: 0, Amount: 1001, Address: proof.UTXO.Address}
+	assert.False(t, VerifyUTXOProof(proof))
+}
+
+func TestStateFollowsTip(t *testing.T) {
+	var (
+		chain = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		tx    = spendGenesisTX(t, chain)
+	)
+	stateRoot := func() []byte {
+		utxos, err := chain.utxoStore.All()
+		assert.Nil(t, err)
+		return StateRoot(utxos)
+	}
+
+	assert.Nil(t, chain.AddBlock(randomBlock(t, chain, tx)))
+	assert.Equal(t, stateRoot(), chain.state.Root())
+	assert.Equal(t, chain.headers.Get(1).StateRoot, chain.state.Root())
+
+	// disconnecting puts back the tree the tip had before
+	_, err := chain.DisconnectTip()
+	assert.Nil(t, err)
+	assert.Equal(t, stateRoot(), chain.state.Root())
+	assert.Equal(t, chain.headers.Get(0).StateRoot, chain.state.Root())
+	assert.Nil(t, chain.AddBlock(randomBlock(t, chain, tx)))
+}
@@ -10,29 +10,42 @@ import (
 )
 
 type TXHash = string
+
+// TXLocation points at the block a confirmed transaction was included in.
+type TXLocation struct {
+	BlockHash BlockHash
+	Height    int
+	Index     int
+}
+
 type TXStorer interface {
-	Put(*proto.Transaction) error
+	Put(*proto.Transaction, *TXLocation) error
 	Get(TXHash) (*proto.Transaction, error)
+	GetLocation(TXHash) (*TXLocation, error)
+	Delete(TXHash) error
 }
 
 type MemoryTXStore struct {
-	lock sync.RWMutex
-	txx  map[TXHash]*proto.Transaction
+	lock      sync.RWMutex
+	txx       map[TXHash]*proto.Transaction
+	locations map[TXHash]*TXLocation
 }
 
 func NewMemoryTXStore() *MemoryTXStore {
 	return &MemoryTXStore{
-		txx: make(map[TXHash]*proto.Transaction),
+		txx:       make(map[TXHash]*proto.Transaction),
+		locations: make(map[TXHash]*TXLocation),
 	}
 }
 
-func (s *MemoryTXStore) Put(tx *proto.Transaction) error {
+func (s *MemoryTXStore) Put(tx *proto.Transaction, location *TXLocation) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
 	hash := hex.EncodeToString(types.HashTransaction(tx))
 
 	s.txx[hash] = tx
+	s.locations[hash] = location
 	return nil
 }
 
@@ -48,18 +61,42 @@ func (s *MemoryTXStore) Get(txHash TXHash) (*proto.Transaction, error) {
 	return tx, nil
 }
 
+func (s *MemoryTXStore) GetLocation(txHash TXHash) (*TXLocation, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	location, ok := s.locations[txHash]
+	if !ok {
+		return nil, fmt.Errorf("could not find a location for tx with txHash: %s", txHash)
+	}
+
+	return location, nil
+}
+
+func (s *MemoryTXStore) Delete(txHash TXHash) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.txx, txHash)
+	delete(s.locations, txHash)
+	return nil
+}
+
 type UTXOStorer interface {
 	Put(*UTXO) error
-	Get(TXHash) (*UTXO, error)
+	Get(string) (*UTXO, error)
+	Delete(string) error
+	All() ([]*UTXO, error)
 }
+
 type MemoryUTXOStore struct {
-	lock   sync.RWMutex
-	blocks map[string]*UTXO
+	lock  sync.RWMutex
+	utxos map[string]*UTXO
 }
 
 func NewMemoryUTXOStore() *MemoryUTXOStore {
 	return &MemoryUTXOStore{
-		blocks: make(map[string]*UTXO),
+		utxos: make(map[string]*UTXO),
 	}
 }
 
@@ -67,27 +104,103 @@ func (s *MemoryUTXOStore) Put(utxo *UTXO) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	key := fmt.Sprintf("%s_%d", utxo.Hash, utxo.OutIndex)
-	s.blocks[key] = utxo
+	s.utxos[utxo.Key()] = utxo
 	return nil
 }
 
-func (s *MemoryUTXOStore) Get(hash BlockHash) (*UTXO, error) {
+func (s *MemoryUTXOStore) Get(key string) (*UTXO, error) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
-	utxo, ok := s.blocks[hash]
+	utxo, ok := s.utxos[key]
 	if !ok {
-		return nil, fmt.Errorf("UTXO with hash [%s] does not exist", hash)
+		return nil, fmt.Errorf("UTXO with hash [%s] does not exist", key)
 	}
 
 	return utxo, nil
 }
 
+func (s *MemoryUTXOStore) Delete(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.utxos[key]; !ok {
+		return fmt.Errorf("UTXO with hash [%s] does not exist", key)
+	}
+
+	delete(s.utxos, key)
+	return nil
+}
+
+func (s *MemoryUTXOStore) All() ([]*UTXO, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	utxos := make([]*UTXO, 0, len(s.utxos))
+	for _, utxo := range s.utxos {
+		utxos = append(utxos, utxo)
+	}
+
+	return utxos, nil
+}
+
+func (s *MemoryUTXOStore) Len() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return len(s.utxos)
+}
+
+type UndoStorer interface {
+	Put(BlockHash, *BlockUndo) error
+	Get(BlockHash) (*BlockUndo, error)
+	Delete(BlockHash) error
+}
+
+type MemoryUndoStore struct {
+	lock  sync.RWMutex
+	undos map[BlockHash]*BlockUndo
+}
+
+func NewMemoryUndoStore() *MemoryUndoStore {
+	return &MemoryUndoStore{
+		undos: make(map[BlockHash]*BlockUndo),
+	}
+}
+
+func (s *MemoryUndoStore) Put(hash BlockHash, undo *BlockUndo) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.undos[hash] = undo
+	return nil
+}
+
+func (s *MemoryUndoStore) Get(hash BlockHash) (*BlockUndo, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	undo, ok := s.undos[hash]
+	if !ok {
+		return nil, fmt.Errorf("undo record for block [%s] does not exist", hash)
+	}
+
+	return undo, nil
+}
+
+func (s *MemoryUndoStore) Delete(hash BlockHash) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.undos, hash)
+	return nil
+}
+
 type BlockHash = string
 type BlockStorer interface {
 	Put(*proto.Block) error
 	Get(BlockHash) (*proto.Block, error)
+	Delete(BlockHash) error
 }
 
 type MemoryBlockStore struct {
@@ -121,3 +234,11 @@ func (s *MemoryBlockStore) Get(hash BlockHash) (*proto.Block, error) {
 
 	return block, nil
 }
+
+func (s *MemoryBlockStore) Delete(hash BlockHash) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.blocks, hash)
+	return nil
+}
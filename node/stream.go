@@ -0,0 +1,290 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pdrm26/blocker/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Connect runs the stream of a peer that dialed us. The peer proves who it
+// is over the stream itself, so we never have to dial it back and nodes we
+// can't reach, like ones behind a NAT, can still be our peers.
+func (n *Node) Connect(stream grpc.BidiStreamingServer[proto.Envelope, proto.Envelope]) error {
+	return n.serveStream(stream, peerSendTimeout)
+}
+
+// serveStream runs the handshake over the stream of a peer that dialed us,
+// giving up after timeout, then talks to the peer until either side is done.
+// The stream is no longer used once it returns: it is only sent on by the
+// handshake and then the worker of the peer, which is waited for, and only
+// received from by a recvStream. A send or receive still pending then ends
+// with the stream, so a peer that stopped reading can't keep us here.
+func (n *Node) serveStream(stream peerStream, timeout time.Duration) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	recv := newRecvStream(ctx, stream)
+
+	handshakeCtx, stop := context.WithTimeout(ctx, timeout)
+	p, err := n.acceptHandshake(recv.withContext(handshakeCtx))
+	stop()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, "handshake timed out")
+	}
+	if err != nil {
+		return toStatusError(err)
+	}
+	// the deadline was for the handshake only
+	p.stream = recv
+
+	n.addPeer(p)
+	select {
+	case <-p.quit:
+	case <-ctx.Done():
+	}
+	n.dropPeer(p)
+	<-p.stopped
+
+	return nil
+}
+
+// received is what a Recv on a stream returned.
+type received struct {
+	env *proto.Envelope
+	err error
+}
+
+// recvStream receives from the stream of a peer that dialed us in a goroutine
+// of its own. A Recv on a server stream only returns early once the handler
+// did, so Recv gives up when ctx is done and leaves the receive pending to
+// end with the stream.
+type recvStream struct {
+	peerStream
+	ctx  context.Context
+	msgs <-chan received
+}
+
+func newRecvStream(ctx context.Context, stream peerStream) *recvStream {
+	msgs := make(chan received)
+	go func() {
+		for {
+			env, err := stream.Recv()
+			select {
+			case msgs <- received{env, err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return &recvStream{peerStream: stream, ctx: ctx, msgs: msgs}
+}
+
+func (s *recvStream) Recv() (*proto.Envelope, error) {
+	select {
+	case r := <-s.msgs:
+		return r.env, r.err
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+}
+
+func (s *recvStream) Context() context.Context {
+	return s.ctx
+}
+
+// withContext returns s with Recv giving up once ctx is done.
+func (s *recvStream) withContext(ctx context.Context) *recvStream {
+	return &recvStream{peerStream: s.peerStream, ctx: ctx, msgs: s.msgs}
+}
+
+// acceptHandshake runs our side of the handshake of a peer that dialed us.
+// We prove we hold our identity key by signing its nonce, then it answers
+// our challenge in its peer info.
+func (n *Node) acceptHandshake(stream peerStream) (*peerConn, error) {
+	env, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	hello := env.GetHello()
+	if hello == nil {
+		return nil, fmt.Errorf("%w: expected hello, got %T", ErrHandshakeFailed, env.Payload)
+	}
+
+	challenge := newNonce()
+	resp, err := n.answerChallenge(hello.Nonce, challenge)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(&proto.Envelope{Payload: &proto.Envelope_Challenge{Challenge: resp}}); err != nil {
+		return nil, err
+	}
+
+	env, err = stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	info := env.GetPeerInfo()
+	if info == nil {
+		return nil, fmt.Errorf("%w: expected peer info, got %T", ErrHandshakeFailed, env.Payload)
+	}
+	addr := peerAddr(stream.Context(), info.ListenAddr)
+	id, err := n.acceptPeer(challenge, addr, info)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(&proto.Envelope{Payload: &proto.Envelope_PeerInfo{PeerInfo: n.getPeerInfo()}}); err != nil {
+		return nil, err
+	}
+
+	p := n.newPeerConn(id, stream, info)
+	p.addr = addr
+	p.inbound = true
+	if info.ListenAddr != "" {
+		n.addAddrs(addr)
+	}
+
+	return p, nil
+}
+
+// acceptPeer checks the peer info a node that dialed us answered challenge
+// with, and returns the id of the node if we take it as a peer.
+func (n *Node) acceptPeer(challenge []byte, addr string, info *proto.PeerInfo) (NodeID, error) {
+	id, err := n.verifyHandshake(challenge, info)
+	if err != nil {
+		return "", err
+	}
+	if n.bans.IsBanned(addr) {
+		return "", fmt.Errorf("%w: %s", ErrPeerBanned, addr)
+	}
+	if err := n.checkPeerList(addr, info); err != nil {
+		return "", err
+	}
+	if _, err := n.negotiateVersion(info); err != nil {
+		return "", err
+	}
+	if _, ok := n.peerConn(id); !ok && n.inboundCount() >= n.maxInbound() {
+		return "", fmt.Errorf("%w: (%d) inbound peers", ErrTooManyPeers, n.maxInbound())
+	}
+
+	return id, nil
+}
+
+// handshake runs our side of the handshake over a stream we opened. The
+// node first has to prove it holds its identity key, then we prove ours.
+func (n *Node) handshake(stream peerStream) (NodeID, *proto.PeerInfo, error) {
+	nonce := newNonce()
+	if err := stream.Send(&proto.Envelope{Payload: &proto.Envelope_Hello{Hello: &proto.ChallengeRequest{Nonce: nonce}}}); err != nil {
+		return "", nil, err
+	}
+
+	env, err := stream.Recv()
+	if err != nil {
+		return "", nil, err
+	}
+	resp := env.GetChallenge()
+	if resp == nil {
+		return "", nil, fmt.Errorf("%w: expected challenge, got %T", ErrHandshakeFailed, env.Payload)
+	}
+	id, err := verifyIdentity(resp.PublicKey, challengeMessage(nonce), resp.Signature)
+	if err != nil {
+		return "", nil, err
+	}
+	if id == n.ID() {
+		return "", nil, fmt.Errorf("%w: connected to ourselves", ErrHandshakeFailed)
+	}
+
+	info := n.signedPeerInfo(resp.Challenge)
+	if err := stream.Send(&proto.Envelope{Payload: &proto.Envelope_PeerInfo{PeerInfo: info}}); err != nil {
+		return "", nil, err
+	}
+	env, err = stream.Recv()
+	if err != nil {
+		return "", nil, err
+	}
+	peer := env.GetPeerInfo()
+	if peer == nil {
+		return "", nil, fmt.Errorf("%w: expected peer info, got %T", ErrHandshakeFailed, env.Payload)
+	}
+	if err := checkPeerKey(id, peer); err != nil {
+		return "", nil, err
+	}
+	if _, err := n.negotiateVersion(peer); err != nil {
+		return "", nil, err
+	}
+
+	return id, peer, nil
+}
+
+// peerAddr returns the address a peer that dialed us is known by: the host
+// it connected from, with the port it claims to listen on. Only the port is
+// taken from the peer, so it can't pass for a node on another host and get
+// that one banned. A peer that doesn't listen is known by the address it
+// connected from.
+func peerAddr(ctx context.Context, listenAddr string) string {
+	remote, ok := peer.FromContext(ctx)
+	if !ok {
+		return listenAddr
+	}
+	host, _, err := net.SplitHostPort(remote.Addr.String())
+	if err != nil {
+		return remote.Addr.String()
+	}
+	if _, port, err := net.SplitHostPort(listenAddr); err == nil && port != "" {
+		return net.JoinHostPort(host, port)
+	}
+
+	return remote.Addr.String()
+}
+
+// handleEnvelope handles a message from p. Requests are answered through
+// the queue of p, like everything else we send it.
+func (n *Node) handleEnvelope(p *peerConn, env *proto.Envelope) {
+	if env.Response {
+		p.resolve(env)
+		return
+	}
+	if !n.allowEnvelope(p, env) {
+		n.logger.Debugw("peer over rate limit, dropped message", "remote", p.addr, "type", fmt.Sprintf("%T", env.Payload))
+		if env.Id != 0 {
+			p.respond(env.Id, nil, ErrRateLimited)
+		}
+		return
+	}
+
+	switch msg := env.Payload.(type) {
+	case *proto.Envelope_Tx:
+		n.txReceived(msg.Tx, p.addr)
+	case *proto.Envelope_Block:
+		n.blockReceived(msg.Block, p.addr, n.processBlock(msg.Block))
+	case *proto.Envelope_Inv:
+		if err := n.inventory(p, msg.Inv); err != nil {
+			n.logger.Debugw("bad inventory", "remote", p.addr, "error", err)
+		}
+	case *proto.Envelope_DataRequest:
+		data, err := n.getData(p, msg.DataRequest)
+		p.respond(env.Id, &proto.Envelope{Payload: &proto.Envelope_Data{Data: data}}, err)
+	case *proto.Envelope_BlockTxsRequest:
+		txs, err := n.getBlockTransactions(msg.BlockTxsRequest)
+		p.respond(env.Id, &proto.Envelope{Payload: &proto.Envelope_BlockTxs{BlockTxs: txs}}, err)
+	case *proto.Envelope_AddrsRequest:
+		p.respond(env.Id, &proto.Envelope{Payload: &proto.Envelope_Addrs{Addrs: n.getAddrs()}}, nil)
+	case *proto.Envelope_Ping:
+		pong := &proto.PongMessage{Nonce: msg.Ping.Nonce, Timestamp: msg.Ping.Timestamp}
+		p.respond(env.Id, &proto.Envelope{Payload: &proto.Envelope_Pong{Pong: pong}}, nil)
+	default:
+		// handshake messages once the handshake is over, or nothing at all
+		n.logger.Debugw("unexpected message", "remote", p.addr, "type", fmt.Sprintf("%T", env.Payload))
+		n.misbehaving(p.addr, misbehaviorProtocol)
+	}
+}
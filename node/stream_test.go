@@ -0,0 +1,262 @@
+package node
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pdrm26/blocker/crypto"
+	"github.com/pdrm26/blocker/proto"
+	"github.com/pdrm26/blocker/types"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestPeerBehindNAT(t *testing.T) {
+	var (
+		a       = NewNode(ServerConfig{PrivKey: crypto.NewPrivateKey()})
+		addrA   = freeAddr(t)
+		privKey = genesisPrivKey(t)
+		tx      = spendOutput(t, privKey, genesisTX(t, a.chain), 0, 990)
+	)
+	// b listens nowhere, it only has the connection it dialed
+	b := NewNode(ServerConfig{})
+	startNode(t, a, addrA)
+
+	p, err := b.dialRemoteNode(addrA)
+	assert.Nil(t, err)
+	b.addPeer(p)
+	assert.Eventually(t, connected(a, b), time.Second, 10*time.Millisecond)
+
+	conn, _ := a.peerConn(b.ID())
+	assert.True(t, conn.inbound)
+	assert.NotEmpty(t, conn.addr)
+	assert.Empty(t, a.getPeerList())
+
+	// gossip flows both ways over it
+	assert.Nil(t, b.processTransaction(tx, "client"))
+	b.flushInventory()
+	assert.Eventually(t, func() bool { return a.mempool.Has(tx) }, time.Second, 10*time.Millisecond)
+	block, err := a.createBlock()
+	assert.Nil(t, err)
+	a.flushInventory()
+	assert.Eventually(t, func() bool { return b.chain.HasBlock(types.HashBlock(block)) }, time.Second, 10*time.Millisecond)
+}
+
+func TestHandshakeRejectedOverNetwork(t *testing.T) {
+	var (
+		a     = NewNode(ServerConfig{})
+		addrA = freeAddr(t)
+		b     = NewNode(ServerConfig{ListenAddr: "127.0.0.1:1"})
+	)
+	startNode(t, a, addrA)
+	assert.Nil(t, a.bans.Ban(b.ListenAddr, "testing", time.Hour))
+
+	_, err := b.dialRemoteNode(addrA)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, "PEER_BANNED", RejectReason(err))
+	assert.Empty(t, a.peerSnapshot())
+}
+
+func TestHandshakeOverPipe(t *testing.T) {
+	var (
+		a          = NewNode(ServerConfig{ListenAddr: "a"})
+		b          = NewNode(ServerConfig{ListenAddr: "b"})
+		toA, fromB = newPipe()
+		accepted   = make(chan *peerConn, 1)
+	)
+	go func() {
+		p, err := a.acceptHandshake(fromB)
+		assert.Nil(t, err)
+		accepted <- p
+	}()
+
+	id, info, err := b.handshake(toA)
+	assert.Nil(t, err)
+	assert.Equal(t, a.ID(), id)
+	assert.Equal(t, "a", info.ListenAddr)
+
+	p := <-accepted
+	assert.Equal(t, b.ID(), p.id)
+	assert.Equal(t, "b", p.addr)
+	assert.True(t, p.inbound)
+}
+
+func TestClaimedAddrKeepsRemoteHost(t *testing.T) {
+	var (
+		a          = NewNode(ServerConfig{ListenAddr: "a"})
+		b          = NewNode(ServerConfig{ListenAddr: ":4000"})
+		toA, fromB = newPipe()
+		accepted   = make(chan *peerConn, 1)
+	)
+	remote, err := net.ResolveTCPAddr("tcp", "10.0.0.9:5555")
+	assert.Nil(t, err)
+	fromB.ctx = peer.NewContext(fromB.ctx, &peer.Peer{Addr: remote})
+	go func() {
+		p, err := a.acceptHandshake(fromB)
+		assert.Nil(t, err)
+		accepted <- p
+	}()
+	_, _, err = b.handshake(toA)
+	assert.Nil(t, err)
+	p := <-accepted
+
+	// whatever it does counts against its own host only
+	assert.Equal(t, "10.0.0.9:4000", p.addr)
+	a.misbehaving(p.addr, misbehaviorProtocol)
+	assert.Equal(t, 0, a.bans.Score(":4000"))
+	assert.Equal(t, misbehaviorProtocol.score, a.bans.Score("10.0.0.9"))
+	assert.Equal(t, misbehaviorProtocol.score, a.bans.Score("10.0.0.9:5555"))
+}
+
+func TestBannedHostRejectedOnAnyPort(t *testing.T) {
+	var (
+		a          = NewNode(ServerConfig{ListenAddr: "a"})
+		b          = NewNode(ServerConfig{ListenAddr: ":5000"})
+		toA, fromB = newPipe()
+	)
+	assert.Nil(t, a.bans.Ban("10.0.0.9:4000", "testing", time.Hour))
+	assert.Equal(t, "10.0.0.9", a.bans.Bans()[0].Addr)
+
+	// a new connection claiming another port is the same host
+	remote, err := net.ResolveTCPAddr("tcp", "10.0.0.9:6666")
+	assert.Nil(t, err)
+	fromB.ctx = peer.NewContext(fromB.ctx, &peer.Peer{Addr: remote})
+	go b.handshake(toA)
+
+	_, err = a.acceptHandshake(fromB)
+	assert.ErrorIs(t, err, ErrPeerBanned)
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	var (
+		a          = NewNode(ServerConfig{ListenAddr: "a"})
+		toA, fromB = newPipe()
+	)
+
+	err := a.serveStream(fromB, 50*time.Millisecond)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+
+	// the handshake was given up, nothing answers a late hello
+	assert.Nil(t, toA.Send(&proto.Envelope{Payload: &proto.Envelope_Hello{Hello: &proto.ChallengeRequest{Nonce: newNonce()}}}))
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, toA.in)
+	assert.Empty(t, a.peerSnapshot())
+}
+
+func TestServeStreamWaitsForWorker(t *testing.T) {
+	var (
+		a          = NewNode(ServerConfig{ListenAddr: "a"})
+		b          = NewNode(ServerConfig{ListenAddr: "b"})
+		toA, fromB = newPipe()
+		served     = make(chan error, 1)
+	)
+	go func() {
+		served <- a.serveStream(fromB, time.Second)
+	}()
+	_, _, err := b.handshake(toA)
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool { return len(a.peerSnapshot()) == 1 }, time.Second, 10*time.Millisecond)
+	p, _ := a.peerConn(b.ID())
+
+	// the stream is only let go once the worker stopped writing to it
+	a.removePeer(b.ID())
+	assert.Nil(t, <-served)
+	select {
+	case <-p.stopped:
+	default:
+		t.Fatal("worker still running")
+	}
+}
+
+// stallingStream stops taking messages once stalled is set, for good, like
+// a peer that stopped reading.
+type stallingStream struct {
+	*fakeStream
+	stalled atomic.Bool
+}
+
+func (s *stallingStream) Send(env *proto.Envelope) error {
+	if !s.stalled.Load() {
+		return s.fakeStream.Send(env)
+	}
+	<-s.ctx.Done()
+	return s.ctx.Err()
+}
+
+func TestServeStreamLetsGoOfStuckPeer(t *testing.T) {
+	var (
+		a          = NewNode(ServerConfig{ListenAddr: "a"})
+		b          = NewNode(ServerConfig{ListenAddr: "b"})
+		toA, fromB = newPipe()
+		stalling   = &stallingStream{fakeStream: fromB}
+		served     = make(chan error, 1)
+	)
+	go func() {
+		served <- a.serveStream(stalling, time.Second)
+	}()
+	_, _, err := b.handshake(toA)
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool { return len(a.peerSnapshot()) == 1 }, time.Second, 10*time.Millisecond)
+	p, _ := a.peerConn(b.ID())
+
+	// the worker hangs in a send nothing ever takes
+	stalling.stalled.Store(true)
+	assert.True(t, p.send(&proto.Envelope{}))
+	time.Sleep(50 * time.Millisecond)
+
+	a.removePeer(b.ID())
+	select {
+	case err := <-served:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("stream handler still waiting for the worker")
+	}
+	// returning ends the stream, and the send with it
+	fromB.cancel()
+}
+
+func TestHandshakeOutOfOrder(t *testing.T) {
+	var (
+		a          = NewNode(ServerConfig{ListenAddr: "a"})
+		toA, fromB = newPipe()
+	)
+	assert.Nil(t, toA.Send(&proto.Envelope{Payload: &proto.Envelope_PeerInfo{PeerInfo: &proto.PeerInfo{}}}))
+
+	_, err := a.acceptHandshake(fromB)
+	assert.ErrorIs(t, err, ErrHandshakeFailed)
+}
+
+func TestUnexpectedMessageIsScored(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{ListenAddr: "a"})
+		b = NewNode(ServerConfig{ListenAddr: "b"})
+	)
+	_, toA := connect(a, b)
+
+	assert.Nil(t, toA.Send(&proto.Envelope{Payload: &proto.Envelope_Hello{Hello: &proto.ChallengeRequest{}}}))
+	assert.Eventually(t, func() bool {
+		return a.bans.Score("b") == misbehaviorProtocol.score
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPeerAddr(t *testing.T) {
+	from := func(addr string) context.Context {
+		tcp, err := net.ResolveTCPAddr("tcp", addr)
+		assert.Nil(t, err)
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: tcp})
+	}
+
+	assert.Equal(t, "10.0.0.1:3000", peerAddr(from("10.0.0.1:5555"), "10.0.0.1:3000"))
+	// only the port is taken from the peer, never the host
+	assert.Equal(t, "10.0.0.1:3000", peerAddr(from("10.0.0.1:5555"), ":3000"))
+	assert.Equal(t, "10.0.0.1:3000", peerAddr(from("10.0.0.1:5555"), "10.0.0.2:3000"))
+	assert.Equal(t, "[::1]:3000", peerAddr(from("[::1]:5555"), ":3000"))
+	assert.Equal(t, "10.0.0.1:5555", peerAddr(from("10.0.0.1:5555"), ""))
+	assert.Equal(t, "10.0.0.1:5555", peerAddr(from("10.0.0.1:5555"), "nonsense"))
+	assert.Equal(t, "b", peerAddr(context.Background(), "b"))
+}
@@ -0,0 +1,65 @@
+package node
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// TLSConfig secures the connections between nodes. Without one nodes talk
+// in plaintext.
+type TLSConfig struct {
+	// CertFile and KeyFile hold our certificate and its key in PEM.
+	CertFile string
+	KeyFile  string
+	// CAFile holds the PEM certificates the certificates of our peers must
+	// chain to. Empty uses the system roots.
+	CAFile string
+	// Mutual makes both sides of every connection present a certificate
+	// issued by the CA in CAFile, so only nodes the CA let in can join.
+	Mutual bool
+}
+
+// credentials loads the certificates and returns the transport credentials
+// for serving and for dialing peers.
+func (c *TLSConfig) credentials() (credentials.TransportCredentials, credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load certificate: %w", err)
+	}
+
+	var roots *x509.CertPool
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("load CA: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("load CA: no certificates in %s", c.CAFile)
+		}
+	}
+	if c.Mutual && roots == nil {
+		return nil, nil, errors.New("mutual TLS needs a CA file")
+	}
+
+	server := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	client := &tls.Config{
+		RootCAs:    roots,
+		MinVersion: tls.VersionTLS12,
+	}
+	if c.Mutual {
+		server.ClientAuth = tls.RequireAndVerifyClientCert
+		server.ClientCAs = roots
+		client.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(server), credentials.NewTLS(client), nil
+}
@@ -0,0 +1,167 @@
+package node
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// testCA is a private CA that issues certificates for 127.0.0.1.
+type testCA struct {
+	dir  string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "blocker test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+
+	ca := &testCA{dir: t.TempDir(), cert: cert, key: key}
+	ca.file = ca.writePEM(t, "ca.pem", "CERTIFICATE", der)
+	return ca
+}
+
+func (ca *testCA) writePEM(t *testing.T, name, typ string, der []byte) string {
+	path := filepath.Join(ca.dir, name)
+	assert.Nil(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600))
+	return path
+}
+
+// issue returns the TLS config of a node with a certificate from ca.
+func (ca *testCA) issue(t *testing.T, name string, mutual bool) *TLSConfig {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	return &TLSConfig{
+		CertFile: ca.writePEM(t, name+".pem", "CERTIFICATE", der),
+		KeyFile:  ca.writePEM(t, name+"-key.pem", "EC PRIVATE KEY", keyDER),
+		CAFile:   ca.file,
+		Mutual:   mutual,
+	}
+}
+
+func connected(a, b *Node) func() bool {
+	return func() bool {
+		_, atA := a.peerConn(b.ID())
+		_, atB := b.peerConn(a.ID())
+		return atA && atB
+	}
+}
+
+func callOver(t *testing.T, addr string, creds credentials.TransportCredentials) error {
+	client, err := MakeNodeClientWithCredentials(addr, creds)
+	assert.Nil(t, err)
+	defer client.(*nodeClient).conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = client.GetMempoolStats(ctx, &emptypb.Empty{})
+	return err
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	ca := newTestCA(t)
+	cfg := ca.issue(t, "a", true)
+
+	_, _, err := (&TLSConfig{CertFile: "missing.pem", KeyFile: cfg.KeyFile}).credentials()
+	assert.NotNil(t, err)
+
+	_, _, err = (&TLSConfig{CertFile: cfg.CertFile, KeyFile: cfg.KeyFile, Mutual: true}).credentials()
+	assert.NotNil(t, err)
+
+	_, _, err = (&TLSConfig{CertFile: cfg.CertFile, KeyFile: cfg.KeyFile, CAFile: cfg.KeyFile}).credentials()
+	assert.NotNil(t, err)
+
+	_, _, err = cfg.credentials()
+	assert.Nil(t, err)
+}
+
+func TestTLSNodes(t *testing.T) {
+	var (
+		ca    = newTestCA(t)
+		a     = NewNode(ServerConfig{TLS: ca.issue(t, "a", false)})
+		b     = NewNode(ServerConfig{TLS: ca.issue(t, "b", false)})
+		addrA = freeAddr(t)
+		addrB = freeAddr(t)
+	)
+	startNode(t, a, addrA)
+	startNode(t, b, addrB, addrA)
+
+	assert.Eventually(t, connected(a, b), 5*time.Second, 10*time.Millisecond)
+
+	// plaintext clients can't talk to a TLS node
+	assert.NotNil(t, callOver(t, addrA, insecure.NewCredentials()))
+}
+
+func TestMutualTLSNodes(t *testing.T) {
+	var (
+		ca    = newTestCA(t)
+		a     = NewNode(ServerConfig{TLS: ca.issue(t, "a", true)})
+		b     = NewNode(ServerConfig{TLS: ca.issue(t, "b", true)})
+		addrA = freeAddr(t)
+		addrB = freeAddr(t)
+	)
+	startNode(t, a, addrA)
+	startNode(t, b, addrB, addrA)
+
+	assert.Eventually(t, connected(a, b), 5*time.Second, 10*time.Millisecond)
+
+	// a client trusting the CA but without a certificate of its own
+	_, serverOnly, err := ca.issue(t, "c", false).credentials()
+	assert.Nil(t, err)
+	assert.NotNil(t, callOver(t, addrA, serverOnly))
+
+	// a client with a certificate from another CA
+	_, stranger, err := newTestCA(t).issue(t, "d", true).credentials()
+	assert.Nil(t, err)
+	assert.NotNil(t, callOver(t, addrA, stranger))
+
+	_, member, err := ca.issue(t, "e", true).credentials()
+	assert.Nil(t, err)
+	assert.Nil(t, callOver(t, addrA, member))
+}
@@ -0,0 +1,91 @@
+package node
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pdrm26/blocker/proto"
+	"github.com/pdrm26/blocker/types"
+)
+
+// utxoView layers spends and new outputs on top of a UTXO store without
+// writing to it, so a batch of transactions can be checked and applied in
+// order before anything is committed.
+type utxoView struct {
+	base  UTXOStorer
+	added map[string]*UTXO
+	spent map[string]*UTXO
+}
+
+func newUTXOView(base UTXOStorer) *utxoView {
+	return &utxoView{
+		base:  base,
+		added: make(map[string]*UTXO),
+		spent: make(map[string]*UTXO),
+	}
+}
+
+func (v *utxoView) Get(key string) (*UTXO, error) {
+	if _, ok := v.spent[key]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrDoubleSpend, key)
+	}
+	if utxo, ok := v.added[key]; ok {
+		return utxo, nil
+	}
+
+	return v.base.Get(key)
+}
+
+// apply spends the inputs of tx and adds its outputs to the view.
+func (v *utxoView) apply(tx *proto.Transaction, height int) error {
+	for _, input := range tx.Inputs {
+		key := utxoKey(hex.EncodeToString(input.PrevTxHash), int(input.PrevOutIndex))
+		utxo, err := v.Get(key)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := v.added[key]; ok {
+			delete(v.added, key)
+		} else {
+			v.spent[key] = utxo
+		}
+	}
+
+	hash := hex.EncodeToString(types.HashTransaction(tx))
+	for index, output := range tx.Outputs {
+		utxo := &UTXO{
+			Hash:     hash,
+			OutIndex: index,
+			Amount:   output.Amount,
+			Address:  output.Address,
+			Height:   height,
+		}
+		v.added[utxo.Key()] = utxo
+	}
+
+	return nil
+}
+
+// spentUTXOs returns the outputs of the base store spent through the view.
+func (v *utxoView) spentUTXOs() []*UTXO {
+	utxos := make([]*UTXO, 0, len(v.spent))
+	for _, utxo := range v.spent {
+		utxos = append(utxos, utxo)
+	}
+
+	return utxos
+}
+
+// stateTree returns tree, the state tree of the base store, with the view
+// applied. tree itself is left as it was.
+func (v *utxoView) stateTree(tree types.SparseMerkleTree) types.SparseMerkleTree {
+	for key := range v.spent {
+		tree = tree.Delete([]byte(key))
+	}
+	for _, utxo := range v.added {
+		tree = setUTXO(tree, utxo)
+	}
+
+	return tree
+}
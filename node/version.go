@@ -0,0 +1,114 @@
+package node
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pdrm26/blocker/proto"
+)
+
+// MinProtocolVersion and ProtocolVersion are the oldest and the newest
+// version of the peer protocol we speak. Two peers talk the newest version
+// they both speak.
+const (
+	MinProtocolVersion int32 = 1
+	ProtocolVersion    int32 = 2
+)
+
+// Versions that added parts of the protocol. Peers that negotiated an older
+// version are not asked to use them.
+const (
+	versionCompactBlocks int32 = 2
+	versionAddrGossip    int32 = 2
+)
+
+// Services is the bitmap of what a node offers its peers.
+type Services uint64
+
+const (
+	// ServiceFullNode keeps and serves every block.
+	ServiceFullNode Services = 1 << iota
+	// ServicePruned serves the recent blocks only.
+	ServicePruned
+	// ServiceLightServer serves UTXO proofs to light clients.
+	ServiceLightServer
+	// ServiceValidator creates blocks.
+	ServiceValidator
+)
+
+var serviceNames = []struct {
+	service Services
+	name    string
+}{
+	{ServiceFullNode, "full"},
+	{ServicePruned, "pruned"},
+	{ServiceLightServer, "light-server"},
+	{ServiceValidator, "validator"},
+}
+
+func (s Services) Has(services Services) bool {
+	return s&services == services
+}
+
+// servesBlocks reports whether blocks may be fetched from a node with s.
+func (s Services) servesBlocks() bool {
+	return s.Has(ServiceFullNode) || s.Has(ServicePruned)
+}
+
+func (s Services) String() string {
+	var names []string
+	for _, sn := range serviceNames {
+		if s.Has(sn.service) {
+			names = append(names, sn.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+
+	return strings.Join(names, "|")
+}
+
+// protocolVersion is the newest version we speak. ServerConfig.Version pins
+// it to an older one, for instance while a network upgrades.
+func (n *Node) protocolVersion() int32 {
+	if n.Version >= MinProtocolVersion && n.Version < ProtocolVersion {
+		return n.Version
+	}
+	return ProtocolVersion
+}
+
+// services returns what we offer, which is everything a node that keeps the
+// whole chain can serve unless ServerConfig.Services says otherwise. A chain
+// that dropped old blocks only serves the recent ones, whatever we were told.
+func (n *Node) services() Services {
+	services := n.Services
+	if services == 0 {
+		services = ServiceFullNode | ServiceLightServer
+	}
+	if services.Has(ServiceFullNode) && !n.chain.HasFullHistory() {
+		services = services&^ServiceFullNode | ServicePruned
+	}
+	if n.PrivKey != nil {
+		services |= ServiceValidator
+	}
+
+	return services
+}
+
+// negotiateVersion returns the newest version both we and the peer speak.
+// Peers from before versions were negotiated only send the one they speak.
+func (n *Node) negotiateVersion(info *proto.PeerInfo) (int32, error) {
+	theirMin, theirMax := info.MinProtocolVersion, info.ProtocolVersion
+	if theirMin == 0 {
+		theirMin = theirMax
+	}
+
+	version := min(n.protocolVersion(), theirMax)
+	if version < max(MinProtocolVersion, theirMin) {
+		return 0, fmt.Errorf("%w: we speak (%d-%d) peer (%d-%d)", ErrIncompatibleVersion,
+			MinProtocolVersion, n.protocolVersion(), theirMin, theirMax)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,127 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pdrm26/blocker/crypto"
+	"github.com/pdrm26/blocker/proto"
+	"github.com/pdrm26/blocker/types"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNegotiateVersion(t *testing.T) {
+	var (
+		n      = NewNode(ServerConfig{ListenAddr: "a"})
+		pinned = NewNode(ServerConfig{ListenAddr: "b", Version: 1})
+	)
+	tests := []struct {
+		min, max int32
+		version  int32
+		ok       bool
+	}{
+		{MinProtocolVersion, ProtocolVersion, ProtocolVersion, true},
+		{1, 1, 1, true},
+		// from before versions were negotiated
+		{0, 1, 1, true},
+		{1, ProtocolVersion + 5, ProtocolVersion, true},
+		{ProtocolVersion + 1, ProtocolVersion + 2, 0, false},
+		{0, 0, 0, false},
+		{2, 1, 0, false},
+	}
+	for _, tt := range tests {
+		version, err := n.negotiateVersion(&proto.PeerInfo{MinProtocolVersion: tt.min, ProtocolVersion: tt.max})
+		assert.Equal(t, tt.version, version, "%d-%d", tt.min, tt.max)
+		if tt.ok {
+			assert.Nil(t, err)
+		} else {
+			assert.ErrorIs(t, err, ErrIncompatibleVersion)
+		}
+	}
+
+	assert.Equal(t, int32(1), pinned.protocolVersion())
+	version, err := n.negotiateVersion(pinned.getPeerInfo())
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), version)
+}
+
+func TestServices(t *testing.T) {
+	var (
+		full      = NewNode(ServerConfig{ListenAddr: "a"})
+		validator = NewNode(ServerConfig{ListenAddr: "b", PrivKey: crypto.NewPrivateKey(), Services: ServicePruned})
+	)
+
+	assert.Equal(t, ServiceFullNode|ServiceLightServer, full.services())
+	assert.Equal(t, ServicePruned|ServiceValidator, validator.services())
+	assert.Equal(t, "pruned|validator", validator.services().String())
+	assert.Equal(t, "none", Services(0).String())
+	assert.True(t, validator.services().servesBlocks())
+	assert.False(t, ServiceLightServer.servesBlocks())
+
+	_, err := validator.GetUTXOProof(context.Background(), &proto.UTXOProofRequest{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestPrunedNodeServices(t *testing.T) {
+	var (
+		n        = NewNode(ServerConfig{ListenAddr: "a"})
+		explicit = NewNode(ServerConfig{ListenAddr: "b", Services: ServiceFullNode})
+	)
+	assert.Nil(t, n.chain.EnablePruning(10))
+	assert.Nil(t, explicit.chain.EnablePruning(10))
+
+	assert.Equal(t, ServicePruned|ServiceLightServer, n.services())
+	assert.Equal(t, ServicePruned, explicit.services())
+	assert.Equal(t, uint64(ServicePruned|ServiceLightServer), n.getPeerInfo().Services)
+}
+
+func TestHandshakeIncompatibleVersion(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{ListenAddr: "a"})
+		b = NewNode(ServerConfig{ListenAddr: "b"})
+	)
+
+	challenge, info := signedHandshake(b)
+	info.MinProtocolVersion, info.ProtocolVersion = ProtocolVersion+1, ProtocolVersion+1
+	_, err := a.acceptPeer(challenge, "b", info)
+	assert.ErrorIs(t, err, ErrIncompatibleVersion)
+}
+
+func TestOldPeerGetsFullBlocks(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{ListenAddr: "a", PrivKey: crypto.NewPrivateKey()})
+		b = NewNode(ServerConfig{ListenAddr: "b", Version: versionCompactBlocks - 1})
+	)
+	_, toA := connect(a, b)
+	block, _ := twoTxBlock(t, a, b)
+	peerB, _ := a.peerConn(b.ID())
+
+	// a doesn't hand out compact blocks to b
+	data, err := a.getData(peerB, &proto.InvMessage{
+		Items: []*proto.InvItem{{Type: proto.InvType_INV_COMPACT_BLOCK, Hash: types.HashBlock(block)}},
+	})
+	assert.Nil(t, err)
+	assert.Empty(t, data.CompactBlocks)
+	assert.Equal(t, 1, len(data.NotFound))
+
+	// and b doesn't ask for them
+	a.flushInventory()
+	assert.Eventually(t, func() bool { return b.chain.HasBlock(types.HashBlock(block)) }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(0), toA.blockTxRequests.Load())
+}
+
+func TestInventoryOfBlocksFromNonServingPeer(t *testing.T) {
+	var (
+		a = NewNode(ServerConfig{ListenAddr: "a", PrivKey: crypto.NewPrivateKey(), Services: ServiceLightServer})
+		b = NewNode(ServerConfig{ListenAddr: "b"})
+	)
+	connect(a, b)
+	block, _ := twoTxBlock(t, a, b)
+
+	a.flushInventory()
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, b.chain.HasBlock(types.HashBlock(block)))
+}
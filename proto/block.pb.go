@@ -22,31 +22,2316 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type RemovalReason int32
+
+const (
+	RemovalReason_REMOVAL_NONE     RemovalReason = 0
+	RemovalReason_REMOVAL_MINED    RemovalReason = 1
+	RemovalReason_REMOVAL_REPLACED RemovalReason = 2
+	RemovalReason_REMOVAL_EVICTED  RemovalReason = 3
+	RemovalReason_REMOVAL_EXPIRED  RemovalReason = 4
+	RemovalReason_REMOVAL_RESET    RemovalReason = 5
+	RemovalReason_REMOVAL_CONFLICT RemovalReason = 6
+)
+
+// Enum value maps for RemovalReason.
+var (
+	RemovalReason_name = map[int32]string{
+		0: "REMOVAL_NONE",
+		1: "REMOVAL_MINED",
+		2: "REMOVAL_REPLACED",
+		3: "REMOVAL_EVICTED",
+		4: "REMOVAL_EXPIRED",
+		5: "REMOVAL_RESET",
+		6: "REMOVAL_CONFLICT",
+	}
+	RemovalReason_value = map[string]int32{
+		"REMOVAL_NONE":     0,
+		"REMOVAL_MINED":    1,
+		"REMOVAL_REPLACED": 2,
+		"REMOVAL_EVICTED":  3,
+		"REMOVAL_EXPIRED":  4,
+		"REMOVAL_RESET":    5,
+		"REMOVAL_CONFLICT": 6,
+	}
+)
+
+func (x RemovalReason) Enum() *RemovalReason {
+	p := new(RemovalReason)
+	*p = x
+	return p
+}
+
+func (x RemovalReason) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RemovalReason) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_block_proto_enumTypes[0].Descriptor()
+}
+
+func (RemovalReason) Type() protoreflect.EnumType {
+	return &file_proto_block_proto_enumTypes[0]
+}
+
+func (x RemovalReason) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RemovalReason.Descriptor instead.
+func (RemovalReason) EnumDescriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{0}
+}
+
+type InvType int32
+
+const (
+	InvType_INV_TX            InvType = 0
+	InvType_INV_BLOCK         InvType = 1
+	InvType_INV_COMPACT_BLOCK InvType = 2 // only in getdata, the block comes back compact
+)
+
+// Enum value maps for InvType.
+var (
+	InvType_name = map[int32]string{
+		0: "INV_TX",
+		1: "INV_BLOCK",
+		2: "INV_COMPACT_BLOCK",
+	}
+	InvType_value = map[string]int32{
+		"INV_TX":            0,
+		"INV_BLOCK":         1,
+		"INV_COMPACT_BLOCK": 2,
+	}
+)
+
+func (x InvType) Enum() *InvType {
+	p := new(InvType)
+	*p = x
+	return p
+}
+
+func (x InvType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (InvType) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_block_proto_enumTypes[1].Descriptor()
+}
+
+func (InvType) Type() protoreflect.EnumType {
+	return &file_proto_block_proto_enumTypes[1]
+}
+
+func (x InvType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use InvType.Descriptor instead.
+func (InvType) EnumDescriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{1}
+}
+
+// Envelope carries every message between two peers over the Connect stream.
+// Requests carry an id that their response echoes back, announcements and
+// relayed txs and blocks carry none.
+type Envelope struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Id       uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Response bool                   `protobuf:"varint,2,opt,name=response,proto3" json:"response,omitempty"`
+	Error    *PeerError             `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"` // set on the response to a request that failed
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*Envelope_Hello
+	//	*Envelope_Challenge
+	//	*Envelope_PeerInfo
+	//	*Envelope_Tx
+	//	*Envelope_Block
+	//	*Envelope_Inv
+	//	*Envelope_DataRequest
+	//	*Envelope_Data
+	//	*Envelope_BlockTxsRequest
+	//	*Envelope_BlockTxs
+	//	*Envelope_AddrsRequest
+	//	*Envelope_Addrs
+	//	*Envelope_Ping
+	//	*Envelope_Pong
+	Payload       isEnvelope_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Envelope) Reset() {
+	*x = Envelope{}
+	mi := &file_proto_block_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Envelope) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Envelope) ProtoMessage() {}
+
+func (x *Envelope) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Envelope.ProtoReflect.Descriptor instead.
+func (*Envelope) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Envelope) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Envelope) GetResponse() bool {
+	if x != nil {
+		return x.Response
+	}
+	return false
+}
+
+func (x *Envelope) GetError() *PeerError {
+	if x != nil {
+		return x.Error
+	}
+	return nil
+}
+
+func (x *Envelope) GetPayload() isEnvelope_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Envelope) GetHello() *ChallengeRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*Envelope_Hello); ok {
+			return x.Hello
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetChallenge() *ChallengeResponse {
+	if x != nil {
+		if x, ok := x.Payload.(*Envelope_Challenge); ok {
+			return x.Challenge
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetPeerInfo() *PeerInfo {
+	if x != nil {
+		if x, ok := x.Payload.(*Envelope_PeerInfo); ok {
+			return x.PeerInfo
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetTx() *Transaction {
+	if x != nil {
+		if x, ok := x.Payload.(*Envelope_Tx); ok {
+			return x.Tx
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetBlock() *Block {
+	if x != nil {
+		if x, ok := x.Payload.(*Envelope_Block); ok {
+			return x.Block
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetInv() *InvMessage {
+	if x != nil {
+		if x, ok := x.Payload.(*Envelope_Inv); ok {
+			return x.Inv
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetDataRequest() *InvMessage {
+	if x != nil {
+		if x, ok := x.Payload.(*Envelope_DataRequest); ok {
+			return x.DataRequest
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetData() *DataMessage {
+	if x != nil {
+		if x, ok := x.Payload.(*Envelope_Data); ok {
+			return x.Data
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetBlockTxsRequest() *BlockTxRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*Envelope_BlockTxsRequest); ok {
+			return x.BlockTxsRequest
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetBlockTxs() *BlockTransactions {
+	if x != nil {
+		if x, ok := x.Payload.(*Envelope_BlockTxs); ok {
+			return x.BlockTxs
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetAddrsRequest() *emptypb.Empty {
+	if x != nil {
+		if x, ok := x.Payload.(*Envelope_AddrsRequest); ok {
+			return x.AddrsRequest
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetAddrs() *AddrList {
+	if x != nil {
+		if x, ok := x.Payload.(*Envelope_Addrs); ok {
+			return x.Addrs
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetPing() *PingMessage {
+	if x != nil {
+		if x, ok := x.Payload.(*Envelope_Ping); ok {
+			return x.Ping
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetPong() *PongMessage {
+	if x != nil {
+		if x, ok := x.Payload.(*Envelope_Pong); ok {
+			return x.Pong
+		}
+	}
+	return nil
+}
+
+type isEnvelope_Payload interface {
+	isEnvelope_Payload()
+}
+
+type Envelope_Hello struct {
+	Hello *ChallengeRequest `protobuf:"bytes,10,opt,name=hello,proto3,oneof"`
+}
+
+type Envelope_Challenge struct {
+	Challenge *ChallengeResponse `protobuf:"bytes,11,opt,name=challenge,proto3,oneof"`
+}
+
+type Envelope_PeerInfo struct {
+	PeerInfo *PeerInfo `protobuf:"bytes,12,opt,name=peerInfo,proto3,oneof"`
+}
+
+type Envelope_Tx struct {
+	Tx *Transaction `protobuf:"bytes,13,opt,name=tx,proto3,oneof"`
+}
+
+type Envelope_Block struct {
+	Block *Block `protobuf:"bytes,14,opt,name=block,proto3,oneof"`
+}
+
+type Envelope_Inv struct {
+	Inv *InvMessage `protobuf:"bytes,15,opt,name=inv,proto3,oneof"`
+}
+
+type Envelope_DataRequest struct {
+	DataRequest *InvMessage `protobuf:"bytes,16,opt,name=dataRequest,proto3,oneof"`
+}
+
+type Envelope_Data struct {
+	Data *DataMessage `protobuf:"bytes,17,opt,name=data,proto3,oneof"`
+}
+
+type Envelope_BlockTxsRequest struct {
+	BlockTxsRequest *BlockTxRequest `protobuf:"bytes,18,opt,name=blockTxsRequest,proto3,oneof"`
+}
+
+type Envelope_BlockTxs struct {
+	BlockTxs *BlockTransactions `protobuf:"bytes,19,opt,name=blockTxs,proto3,oneof"`
+}
+
+type Envelope_AddrsRequest struct {
+	AddrsRequest *emptypb.Empty `protobuf:"bytes,20,opt,name=addrsRequest,proto3,oneof"`
+}
+
+type Envelope_Addrs struct {
+	Addrs *AddrList `protobuf:"bytes,21,opt,name=addrs,proto3,oneof"`
+}
+
+type Envelope_Ping struct {
+	Ping *PingMessage `protobuf:"bytes,22,opt,name=ping,proto3,oneof"`
+}
+
+type Envelope_Pong struct {
+	Pong *PongMessage `protobuf:"bytes,23,opt,name=pong,proto3,oneof"`
+}
+
+func (*Envelope_Hello) isEnvelope_Payload() {}
+
+func (*Envelope_Challenge) isEnvelope_Payload() {}
+
+func (*Envelope_PeerInfo) isEnvelope_Payload() {}
+
+func (*Envelope_Tx) isEnvelope_Payload() {}
+
+func (*Envelope_Block) isEnvelope_Payload() {}
+
+func (*Envelope_Inv) isEnvelope_Payload() {}
+
+func (*Envelope_DataRequest) isEnvelope_Payload() {}
+
+func (*Envelope_Data) isEnvelope_Payload() {}
+
+func (*Envelope_BlockTxsRequest) isEnvelope_Payload() {}
+
+func (*Envelope_BlockTxs) isEnvelope_Payload() {}
+
+func (*Envelope_AddrsRequest) isEnvelope_Payload() {}
+
+func (*Envelope_Addrs) isEnvelope_Payload() {}
+
+func (*Envelope_Ping) isEnvelope_Payload() {}
+
+func (*Envelope_Pong) isEnvelope_Payload() {}
+
+type PeerError struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          uint32                 `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"` // a gRPC status code
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PeerError) Reset() {
+	*x = PeerError{}
+	mi := &file_proto_block_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeerError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerError) ProtoMessage() {}
+
+func (x *PeerError) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerError.ProtoReflect.Descriptor instead.
+func (*PeerError) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PeerError) GetCode() uint32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *PeerError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
 type PeerInfo struct {
-	state           protoimpl.MessageState `protogen:"open.v1"`
-	ProtocolVersion int32                  `protobuf:"varint,1,opt,name=protocolVersion,proto3" json:"protocolVersion,omitempty"`
-	BlockHeight     int32                  `protobuf:"varint,2,opt,name=blockHeight,proto3" json:"blockHeight,omitempty"`
-	ListenAddr      string                 `protobuf:"bytes,3,opt,name=listenAddr,proto3" json:"listenAddr,omitempty"`
-	PeerList        []string               `protobuf:"bytes,4,rep,name=peerList,proto3" json:"peerList,omitempty"`
-	unknownFields   protoimpl.UnknownFields
-	sizeCache       protoimpl.SizeCache
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	ProtocolVersion    int32                  `protobuf:"varint,1,opt,name=protocolVersion,proto3" json:"protocolVersion,omitempty"` // the newest version the node speaks
+	BlockHeight        int32                  `protobuf:"varint,2,opt,name=blockHeight,proto3" json:"blockHeight,omitempty"`
+	ListenAddr         string                 `protobuf:"bytes,3,opt,name=listenAddr,proto3" json:"listenAddr,omitempty"`
+	PeerList           []string               `protobuf:"bytes,4,rep,name=peerList,proto3" json:"peerList,omitempty"`
+	PublicKey          []byte                 `protobuf:"bytes,5,opt,name=publicKey,proto3" json:"publicKey,omitempty"`                    // the node identity key
+	Challenge          []byte                 `protobuf:"bytes,6,opt,name=challenge,proto3" json:"challenge,omitempty"`                    // issued by the node we hand this to
+	Signature          []byte                 `protobuf:"bytes,7,opt,name=signature,proto3" json:"signature,omitempty"`                    // over the challenge and listenAddr
+	MinProtocolVersion int32                  `protobuf:"varint,8,opt,name=minProtocolVersion,proto3" json:"minProtocolVersion,omitempty"` // the oldest version the node speaks
+	Services           uint64                 `protobuf:"varint,9,opt,name=services,proto3" json:"services,omitempty"`                     // bitmap of what the node offers
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *PeerInfo) Reset() {
+	*x = PeerInfo{}
+	mi := &file_proto_block_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeerInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerInfo) ProtoMessage() {}
+
+func (x *PeerInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerInfo.ProtoReflect.Descriptor instead.
+func (*PeerInfo) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PeerInfo) GetProtocolVersion() int32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *PeerInfo) GetBlockHeight() int32 {
+	if x != nil {
+		return x.BlockHeight
+	}
+	return 0
+}
+
+func (x *PeerInfo) GetListenAddr() string {
+	if x != nil {
+		return x.ListenAddr
+	}
+	return ""
+}
+
+func (x *PeerInfo) GetPeerList() []string {
+	if x != nil {
+		return x.PeerList
+	}
+	return nil
+}
+
+func (x *PeerInfo) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *PeerInfo) GetChallenge() []byte {
+	if x != nil {
+		return x.Challenge
+	}
+	return nil
+}
+
+func (x *PeerInfo) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *PeerInfo) GetMinProtocolVersion() int32 {
+	if x != nil {
+		return x.MinProtocolVersion
+	}
+	return 0
+}
+
+func (x *PeerInfo) GetServices() uint64 {
+	if x != nil {
+		return x.Services
+	}
+	return 0
+}
+
+type ChallengeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Nonce         []byte                 `protobuf:"bytes,1,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChallengeRequest) Reset() {
+	*x = ChallengeRequest{}
+	mi := &file_proto_block_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChallengeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChallengeRequest) ProtoMessage() {}
+
+func (x *ChallengeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChallengeRequest.ProtoReflect.Descriptor instead.
+func (*ChallengeRequest) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ChallengeRequest) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+type ChallengeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PublicKey     []byte                 `protobuf:"bytes,1,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"` // over the request nonce
+	Challenge     []byte                 `protobuf:"bytes,3,opt,name=challenge,proto3" json:"challenge,omitempty"` // to sign in the handshake that follows
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChallengeResponse) Reset() {
+	*x = ChallengeResponse{}
+	mi := &file_proto_block_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChallengeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChallengeResponse) ProtoMessage() {}
+
+func (x *ChallengeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChallengeResponse.ProtoReflect.Descriptor instead.
+func (*ChallengeResponse) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ChallengeResponse) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *ChallengeResponse) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *ChallengeResponse) GetChallenge() []byte {
+	if x != nil {
+		return x.Challenge
+	}
+	return nil
+}
+
+type PingMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Nonce         uint64                 `protobuf:"varint,1,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // unix nanoseconds, echoed back in the pong
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingMessage) Reset() {
+	*x = PingMessage{}
+	mi := &file_proto_block_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingMessage) ProtoMessage() {}
+
+func (x *PingMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingMessage.ProtoReflect.Descriptor instead.
+func (*PingMessage) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PingMessage) GetNonce() uint64 {
+	if x != nil {
+		return x.Nonce
+	}
+	return 0
+}
+
+func (x *PingMessage) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type PongMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Nonce         uint64                 `protobuf:"varint,1,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PongMessage) Reset() {
+	*x = PongMessage{}
+	mi := &file_proto_block_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PongMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PongMessage) ProtoMessage() {}
+
+func (x *PongMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PongMessage.ProtoReflect.Descriptor instead.
+func (*PongMessage) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *PongMessage) GetNonce() uint64 {
+	if x != nil {
+		return x.Nonce
+	}
+	return 0
+}
+
+func (x *PongMessage) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type Header struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Height        int32                  `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
+	PrevHash      []byte                 `protobuf:"bytes,3,opt,name=prevHash,proto3" json:"prevHash,omitempty"`
+	RootHash      []byte                 `protobuf:"bytes,4,opt,name=rootHash,proto3" json:"rootHash,omitempty"` // merkle root of txs
+	Timestamp     int64                  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	StateRoot     []byte                 `protobuf:"bytes,6,opt,name=stateRoot,proto3" json:"stateRoot,omitempty"` // sparse merkle root of the utxo set after this block
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Header) Reset() {
+	*x = Header{}
+	mi := &file_proto_block_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Header) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Header) ProtoMessage() {}
+
+func (x *Header) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Header.ProtoReflect.Descriptor instead.
+func (*Header) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Header) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Header) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *Header) GetPrevHash() []byte {
+	if x != nil {
+		return x.PrevHash
+	}
+	return nil
+}
+
+func (x *Header) GetRootHash() []byte {
+	if x != nil {
+		return x.RootHash
+	}
+	return nil
+}
+
+func (x *Header) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Header) GetStateRoot() []byte {
+	if x != nil {
+		return x.StateRoot
+	}
+	return nil
+}
+
+type Block struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Header        *Header                `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	Transactions  []*Transaction         `protobuf:"bytes,2,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	PublicKey     []byte                 `protobuf:"bytes,3,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Block) Reset() {
+	*x = Block{}
+	mi := &file_proto_block_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Block) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Block) ProtoMessage() {}
+
+func (x *Block) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Block.ProtoReflect.Descriptor instead.
+func (*Block) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Block) GetHeader() *Header {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *Block) GetTransactions() []*Transaction {
+	if x != nil {
+		return x.Transactions
+	}
+	return nil
+}
+
+func (x *Block) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *Block) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type TxInput struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PrevTxHash    []byte                 `protobuf:"bytes,1,opt,name=prevTxHash,proto3" json:"prevTxHash,omitempty"`
+	PrevOutIndex  uint32                 `protobuf:"varint,2,opt,name=prevOutIndex,proto3" json:"prevOutIndex,omitempty"`
+	PublicKey     []byte                 `protobuf:"bytes,3,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TxInput) Reset() {
+	*x = TxInput{}
+	mi := &file_proto_block_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TxInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TxInput) ProtoMessage() {}
+
+func (x *TxInput) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TxInput.ProtoReflect.Descriptor instead.
+func (*TxInput) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TxInput) GetPrevTxHash() []byte {
+	if x != nil {
+		return x.PrevTxHash
+	}
+	return nil
+}
+
+func (x *TxInput) GetPrevOutIndex() uint32 {
+	if x != nil {
+		return x.PrevOutIndex
+	}
+	return 0
+}
+
+func (x *TxInput) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *TxInput) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type TxOutput struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Amount        int64                  `protobuf:"varint,1,opt,name=amount,proto3" json:"amount,omitempty"`
+	Address       []byte                 `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TxOutput) Reset() {
+	*x = TxOutput{}
+	mi := &file_proto_block_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TxOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TxOutput) ProtoMessage() {}
+
+func (x *TxOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TxOutput.ProtoReflect.Descriptor instead.
+func (*TxOutput) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *TxOutput) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *TxOutput) GetAddress() []byte {
+	if x != nil {
+		return x.Address
+	}
+	return nil
+}
+
+type Transaction struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Inputs        []*TxInput             `protobuf:"bytes,2,rep,name=inputs,proto3" json:"inputs,omitempty"`
+	Outputs       []*TxOutput            `protobuf:"bytes,3,rep,name=outputs,proto3" json:"outputs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	mi := &file_proto_block_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Transaction) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Transaction) GetInputs() []*TxInput {
+	if x != nil {
+		return x.Inputs
+	}
+	return nil
+}
+
+func (x *Transaction) GetOutputs() []*TxOutput {
+	if x != nil {
+		return x.Outputs
+	}
+	return nil
+}
+
+type GetTransactionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hash          []byte                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTransactionRequest) Reset() {
+	*x = GetTransactionRequest{}
+	mi := &file_proto_block_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTransactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTransactionRequest) ProtoMessage() {}
+
+func (x *GetTransactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTransactionRequest.ProtoReflect.Descriptor instead.
+func (*GetTransactionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *GetTransactionRequest) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+type TransactionInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Transaction   *Transaction           `protobuf:"bytes,1,opt,name=transaction,proto3" json:"transaction,omitempty"`
+	Confirmed     bool                   `protobuf:"varint,2,opt,name=confirmed,proto3" json:"confirmed,omitempty"`
+	InMempool     bool                   `protobuf:"varint,3,opt,name=inMempool,proto3" json:"inMempool,omitempty"`
+	BlockHash     []byte                 `protobuf:"bytes,4,opt,name=blockHash,proto3" json:"blockHash,omitempty"`
+	BlockHeight   int32                  `protobuf:"varint,5,opt,name=blockHeight,proto3" json:"blockHeight,omitempty"`
+	Index         int32                  `protobuf:"varint,6,opt,name=index,proto3" json:"index,omitempty"`
+	Confirmations int32                  `protobuf:"varint,7,opt,name=confirmations,proto3" json:"confirmations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransactionInfo) Reset() {
+	*x = TransactionInfo{}
+	mi := &file_proto_block_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionInfo) ProtoMessage() {}
+
+func (x *TransactionInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionInfo.ProtoReflect.Descriptor instead.
+func (*TransactionInfo) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *TransactionInfo) GetTransaction() *Transaction {
+	if x != nil {
+		return x.Transaction
+	}
+	return nil
+}
+
+func (x *TransactionInfo) GetConfirmed() bool {
+	if x != nil {
+		return x.Confirmed
+	}
+	return false
+}
+
+func (x *TransactionInfo) GetInMempool() bool {
+	if x != nil {
+		return x.InMempool
+	}
+	return false
+}
+
+func (x *TransactionInfo) GetBlockHash() []byte {
+	if x != nil {
+		return x.BlockHash
+	}
+	return nil
+}
+
+func (x *TransactionInfo) GetBlockHeight() int32 {
+	if x != nil {
+		return x.BlockHeight
+	}
+	return 0
+}
+
+func (x *TransactionInfo) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *TransactionInfo) GetConfirmations() int32 {
+	if x != nil {
+		return x.Confirmations
+	}
+	return 0
+}
+
+type UTXO struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hash          []byte                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	OutIndex      uint32                 `protobuf:"varint,2,opt,name=outIndex,proto3" json:"outIndex,omitempty"`
+	Amount        int64                  `protobuf:"varint,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	Address       []byte                 `protobuf:"bytes,4,opt,name=address,proto3" json:"address,omitempty"`
+	Height        int32                  `protobuf:"varint,5,opt,name=height,proto3" json:"height,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UTXO) Reset() {
+	*x = UTXO{}
+	mi := &file_proto_block_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UTXO) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UTXO) ProtoMessage() {}
+
+func (x *UTXO) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UTXO.ProtoReflect.Descriptor instead.
+func (*UTXO) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *UTXO) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *UTXO) GetOutIndex() uint32 {
+	if x != nil {
+		return x.OutIndex
+	}
+	return 0
+}
+
+func (x *UTXO) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *UTXO) GetAddress() []byte {
+	if x != nil {
+		return x.Address
+	}
+	return nil
+}
+
+func (x *UTXO) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+type SnapshotRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Height        int32                  `protobuf:"varint,1,opt,name=height,proto3" json:"height,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SnapshotRequest) Reset() {
+	*x = SnapshotRequest{}
+	mi := &file_proto_block_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SnapshotRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotRequest) ProtoMessage() {}
+
+func (x *SnapshotRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnapshotRequest.ProtoReflect.Descriptor instead.
+func (*SnapshotRequest) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *SnapshotRequest) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+type UTXOSnapshot struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Height        int32                  `protobuf:"varint,1,opt,name=height,proto3" json:"height,omitempty"`
+	BlockHash     []byte                 `protobuf:"bytes,2,opt,name=blockHash,proto3" json:"blockHash,omitempty"`
+	Headers       []*Header              `protobuf:"bytes,3,rep,name=headers,proto3" json:"headers,omitempty"`
+	Utxos         []*UTXO                `protobuf:"bytes,4,rep,name=utxos,proto3" json:"utxos,omitempty"`
+	Commitment    []byte                 `protobuf:"bytes,5,opt,name=commitment,proto3" json:"commitment,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UTXOSnapshot) Reset() {
+	*x = UTXOSnapshot{}
+	mi := &file_proto_block_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UTXOSnapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UTXOSnapshot) ProtoMessage() {}
+
+func (x *UTXOSnapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UTXOSnapshot.ProtoReflect.Descriptor instead.
+func (*UTXOSnapshot) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *UTXOSnapshot) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *UTXOSnapshot) GetBlockHash() []byte {
+	if x != nil {
+		return x.BlockHash
+	}
+	return nil
+}
+
+func (x *UTXOSnapshot) GetHeaders() []*Header {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *UTXOSnapshot) GetUtxos() []*UTXO {
+	if x != nil {
+		return x.Utxos
+	}
+	return nil
+}
+
+func (x *UTXOSnapshot) GetCommitment() []byte {
+	if x != nil {
+		return x.Commitment
+	}
+	return nil
+}
+
+type UTXOProofRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TxHash        []byte                 `protobuf:"bytes,1,opt,name=txHash,proto3" json:"txHash,omitempty"`
+	OutIndex      uint32                 `protobuf:"varint,2,opt,name=outIndex,proto3" json:"outIndex,omitempty"`
+	Height        int32                  `protobuf:"varint,3,opt,name=height,proto3" json:"height,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UTXOProofRequest) Reset() {
+	*x = UTXOProofRequest{}
+	mi := &file_proto_block_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UTXOProofRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UTXOProofRequest) ProtoMessage() {}
+
+func (x *UTXOProofRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UTXOProofRequest.ProtoReflect.Descriptor instead.
+func (*UTXOProofRequest) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *UTXOProofRequest) GetTxHash() []byte {
+	if x != nil {
+		return x.TxHash
+	}
+	return nil
+}
+
+func (x *UTXOProofRequest) GetOutIndex() uint32 {
+	if x != nil {
+		return x.OutIndex
+	}
+	return 0
+}
+
+func (x *UTXOProofRequest) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+type UTXOProof struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Utxo          *UTXO                  `protobuf:"bytes,1,opt,name=utxo,proto3" json:"utxo,omitempty"`
+	Header        *Header                `protobuf:"bytes,2,opt,name=header,proto3" json:"header,omitempty"`
+	Siblings      [][]byte               `protobuf:"bytes,5,rep,name=siblings,proto3" json:"siblings,omitempty"` // sparse merkle tree path, from the root down
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UTXOProof) Reset() {
+	*x = UTXOProof{}
+	mi := &file_proto_block_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UTXOProof) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UTXOProof) ProtoMessage() {}
+
+func (x *UTXOProof) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UTXOProof.ProtoReflect.Descriptor instead.
+func (*UTXOProof) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *UTXOProof) GetUtxo() *UTXO {
+	if x != nil {
+		return x.Utxo
+	}
+	return nil
+}
+
+func (x *UTXOProof) GetHeader() *Header {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *UTXOProof) GetSiblings() [][]byte {
+	if x != nil {
+		return x.Siblings
+	}
+	return nil
+}
+
+type MempoolEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hash          []byte                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Fee           int64                  `protobuf:"varint,2,opt,name=fee,proto3" json:"fee,omitempty"`
+	Size          int32                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	FeeRate       int64                  `protobuf:"varint,4,opt,name=feeRate,proto3" json:"feeRate,omitempty"` // fee per 1000 bytes
+	Added         int64                  `protobuf:"varint,5,opt,name=added,proto3" json:"added,omitempty"`
+	Transaction   *Transaction           `protobuf:"bytes,6,opt,name=transaction,proto3" json:"transaction,omitempty"` // only set by GetMempoolEntry
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MempoolEntry) Reset() {
+	*x = MempoolEntry{}
+	mi := &file_proto_block_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MempoolEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MempoolEntry) ProtoMessage() {}
+
+func (x *MempoolEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MempoolEntry.ProtoReflect.Descriptor instead.
+func (*MempoolEntry) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *MempoolEntry) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *MempoolEntry) GetFee() int64 {
+	if x != nil {
+		return x.Fee
+	}
+	return 0
+}
+
+func (x *MempoolEntry) GetSize() int32 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *MempoolEntry) GetFeeRate() int64 {
+	if x != nil {
+		return x.FeeRate
+	}
+	return 0
+}
+
+func (x *MempoolEntry) GetAdded() int64 {
+	if x != nil {
+		return x.Added
+	}
+	return 0
+}
+
+func (x *MempoolEntry) GetTransaction() *Transaction {
+	if x != nil {
+		return x.Transaction
+	}
+	return nil
+}
+
+type MempoolList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entries       []*MempoolEntry        `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MempoolList) Reset() {
+	*x = MempoolList{}
+	mi := &file_proto_block_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MempoolList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MempoolList) ProtoMessage() {}
+
+func (x *MempoolList) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MempoolList.ProtoReflect.Descriptor instead.
+func (*MempoolList) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *MempoolList) GetEntries() []*MempoolEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type FeeRateBucket struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MinFeeRate    int64                  `protobuf:"varint,1,opt,name=minFeeRate,proto3" json:"minFeeRate,omitempty"`
+	Count         int32                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FeeRateBucket) Reset() {
+	*x = FeeRateBucket{}
+	mi := &file_proto_block_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FeeRateBucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FeeRateBucket) ProtoMessage() {}
+
+func (x *FeeRateBucket) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FeeRateBucket.ProtoReflect.Descriptor instead.
+func (*FeeRateBucket) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *FeeRateBucket) GetMinFeeRate() int64 {
+	if x != nil {
+		return x.MinFeeRate
+	}
+	return 0
+}
+
+func (x *FeeRateBucket) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *FeeRateBucket) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+type MempoolStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Count         int32                  `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Size          int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	MinFeeRate    int64                  `protobuf:"varint,3,opt,name=minFeeRate,proto3" json:"minFeeRate,omitempty"`
+	Histogram     []*FeeRateBucket       `protobuf:"bytes,4,rep,name=histogram,proto3" json:"histogram,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MempoolStats) Reset() {
+	*x = MempoolStats{}
+	mi := &file_proto_block_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MempoolStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MempoolStats) ProtoMessage() {}
+
+func (x *MempoolStats) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MempoolStats.ProtoReflect.Descriptor instead.
+func (*MempoolStats) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *MempoolStats) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *MempoolStats) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *MempoolStats) GetMinFeeRate() int64 {
+	if x != nil {
+		return x.MinFeeRate
+	}
+	return 0
+}
+
+func (x *MempoolStats) GetHistogram() []*FeeRateBucket {
+	if x != nil {
+		return x.Histogram
+	}
+	return nil
+}
+
+type MempoolEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Removed       bool                   `protobuf:"varint,1,opt,name=removed,proto3" json:"removed,omitempty"`
+	Reason        RemovalReason          `protobuf:"varint,2,opt,name=reason,proto3,enum=RemovalReason" json:"reason,omitempty"`
+	Entry         *MempoolEntry          `protobuf:"bytes,3,opt,name=entry,proto3" json:"entry,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MempoolEvent) Reset() {
+	*x = MempoolEvent{}
+	mi := &file_proto_block_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MempoolEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MempoolEvent) ProtoMessage() {}
+
+func (x *MempoolEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MempoolEvent.ProtoReflect.Descriptor instead.
+func (*MempoolEvent) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *MempoolEvent) GetRemoved() bool {
+	if x != nil {
+		return x.Removed
+	}
+	return false
+}
+
+func (x *MempoolEvent) GetReason() RemovalReason {
+	if x != nil {
+		return x.Reason
+	}
+	return RemovalReason_REMOVAL_NONE
+}
+
+func (x *MempoolEvent) GetEntry() *MempoolEntry {
+	if x != nil {
+		return x.Entry
+	}
+	return nil
+}
+
+type InvItem struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          InvType                `protobuf:"varint,1,opt,name=type,proto3,enum=InvType" json:"type,omitempty"`
+	Hash          []byte                 `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InvItem) Reset() {
+	*x = InvItem{}
+	mi := &file_proto_block_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InvItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InvItem) ProtoMessage() {}
+
+func (x *InvItem) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InvItem.ProtoReflect.Descriptor instead.
+func (*InvItem) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *InvItem) GetType() InvType {
+	if x != nil {
+		return x.Type
+	}
+	return InvType_INV_TX
+}
+
+func (x *InvItem) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+type InvMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Items         []*InvItem             `protobuf:"bytes,2,rep,name=items,proto3" json:"items,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InvMessage) Reset() {
+	*x = InvMessage{}
+	mi := &file_proto_block_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InvMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InvMessage) ProtoMessage() {}
+
+func (x *InvMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InvMessage.ProtoReflect.Descriptor instead.
+func (*InvMessage) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *InvMessage) GetItems() []*InvItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+type DataMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Transactions  []*Transaction         `protobuf:"bytes,1,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	Blocks        []*Block               `protobuf:"bytes,2,rep,name=blocks,proto3" json:"blocks,omitempty"`
+	NotFound      []*InvItem             `protobuf:"bytes,3,rep,name=notFound,proto3" json:"notFound,omitempty"`
+	CompactBlocks []*CompactBlock        `protobuf:"bytes,4,rep,name=compactBlocks,proto3" json:"compactBlocks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DataMessage) Reset() {
+	*x = DataMessage{}
+	mi := &file_proto_block_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DataMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DataMessage) ProtoMessage() {}
+
+func (x *DataMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DataMessage.ProtoReflect.Descriptor instead.
+func (*DataMessage) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *DataMessage) GetTransactions() []*Transaction {
+	if x != nil {
+		return x.Transactions
+	}
+	return nil
+}
+
+func (x *DataMessage) GetBlocks() []*Block {
+	if x != nil {
+		return x.Blocks
+	}
+	return nil
+}
+
+func (x *DataMessage) GetNotFound() []*InvItem {
+	if x != nil {
+		return x.NotFound
+	}
+	return nil
+}
+
+func (x *DataMessage) GetCompactBlocks() []*CompactBlock {
+	if x != nil {
+		return x.CompactBlocks
+	}
+	return nil
+}
+
+type PrefilledTransaction struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Transaction   *Transaction           `protobuf:"bytes,2,opt,name=transaction,proto3" json:"transaction,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PrefilledTransaction) Reset() {
+	*x = PrefilledTransaction{}
+	mi := &file_proto_block_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrefilledTransaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrefilledTransaction) ProtoMessage() {}
+
+func (x *PrefilledTransaction) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrefilledTransaction.ProtoReflect.Descriptor instead.
+func (*PrefilledTransaction) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *PrefilledTransaction) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *PrefilledTransaction) GetTransaction() *Transaction {
+	if x != nil {
+		return x.Transaction
+	}
+	return nil
+}
+
+// CompactBlock is a block with most txs replaced by short ids, which the
+// receiver resolves against its mempool.
+type CompactBlock struct {
+	state         protoimpl.MessageState  `protogen:"open.v1"`
+	Header        *Header                 `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	PublicKey     []byte                  `protobuf:"bytes,2,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	Signature     []byte                  `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+	Nonce         uint64                  `protobuf:"varint,4,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	ShortIds      []uint64                `protobuf:"varint,5,rep,packed,name=shortIds,proto3" json:"shortIds,omitempty"` // of the txs that are not prefilled, in block order
+	Prefilled     []*PrefilledTransaction `protobuf:"bytes,6,rep,name=prefilled,proto3" json:"prefilled,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompactBlock) Reset() {
+	*x = CompactBlock{}
+	mi := &file_proto_block_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompactBlock) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompactBlock) ProtoMessage() {}
+
+func (x *CompactBlock) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompactBlock.ProtoReflect.Descriptor instead.
+func (*CompactBlock) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *CompactBlock) GetHeader() *Header {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *CompactBlock) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *CompactBlock) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *CompactBlock) GetNonce() uint64 {
+	if x != nil {
+		return x.Nonce
+	}
+	return 0
+}
+
+func (x *CompactBlock) GetShortIds() []uint64 {
+	if x != nil {
+		return x.ShortIds
+	}
+	return nil
+}
+
+func (x *CompactBlock) GetPrefilled() []*PrefilledTransaction {
+	if x != nil {
+		return x.Prefilled
+	}
+	return nil
+}
+
+type BlockTxRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BlockHash     []byte                 `protobuf:"bytes,1,opt,name=blockHash,proto3" json:"blockHash,omitempty"`
+	Indexes       []int32                `protobuf:"varint,2,rep,packed,name=indexes,proto3" json:"indexes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BlockTxRequest) Reset() {
+	*x = BlockTxRequest{}
+	mi := &file_proto_block_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BlockTxRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockTxRequest) ProtoMessage() {}
+
+func (x *BlockTxRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockTxRequest.ProtoReflect.Descriptor instead.
+func (*BlockTxRequest) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *BlockTxRequest) GetBlockHash() []byte {
+	if x != nil {
+		return x.BlockHash
+	}
+	return nil
+}
+
+func (x *BlockTxRequest) GetIndexes() []int32 {
+	if x != nil {
+		return x.Indexes
+	}
+	return nil
+}
+
+type BlockTransactions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BlockHash     []byte                 `protobuf:"bytes,1,opt,name=blockHash,proto3" json:"blockHash,omitempty"`
+	Transactions  []*Transaction         `protobuf:"bytes,2,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BlockTransactions) Reset() {
+	*x = BlockTransactions{}
+	mi := &file_proto_block_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BlockTransactions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockTransactions) ProtoMessage() {}
+
+func (x *BlockTransactions) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockTransactions.ProtoReflect.Descriptor instead.
+func (*BlockTransactions) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *BlockTransactions) GetBlockHash() []byte {
+	if x != nil {
+		return x.BlockHash
+	}
+	return nil
 }
 
-func (x *PeerInfo) Reset() {
-	*x = PeerInfo{}
-	mi := &file_proto_block_proto_msgTypes[0]
+func (x *BlockTransactions) GetTransactions() []*Transaction {
+	if x != nil {
+		return x.Transactions
+	}
+	return nil
+}
+
+type Ban struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Addr          string                 `protobuf:"bytes,1,opt,name=addr,proto3" json:"addr,omitempty"`
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	Created       int64                  `protobuf:"varint,3,opt,name=created,proto3" json:"created,omitempty"` // unix seconds
+	Until         int64                  `protobuf:"varint,4,opt,name=until,proto3" json:"until,omitempty"`     // unix seconds
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Ban) Reset() {
+	*x = Ban{}
+	mi := &file_proto_block_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PeerInfo) String() string {
+func (x *Ban) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PeerInfo) ProtoMessage() {}
+func (*Ban) ProtoMessage() {}
 
-func (x *PeerInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_block_proto_msgTypes[0]
+func (x *Ban) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -57,65 +2342,61 @@ func (x *PeerInfo) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PeerInfo.ProtoReflect.Descriptor instead.
-func (*PeerInfo) Descriptor() ([]byte, []int) {
-	return file_proto_block_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use Ban.ProtoReflect.Descriptor instead.
+func (*Ban) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{31}
 }
 
-func (x *PeerInfo) GetProtocolVersion() int32 {
+func (x *Ban) GetAddr() string {
 	if x != nil {
-		return x.ProtocolVersion
+		return x.Addr
 	}
-	return 0
+	return ""
 }
 
-func (x *PeerInfo) GetBlockHeight() int32 {
+func (x *Ban) GetReason() string {
 	if x != nil {
-		return x.BlockHeight
+		return x.Reason
 	}
-	return 0
+	return ""
 }
 
-func (x *PeerInfo) GetListenAddr() string {
+func (x *Ban) GetCreated() int64 {
 	if x != nil {
-		return x.ListenAddr
+		return x.Created
 	}
-	return ""
+	return 0
 }
 
-func (x *PeerInfo) GetPeerList() []string {
+func (x *Ban) GetUntil() int64 {
 	if x != nil {
-		return x.PeerList
+		return x.Until
 	}
-	return nil
+	return 0
 }
 
-type Header struct {
+type BanList struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Version       int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
-	Height        int32                  `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
-	PrevHash      []byte                 `protobuf:"bytes,3,opt,name=prevHash,proto3" json:"prevHash,omitempty"`
-	RootHash      []byte                 `protobuf:"bytes,4,opt,name=rootHash,proto3" json:"rootHash,omitempty"`
-	Timestamp     int64                  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Bans          []*Ban                 `protobuf:"bytes,1,rep,name=bans,proto3" json:"bans,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Header) Reset() {
-	*x = Header{}
-	mi := &file_proto_block_proto_msgTypes[1]
+func (x *BanList) Reset() {
+	*x = BanList{}
+	mi := &file_proto_block_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Header) String() string {
+func (x *BanList) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Header) ProtoMessage() {}
+func (*BanList) ProtoMessage() {}
 
-func (x *Header) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_block_proto_msgTypes[1]
+func (x *BanList) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -126,71 +2407,107 @@ func (x *Header) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Header.ProtoReflect.Descriptor instead.
-func (*Header) Descriptor() ([]byte, []int) {
-	return file_proto_block_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use BanList.ProtoReflect.Descriptor instead.
+func (*BanList) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{32}
 }
 
-func (x *Header) GetVersion() int32 {
+func (x *BanList) GetBans() []*Ban {
 	if x != nil {
-		return x.Version
+		return x.Bans
 	}
-	return 0
+	return nil
 }
 
-func (x *Header) GetHeight() int32 {
+type BanRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Addr          string                 `protobuf:"bytes,1,opt,name=addr,proto3" json:"addr,omitempty"`
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	Duration      int64                  `protobuf:"varint,3,opt,name=duration,proto3" json:"duration,omitempty"` // seconds, at most a year
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BanRequest) Reset() {
+	*x = BanRequest{}
+	mi := &file_proto_block_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BanRequest) ProtoMessage() {}
+
+func (x *BanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[33]
 	if x != nil {
-		return x.Height
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return 0
+	return mi.MessageOf(x)
 }
 
-func (x *Header) GetPrevHash() []byte {
+// Deprecated: Use BanRequest.ProtoReflect.Descriptor instead.
+func (*BanRequest) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *BanRequest) GetAddr() string {
 	if x != nil {
-		return x.PrevHash
+		return x.Addr
 	}
-	return nil
+	return ""
 }
 
-func (x *Header) GetRootHash() []byte {
+func (x *BanRequest) GetReason() string {
 	if x != nil {
-		return x.RootHash
+		return x.Reason
 	}
-	return nil
+	return ""
 }
 
-func (x *Header) GetTimestamp() int64 {
+func (x *BanRequest) GetDuration() int64 {
 	if x != nil {
-		return x.Timestamp
+		return x.Duration
 	}
 	return 0
 }
 
-type Block struct {
+type PeerStats struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Header        *Header                `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
-	Transactions  []*Transaction         `protobuf:"bytes,2,rep,name=transactions,proto3" json:"transactions,omitempty"`
-	PublicKey     []byte                 `protobuf:"bytes,3,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
-	Signature     []byte                 `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Addr          string                 `protobuf:"bytes,2,opt,name=addr,proto3" json:"addr,omitempty"`
+	Inbound       bool                   `protobuf:"varint,3,opt,name=inbound,proto3" json:"inbound,omitempty"`
+	Version       int32                  `protobuf:"varint,4,opt,name=version,proto3" json:"version,omitempty"`
+	Latency       int64                  `protobuf:"varint,5,opt,name=latency,proto3" json:"latency,omitempty"` // nanoseconds, the round trip of the last ping
+	Sent          int64                  `protobuf:"varint,6,opt,name=sent,proto3" json:"sent,omitempty"`
+	Failed        int64                  `protobuf:"varint,7,opt,name=failed,proto3" json:"failed,omitempty"`   // requests that got no answer
+	Dropped       int64                  `protobuf:"varint,8,opt,name=dropped,proto3" json:"dropped,omitempty"` // messages the queue had no room for
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Block) Reset() {
-	*x = Block{}
-	mi := &file_proto_block_proto_msgTypes[2]
+func (x *PeerStats) Reset() {
+	*x = PeerStats{}
+	mi := &file_proto_block_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Block) String() string {
+func (x *PeerStats) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Block) ProtoMessage() {}
+func (*PeerStats) ProtoMessage() {}
 
-func (x *Block) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_block_proto_msgTypes[2]
+func (x *PeerStats) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -201,64 +2518,89 @@ func (x *Block) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Block.ProtoReflect.Descriptor instead.
-func (*Block) Descriptor() ([]byte, []int) {
-	return file_proto_block_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use PeerStats.ProtoReflect.Descriptor instead.
+func (*PeerStats) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{34}
 }
 
-func (x *Block) GetHeader() *Header {
+func (x *PeerStats) GetId() string {
 	if x != nil {
-		return x.Header
+		return x.Id
 	}
-	return nil
+	return ""
 }
 
-func (x *Block) GetTransactions() []*Transaction {
+func (x *PeerStats) GetAddr() string {
 	if x != nil {
-		return x.Transactions
+		return x.Addr
 	}
-	return nil
+	return ""
 }
 
-func (x *Block) GetPublicKey() []byte {
+func (x *PeerStats) GetInbound() bool {
 	if x != nil {
-		return x.PublicKey
+		return x.Inbound
 	}
-	return nil
+	return false
 }
 
-func (x *Block) GetSignature() []byte {
+func (x *PeerStats) GetVersion() int32 {
 	if x != nil {
-		return x.Signature
+		return x.Version
 	}
-	return nil
+	return 0
 }
 
-type TxInput struct {
+func (x *PeerStats) GetLatency() int64 {
+	if x != nil {
+		return x.Latency
+	}
+	return 0
+}
+
+func (x *PeerStats) GetSent() int64 {
+	if x != nil {
+		return x.Sent
+	}
+	return 0
+}
+
+func (x *PeerStats) GetFailed() int64 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *PeerStats) GetDropped() int64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+type PeerStatsList struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	PrevTxHash    []byte                 `protobuf:"bytes,1,opt,name=prevTxHash,proto3" json:"prevTxHash,omitempty"`
-	PrevOutIndex  uint32                 `protobuf:"varint,2,opt,name=prevOutIndex,proto3" json:"prevOutIndex,omitempty"`
-	PublicKey     []byte                 `protobuf:"bytes,3,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
-	Signature     []byte                 `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	Peers         []*PeerStats           `protobuf:"bytes,1,rep,name=peers,proto3" json:"peers,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *TxInput) Reset() {
-	*x = TxInput{}
-	mi := &file_proto_block_proto_msgTypes[3]
+func (x *PeerStatsList) Reset() {
+	*x = PeerStatsList{}
+	mi := &file_proto_block_proto_msgTypes[35]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *TxInput) String() string {
+func (x *PeerStatsList) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*TxInput) ProtoMessage() {}
+func (*PeerStatsList) ProtoMessage() {}
 
-func (x *TxInput) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_block_proto_msgTypes[3]
+func (x *PeerStatsList) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[35]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -269,62 +2611,45 @@ func (x *TxInput) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use TxInput.ProtoReflect.Descriptor instead.
-func (*TxInput) Descriptor() ([]byte, []int) {
-	return file_proto_block_proto_rawDescGZIP(), []int{3}
-}
-
-func (x *TxInput) GetPrevTxHash() []byte {
-	if x != nil {
-		return x.PrevTxHash
-	}
-	return nil
-}
-
-func (x *TxInput) GetPrevOutIndex() uint32 {
-	if x != nil {
-		return x.PrevOutIndex
-	}
-	return 0
-}
-
-func (x *TxInput) GetPublicKey() []byte {
-	if x != nil {
-		return x.PublicKey
-	}
-	return nil
+// Deprecated: Use PeerStatsList.ProtoReflect.Descriptor instead.
+func (*PeerStatsList) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{35}
 }
 
-func (x *TxInput) GetSignature() []byte {
+func (x *PeerStatsList) GetPeers() []*PeerStats {
 	if x != nil {
-		return x.Signature
+		return x.Peers
 	}
 	return nil
 }
 
-type TxOutput struct {
+type KnownAddr struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Amount        int64                  `protobuf:"varint,1,opt,name=amount,proto3" json:"amount,omitempty"`
-	Address       []byte                 `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	Addr          string                 `protobuf:"bytes,1,opt,name=addr,proto3" json:"addr,omitempty"`
+	LastSeen      int64                  `protobuf:"varint,2,opt,name=lastSeen,proto3" json:"lastSeen,omitempty"` // unix seconds
+	LastSuccess   int64                  `protobuf:"varint,3,opt,name=lastSuccess,proto3" json:"lastSuccess,omitempty"`
+	LastAttempt   int64                  `protobuf:"varint,4,opt,name=lastAttempt,proto3" json:"lastAttempt,omitempty"`
+	Successes     int32                  `protobuf:"varint,5,opt,name=successes,proto3" json:"successes,omitempty"`
+	Failures      int32                  `protobuf:"varint,6,opt,name=failures,proto3" json:"failures,omitempty"` // in a row
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *TxOutput) Reset() {
-	*x = TxOutput{}
-	mi := &file_proto_block_proto_msgTypes[4]
+func (x *KnownAddr) Reset() {
+	*x = KnownAddr{}
+	mi := &file_proto_block_proto_msgTypes[36]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *TxOutput) String() string {
+func (x *KnownAddr) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*TxOutput) ProtoMessage() {}
+func (*KnownAddr) ProtoMessage() {}
 
-func (x *TxOutput) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_block_proto_msgTypes[4]
+func (x *KnownAddr) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[36]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -335,49 +2660,75 @@ func (x *TxOutput) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use TxOutput.ProtoReflect.Descriptor instead.
-func (*TxOutput) Descriptor() ([]byte, []int) {
-	return file_proto_block_proto_rawDescGZIP(), []int{4}
+// Deprecated: Use KnownAddr.ProtoReflect.Descriptor instead.
+func (*KnownAddr) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{36}
 }
 
-func (x *TxOutput) GetAmount() int64 {
+func (x *KnownAddr) GetAddr() string {
 	if x != nil {
-		return x.Amount
+		return x.Addr
+	}
+	return ""
+}
+
+func (x *KnownAddr) GetLastSeen() int64 {
+	if x != nil {
+		return x.LastSeen
 	}
 	return 0
 }
 
-func (x *TxOutput) GetAddress() []byte {
+func (x *KnownAddr) GetLastSuccess() int64 {
 	if x != nil {
-		return x.Address
+		return x.LastSuccess
 	}
-	return nil
+	return 0
 }
 
-type Transaction struct {
+func (x *KnownAddr) GetLastAttempt() int64 {
+	if x != nil {
+		return x.LastAttempt
+	}
+	return 0
+}
+
+func (x *KnownAddr) GetSuccesses() int32 {
+	if x != nil {
+		return x.Successes
+	}
+	return 0
+}
+
+func (x *KnownAddr) GetFailures() int32 {
+	if x != nil {
+		return x.Failures
+	}
+	return 0
+}
+
+type AddrList struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Version       int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
-	Inputs        []*TxInput             `protobuf:"bytes,2,rep,name=inputs,proto3" json:"inputs,omitempty"`
-	Outputs       []*TxOutput            `protobuf:"bytes,3,rep,name=outputs,proto3" json:"outputs,omitempty"`
+	Addrs         []*KnownAddr           `protobuf:"bytes,1,rep,name=addrs,proto3" json:"addrs,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Transaction) Reset() {
-	*x = Transaction{}
-	mi := &file_proto_block_proto_msgTypes[5]
+func (x *AddrList) Reset() {
+	*x = AddrList{}
+	mi := &file_proto_block_proto_msgTypes[37]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Transaction) String() string {
+func (x *AddrList) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Transaction) ProtoMessage() {}
+func (*AddrList) ProtoMessage() {}
 
-func (x *Transaction) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_block_proto_msgTypes[5]
+func (x *AddrList) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_block_proto_msgTypes[37]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -388,28 +2739,14 @@ func (x *Transaction) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
-func (*Transaction) Descriptor() ([]byte, []int) {
-	return file_proto_block_proto_rawDescGZIP(), []int{5}
-}
-
-func (x *Transaction) GetVersion() int32 {
-	if x != nil {
-		return x.Version
-	}
-	return 0
-}
-
-func (x *Transaction) GetInputs() []*TxInput {
-	if x != nil {
-		return x.Inputs
-	}
-	return nil
+// Deprecated: Use AddrList.ProtoReflect.Descriptor instead.
+func (*AddrList) Descriptor() ([]byte, []int) {
+	return file_proto_block_proto_rawDescGZIP(), []int{37}
 }
 
-func (x *Transaction) GetOutputs() []*TxOutput {
+func (x *AddrList) GetAddrs() []*KnownAddr {
 	if x != nil {
-		return x.Outputs
+		return x.Addrs
 	}
 	return nil
 }
@@ -418,20 +2755,62 @@ var File_proto_block_proto protoreflect.FileDescriptor
 
 const file_proto_block_proto_rawDesc = "" +
 	"\n" +
-	"\x11proto/block.proto\x1a\x1bgoogle/protobuf/empty.proto\"\x92\x01\n" +
+	"\x11proto/block.proto\x1a\x1bgoogle/protobuf/empty.proto\"\xb9\x05\n" +
+	"\bEnvelope\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x1a\n" +
+	"\bresponse\x18\x02 \x01(\bR\bresponse\x12 \n" +
+	"\x05error\x18\x03 \x01(\v2\n" +
+	".PeerErrorR\x05error\x12)\n" +
+	"\x05hello\x18\n" +
+	" \x01(\v2\x11.ChallengeRequestH\x00R\x05hello\x122\n" +
+	"\tchallenge\x18\v \x01(\v2\x12.ChallengeResponseH\x00R\tchallenge\x12'\n" +
+	"\bpeerInfo\x18\f \x01(\v2\t.PeerInfoH\x00R\bpeerInfo\x12\x1e\n" +
+	"\x02tx\x18\r \x01(\v2\f.TransactionH\x00R\x02tx\x12\x1e\n" +
+	"\x05block\x18\x0e \x01(\v2\x06.BlockH\x00R\x05block\x12\x1f\n" +
+	"\x03inv\x18\x0f \x01(\v2\v.InvMessageH\x00R\x03inv\x12/\n" +
+	"\vdataRequest\x18\x10 \x01(\v2\v.InvMessageH\x00R\vdataRequest\x12\"\n" +
+	"\x04data\x18\x11 \x01(\v2\f.DataMessageH\x00R\x04data\x12;\n" +
+	"\x0fblockTxsRequest\x18\x12 \x01(\v2\x0f.BlockTxRequestH\x00R\x0fblockTxsRequest\x120\n" +
+	"\bblockTxs\x18\x13 \x01(\v2\x12.BlockTransactionsH\x00R\bblockTxs\x12<\n" +
+	"\faddrsRequest\x18\x14 \x01(\v2\x16.google.protobuf.EmptyH\x00R\faddrsRequest\x12!\n" +
+	"\x05addrs\x18\x15 \x01(\v2\t.AddrListH\x00R\x05addrs\x12\"\n" +
+	"\x04ping\x18\x16 \x01(\v2\f.PingMessageH\x00R\x04ping\x12\"\n" +
+	"\x04pong\x18\x17 \x01(\v2\f.PongMessageH\x00R\x04pongB\t\n" +
+	"\apayload\"9\n" +
+	"\tPeerError\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\rR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"\xb8\x02\n" +
 	"\bPeerInfo\x12(\n" +
 	"\x0fprotocolVersion\x18\x01 \x01(\x05R\x0fprotocolVersion\x12 \n" +
 	"\vblockHeight\x18\x02 \x01(\x05R\vblockHeight\x12\x1e\n" +
 	"\n" +
 	"listenAddr\x18\x03 \x01(\tR\n" +
 	"listenAddr\x12\x1a\n" +
-	"\bpeerList\x18\x04 \x03(\tR\bpeerList\"\x90\x01\n" +
+	"\bpeerList\x18\x04 \x03(\tR\bpeerList\x12\x1c\n" +
+	"\tpublicKey\x18\x05 \x01(\fR\tpublicKey\x12\x1c\n" +
+	"\tchallenge\x18\x06 \x01(\fR\tchallenge\x12\x1c\n" +
+	"\tsignature\x18\a \x01(\fR\tsignature\x12.\n" +
+	"\x12minProtocolVersion\x18\b \x01(\x05R\x12minProtocolVersion\x12\x1a\n" +
+	"\bservices\x18\t \x01(\x04R\bservices\"(\n" +
+	"\x10ChallengeRequest\x12\x14\n" +
+	"\x05nonce\x18\x01 \x01(\fR\x05nonce\"m\n" +
+	"\x11ChallengeResponse\x12\x1c\n" +
+	"\tpublicKey\x18\x01 \x01(\fR\tpublicKey\x12\x1c\n" +
+	"\tsignature\x18\x02 \x01(\fR\tsignature\x12\x1c\n" +
+	"\tchallenge\x18\x03 \x01(\fR\tchallenge\"A\n" +
+	"\vPingMessage\x12\x14\n" +
+	"\x05nonce\x18\x01 \x01(\x04R\x05nonce\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\"A\n" +
+	"\vPongMessage\x12\x14\n" +
+	"\x05nonce\x18\x01 \x01(\x04R\x05nonce\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\"\xae\x01\n" +
 	"\x06Header\x12\x18\n" +
 	"\aversion\x18\x01 \x01(\x05R\aversion\x12\x16\n" +
 	"\x06height\x18\x02 \x01(\x05R\x06height\x12\x1a\n" +
 	"\bprevHash\x18\x03 \x01(\fR\bprevHash\x12\x1a\n" +
 	"\brootHash\x18\x04 \x01(\fR\brootHash\x12\x1c\n" +
-	"\ttimestamp\x18\x05 \x01(\x03R\ttimestamp\"\x96\x01\n" +
+	"\ttimestamp\x18\x05 \x01(\x03R\ttimestamp\x12\x1c\n" +
+	"\tstateRoot\x18\x06 \x01(\fR\tstateRoot\"\x96\x01\n" +
 	"\x05Block\x12\x1f\n" +
 	"\x06header\x18\x01 \x01(\v2\a.HeaderR\x06header\x120\n" +
 	"\ftransactions\x18\x02 \x03(\v2\f.TransactionR\ftransactions\x12\x1c\n" +
@@ -450,10 +2829,158 @@ const file_proto_block_proto_rawDesc = "" +
 	"\vTransaction\x12\x18\n" +
 	"\aversion\x18\x01 \x01(\x05R\aversion\x12 \n" +
 	"\x06inputs\x18\x02 \x03(\v2\b.TxInputR\x06inputs\x12#\n" +
-	"\aoutputs\x18\x03 \x03(\v2\t.TxOutputR\aoutputs2d\n" +
-	"\x04Node\x12!\n" +
-	"\tHandshake\x12\t.PeerInfo\x1a\t.PeerInfo\x129\n" +
-	"\x11HandleTransaction\x12\f.Transaction\x1a\x16.google.protobuf.EmptyB!Z\x1fgithub.com/pdrm26/blocker/protob\x06proto3"
+	"\aoutputs\x18\x03 \x03(\v2\t.TxOutputR\aoutputs\"+\n" +
+	"\x15GetTransactionRequest\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\fR\x04hash\"\xf9\x01\n" +
+	"\x0fTransactionInfo\x12.\n" +
+	"\vtransaction\x18\x01 \x01(\v2\f.TransactionR\vtransaction\x12\x1c\n" +
+	"\tconfirmed\x18\x02 \x01(\bR\tconfirmed\x12\x1c\n" +
+	"\tinMempool\x18\x03 \x01(\bR\tinMempool\x12\x1c\n" +
+	"\tblockHash\x18\x04 \x01(\fR\tblockHash\x12 \n" +
+	"\vblockHeight\x18\x05 \x01(\x05R\vblockHeight\x12\x14\n" +
+	"\x05index\x18\x06 \x01(\x05R\x05index\x12$\n" +
+	"\rconfirmations\x18\a \x01(\x05R\rconfirmations\"\x80\x01\n" +
+	"\x04UTXO\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\fR\x04hash\x12\x1a\n" +
+	"\boutIndex\x18\x02 \x01(\rR\boutIndex\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x03R\x06amount\x12\x18\n" +
+	"\aaddress\x18\x04 \x01(\fR\aaddress\x12\x16\n" +
+	"\x06height\x18\x05 \x01(\x05R\x06height\")\n" +
+	"\x0fSnapshotRequest\x12\x16\n" +
+	"\x06height\x18\x01 \x01(\x05R\x06height\"\xa4\x01\n" +
+	"\fUTXOSnapshot\x12\x16\n" +
+	"\x06height\x18\x01 \x01(\x05R\x06height\x12\x1c\n" +
+	"\tblockHash\x18\x02 \x01(\fR\tblockHash\x12!\n" +
+	"\aheaders\x18\x03 \x03(\v2\a.HeaderR\aheaders\x12\x1b\n" +
+	"\x05utxos\x18\x04 \x03(\v2\x05.UTXOR\x05utxos\x12\x1e\n" +
+	"\n" +
+	"commitment\x18\x05 \x01(\fR\n" +
+	"commitment\"^\n" +
+	"\x10UTXOProofRequest\x12\x16\n" +
+	"\x06txHash\x18\x01 \x01(\fR\x06txHash\x12\x1a\n" +
+	"\boutIndex\x18\x02 \x01(\rR\boutIndex\x12\x16\n" +
+	"\x06height\x18\x03 \x01(\x05R\x06height\"o\n" +
+	"\tUTXOProof\x12\x19\n" +
+	"\x04utxo\x18\x01 \x01(\v2\x05.UTXOR\x04utxo\x12\x1f\n" +
+	"\x06header\x18\x02 \x01(\v2\a.HeaderR\x06header\x12\x1a\n" +
+	"\bsiblings\x18\x05 \x03(\fR\bsiblingsJ\x04\b\x03\x10\x04J\x04\b\x04\x10\x05\"\xa8\x01\n" +
+	"\fMempoolEntry\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\fR\x04hash\x12\x10\n" +
+	"\x03fee\x18\x02 \x01(\x03R\x03fee\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x05R\x04size\x12\x18\n" +
+	"\afeeRate\x18\x04 \x01(\x03R\afeeRate\x12\x14\n" +
+	"\x05added\x18\x05 \x01(\x03R\x05added\x12.\n" +
+	"\vtransaction\x18\x06 \x01(\v2\f.TransactionR\vtransaction\"6\n" +
+	"\vMempoolList\x12'\n" +
+	"\aentries\x18\x01 \x03(\v2\r.MempoolEntryR\aentries\"Y\n" +
+	"\rFeeRateBucket\x12\x1e\n" +
+	"\n" +
+	"minFeeRate\x18\x01 \x01(\x03R\n" +
+	"minFeeRate\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x05R\x05count\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\"\x86\x01\n" +
+	"\fMempoolStats\x12\x14\n" +
+	"\x05count\x18\x01 \x01(\x05R\x05count\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\x12\x1e\n" +
+	"\n" +
+	"minFeeRate\x18\x03 \x01(\x03R\n" +
+	"minFeeRate\x12,\n" +
+	"\thistogram\x18\x04 \x03(\v2\x0e.FeeRateBucketR\thistogram\"u\n" +
+	"\fMempoolEvent\x12\x18\n" +
+	"\aremoved\x18\x01 \x01(\bR\aremoved\x12&\n" +
+	"\x06reason\x18\x02 \x01(\x0e2\x0e.RemovalReasonR\x06reason\x12#\n" +
+	"\x05entry\x18\x03 \x01(\v2\r.MempoolEntryR\x05entry\";\n" +
+	"\aInvItem\x12\x1c\n" +
+	"\x04type\x18\x01 \x01(\x0e2\b.InvTypeR\x04type\x12\x12\n" +
+	"\x04hash\x18\x02 \x01(\fR\x04hash\"2\n" +
+	"\n" +
+	"InvMessage\x12\x1e\n" +
+	"\x05items\x18\x02 \x03(\v2\b.InvItemR\x05itemsJ\x04\b\x01\x10\x02\"\xba\x01\n" +
+	"\vDataMessage\x120\n" +
+	"\ftransactions\x18\x01 \x03(\v2\f.TransactionR\ftransactions\x12\x1e\n" +
+	"\x06blocks\x18\x02 \x03(\v2\x06.BlockR\x06blocks\x12$\n" +
+	"\bnotFound\x18\x03 \x03(\v2\b.InvItemR\bnotFound\x123\n" +
+	"\rcompactBlocks\x18\x04 \x03(\v2\r.CompactBlockR\rcompactBlocks\"\\\n" +
+	"\x14PrefilledTransaction\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12.\n" +
+	"\vtransaction\x18\x02 \x01(\v2\f.TransactionR\vtransaction\"\xd2\x01\n" +
+	"\fCompactBlock\x12\x1f\n" +
+	"\x06header\x18\x01 \x01(\v2\a.HeaderR\x06header\x12\x1c\n" +
+	"\tpublicKey\x18\x02 \x01(\fR\tpublicKey\x12\x1c\n" +
+	"\tsignature\x18\x03 \x01(\fR\tsignature\x12\x14\n" +
+	"\x05nonce\x18\x04 \x01(\x04R\x05nonce\x12\x1a\n" +
+	"\bshortIds\x18\x05 \x03(\x04R\bshortIds\x123\n" +
+	"\tprefilled\x18\x06 \x03(\v2\x15.PrefilledTransactionR\tprefilled\"H\n" +
+	"\x0eBlockTxRequest\x12\x1c\n" +
+	"\tblockHash\x18\x01 \x01(\fR\tblockHash\x12\x18\n" +
+	"\aindexes\x18\x02 \x03(\x05R\aindexes\"c\n" +
+	"\x11BlockTransactions\x12\x1c\n" +
+	"\tblockHash\x18\x01 \x01(\fR\tblockHash\x120\n" +
+	"\ftransactions\x18\x02 \x03(\v2\f.TransactionR\ftransactions\"a\n" +
+	"\x03Ban\x12\x12\n" +
+	"\x04addr\x18\x01 \x01(\tR\x04addr\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x12\x18\n" +
+	"\acreated\x18\x03 \x01(\x03R\acreated\x12\x14\n" +
+	"\x05until\x18\x04 \x01(\x03R\x05until\"#\n" +
+	"\aBanList\x12\x18\n" +
+	"\x04bans\x18\x01 \x03(\v2\x04.BanR\x04bans\"T\n" +
+	"\n" +
+	"BanRequest\x12\x12\n" +
+	"\x04addr\x18\x01 \x01(\tR\x04addr\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x12\x1a\n" +
+	"\bduration\x18\x03 \x01(\x03R\bduration\"\xc3\x01\n" +
+	"\tPeerStats\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04addr\x18\x02 \x01(\tR\x04addr\x12\x18\n" +
+	"\ainbound\x18\x03 \x01(\bR\ainbound\x12\x18\n" +
+	"\aversion\x18\x04 \x01(\x05R\aversion\x12\x18\n" +
+	"\alatency\x18\x05 \x01(\x03R\alatency\x12\x12\n" +
+	"\x04sent\x18\x06 \x01(\x03R\x04sent\x12\x16\n" +
+	"\x06failed\x18\a \x01(\x03R\x06failed\x12\x18\n" +
+	"\adropped\x18\b \x01(\x03R\adropped\"1\n" +
+	"\rPeerStatsList\x12 \n" +
+	"\x05peers\x18\x01 \x03(\v2\n" +
+	".PeerStatsR\x05peers\"\xb9\x01\n" +
+	"\tKnownAddr\x12\x12\n" +
+	"\x04addr\x18\x01 \x01(\tR\x04addr\x12\x1a\n" +
+	"\blastSeen\x18\x02 \x01(\x03R\blastSeen\x12 \n" +
+	"\vlastSuccess\x18\x03 \x01(\x03R\vlastSuccess\x12 \n" +
+	"\vlastAttempt\x18\x04 \x01(\x03R\vlastAttempt\x12\x1c\n" +
+	"\tsuccesses\x18\x05 \x01(\x05R\tsuccesses\x12\x1a\n" +
+	"\bfailures\x18\x06 \x01(\x05R\bfailures\",\n" +
+	"\bAddrList\x12 \n" +
+	"\x05addrs\x18\x01 \x03(\v2\n" +
+	".KnownAddrR\x05addrs*\x9d\x01\n" +
+	"\rRemovalReason\x12\x10\n" +
+	"\fREMOVAL_NONE\x10\x00\x12\x11\n" +
+	"\rREMOVAL_MINED\x10\x01\x12\x14\n" +
+	"\x10REMOVAL_REPLACED\x10\x02\x12\x13\n" +
+	"\x0fREMOVAL_EVICTED\x10\x03\x12\x13\n" +
+	"\x0fREMOVAL_EXPIRED\x10\x04\x12\x11\n" +
+	"\rREMOVAL_RESET\x10\x05\x12\x14\n" +
+	"\x10REMOVAL_CONFLICT\x10\x06*;\n" +
+	"\aInvType\x12\n" +
+	"\n" +
+	"\x06INV_TX\x10\x00\x12\r\n" +
+	"\tINV_BLOCK\x10\x01\x12\x15\n" +
+	"\x11INV_COMPACT_BLOCK\x10\x022\xe6\x03\n" +
+	"\x04Node\x12#\n" +
+	"\aConnect\x12\t.Envelope\x1a\t.Envelope(\x010\x01\x129\n" +
+	"\x11HandleTransaction\x12\f.Transaction\x1a\x16.google.protobuf.Empty\x12-\n" +
+	"\vHandleBlock\x12\x06.Block\x1a\x16.google.protobuf.Empty\x12:\n" +
+	"\x0eGetTransaction\x12\x16.GetTransactionRequest\x1a\x10.TransactionInfo\x12-\n" +
+	"\fGetUTXOProof\x12\x11.UTXOProofRequest\x1a\n" +
+	".UTXOProof\x123\n" +
+	"\vListMempool\x12\x16.google.protobuf.Empty\x1a\f.MempoolList\x128\n" +
+	"\x0fGetMempoolEntry\x12\x16.GetTransactionRequest\x1a\r.MempoolEntry\x128\n" +
+	"\x0fGetMempoolStats\x12\x16.google.protobuf.Empty\x1a\r.MempoolStats\x12;\n" +
+	"\x10SubscribeMempool\x12\x16.google.protobuf.Empty\x1a\r.MempoolEvent0\x012\xff\x01\n" +
+	"\x05Admin\x12,\n" +
+	"\bListBans\x12\x16.google.protobuf.Empty\x1a\b.BanList\x12.\n" +
+	"\aBanPeer\x12\v.BanRequest\x1a\x16.google.protobuf.Empty\x120\n" +
+	"\tUnbanPeer\x12\v.BanRequest\x1a\x16.google.protobuf.Empty\x121\n" +
+	"\x0eExportSnapshot\x12\x10.SnapshotRequest\x1a\r.UTXOSnapshot\x123\n" +
+	"\tListPeers\x12\x16.google.protobuf.Empty\x1a\x0e.PeerStatsListB!Z\x1fgithub.com/pdrm26/blocker/protob\x06proto3"
 
 var (
 	file_proto_block_proto_rawDescOnce sync.Once
@@ -467,30 +2994,127 @@ func file_proto_block_proto_rawDescGZIP() []byte {
 	return file_proto_block_proto_rawDescData
 }
 
-var file_proto_block_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_proto_block_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_proto_block_proto_msgTypes = make([]protoimpl.MessageInfo, 38)
 var file_proto_block_proto_goTypes = []any{
-	(*PeerInfo)(nil),      // 0: PeerInfo
-	(*Header)(nil),        // 1: Header
-	(*Block)(nil),         // 2: Block
-	(*TxInput)(nil),       // 3: TxInput
-	(*TxOutput)(nil),      // 4: TxOutput
-	(*Transaction)(nil),   // 5: Transaction
-	(*emptypb.Empty)(nil), // 6: google.protobuf.Empty
+	(RemovalReason)(0),            // 0: RemovalReason
+	(InvType)(0),                  // 1: InvType
+	(*Envelope)(nil),              // 2: Envelope
+	(*PeerError)(nil),             // 3: PeerError
+	(*PeerInfo)(nil),              // 4: PeerInfo
+	(*ChallengeRequest)(nil),      // 5: ChallengeRequest
+	(*ChallengeResponse)(nil),     // 6: ChallengeResponse
+	(*PingMessage)(nil),           // 7: PingMessage
+	(*PongMessage)(nil),           // 8: PongMessage
+	(*Header)(nil),                // 9: Header
+	(*Block)(nil),                 // 10: Block
+	(*TxInput)(nil),               // 11: TxInput
+	(*TxOutput)(nil),              // 12: TxOutput
+	(*Transaction)(nil),           // 13: Transaction
+	(*GetTransactionRequest)(nil), // 14: GetTransactionRequest
+	(*TransactionInfo)(nil),       // 15: TransactionInfo
+	(*UTXO)(nil),                  // 16: UTXO
+	(*SnapshotRequest)(nil),       // 17: SnapshotRequest
+	(*UTXOSnapshot)(nil),          // 18: UTXOSnapshot
+	(*UTXOProofRequest)(nil),      // 19: UTXOProofRequest
+	(*UTXOProof)(nil),             // 20: UTXOProof
+	(*MempoolEntry)(nil),          // 21: MempoolEntry
+	(*MempoolList)(nil),           // 22: MempoolList
+	(*FeeRateBucket)(nil),         // 23: FeeRateBucket
+	(*MempoolStats)(nil),          // 24: MempoolStats
+	(*MempoolEvent)(nil),          // 25: MempoolEvent
+	(*InvItem)(nil),               // 26: InvItem
+	(*InvMessage)(nil),            // 27: InvMessage
+	(*DataMessage)(nil),           // 28: DataMessage
+	(*PrefilledTransaction)(nil),  // 29: PrefilledTransaction
+	(*CompactBlock)(nil),          // 30: CompactBlock
+	(*BlockTxRequest)(nil),        // 31: BlockTxRequest
+	(*BlockTransactions)(nil),     // 32: BlockTransactions
+	(*Ban)(nil),                   // 33: Ban
+	(*BanList)(nil),               // 34: BanList
+	(*BanRequest)(nil),            // 35: BanRequest
+	(*PeerStats)(nil),             // 36: PeerStats
+	(*PeerStatsList)(nil),         // 37: PeerStatsList
+	(*KnownAddr)(nil),             // 38: KnownAddr
+	(*AddrList)(nil),              // 39: AddrList
+	(*emptypb.Empty)(nil),         // 40: google.protobuf.Empty
 }
 var file_proto_block_proto_depIdxs = []int32{
-	1, // 0: Block.header:type_name -> Header
-	5, // 1: Block.transactions:type_name -> Transaction
-	3, // 2: Transaction.inputs:type_name -> TxInput
-	4, // 3: Transaction.outputs:type_name -> TxOutput
-	0, // 4: Node.Handshake:input_type -> PeerInfo
-	5, // 5: Node.HandleTransaction:input_type -> Transaction
-	0, // 6: Node.Handshake:output_type -> PeerInfo
-	6, // 7: Node.HandleTransaction:output_type -> google.protobuf.Empty
-	6, // [6:8] is the sub-list for method output_type
-	4, // [4:6] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	3,  // 0: Envelope.error:type_name -> PeerError
+	5,  // 1: Envelope.hello:type_name -> ChallengeRequest
+	6,  // 2: Envelope.challenge:type_name -> ChallengeResponse
+	4,  // 3: Envelope.peerInfo:type_name -> PeerInfo
+	13, // 4: Envelope.tx:type_name -> Transaction
+	10, // 5: Envelope.block:type_name -> Block
+	27, // 6: Envelope.inv:type_name -> InvMessage
+	27, // 7: Envelope.dataRequest:type_name -> InvMessage
+	28, // 8: Envelope.data:type_name -> DataMessage
+	31, // 9: Envelope.blockTxsRequest:type_name -> BlockTxRequest
+	32, // 10: Envelope.blockTxs:type_name -> BlockTransactions
+	40, // 11: Envelope.addrsRequest:type_name -> google.protobuf.Empty
+	39, // 12: Envelope.addrs:type_name -> AddrList
+	7,  // 13: Envelope.ping:type_name -> PingMessage
+	8,  // 14: Envelope.pong:type_name -> PongMessage
+	9,  // 15: Block.header:type_name -> Header
+	13, // 16: Block.transactions:type_name -> Transaction
+	11, // 17: Transaction.inputs:type_name -> TxInput
+	12, // 18: Transaction.outputs:type_name -> TxOutput
+	13, // 19: TransactionInfo.transaction:type_name -> Transaction
+	9,  // 20: UTXOSnapshot.headers:type_name -> Header
+	16, // 21: UTXOSnapshot.utxos:type_name -> UTXO
+	16, // 22: UTXOProof.utxo:type_name -> UTXO
+	9,  // 23: UTXOProof.header:type_name -> Header
+	13, // 24: MempoolEntry.transaction:type_name -> Transaction
+	21, // 25: MempoolList.entries:type_name -> MempoolEntry
+	23, // 26: MempoolStats.histogram:type_name -> FeeRateBucket
+	0,  // 27: MempoolEvent.reason:type_name -> RemovalReason
+	21, // 28: MempoolEvent.entry:type_name -> MempoolEntry
+	1,  // 29: InvItem.type:type_name -> InvType
+	26, // 30: InvMessage.items:type_name -> InvItem
+	13, // 31: DataMessage.transactions:type_name -> Transaction
+	10, // 32: DataMessage.blocks:type_name -> Block
+	26, // 33: DataMessage.notFound:type_name -> InvItem
+	30, // 34: DataMessage.compactBlocks:type_name -> CompactBlock
+	13, // 35: PrefilledTransaction.transaction:type_name -> Transaction
+	9,  // 36: CompactBlock.header:type_name -> Header
+	29, // 37: CompactBlock.prefilled:type_name -> PrefilledTransaction
+	13, // 38: BlockTransactions.transactions:type_name -> Transaction
+	33, // 39: BanList.bans:type_name -> Ban
+	36, // 40: PeerStatsList.peers:type_name -> PeerStats
+	38, // 41: AddrList.addrs:type_name -> KnownAddr
+	2,  // 42: Node.Connect:input_type -> Envelope
+	13, // 43: Node.HandleTransaction:input_type -> Transaction
+	10, // 44: Node.HandleBlock:input_type -> Block
+	14, // 45: Node.GetTransaction:input_type -> GetTransactionRequest
+	19, // 46: Node.GetUTXOProof:input_type -> UTXOProofRequest
+	40, // 47: Node.ListMempool:input_type -> google.protobuf.Empty
+	14, // 48: Node.GetMempoolEntry:input_type -> GetTransactionRequest
+	40, // 49: Node.GetMempoolStats:input_type -> google.protobuf.Empty
+	40, // 50: Node.SubscribeMempool:input_type -> google.protobuf.Empty
+	40, // 51: Admin.ListBans:input_type -> google.protobuf.Empty
+	35, // 52: Admin.BanPeer:input_type -> BanRequest
+	35, // 53: Admin.UnbanPeer:input_type -> BanRequest
+	17, // 54: Admin.ExportSnapshot:input_type -> SnapshotRequest
+	40, // 55: Admin.ListPeers:input_type -> google.protobuf.Empty
+	2,  // 56: Node.Connect:output_type -> Envelope
+	40, // 57: Node.HandleTransaction:output_type -> google.protobuf.Empty
+	40, // 58: Node.HandleBlock:output_type -> google.protobuf.Empty
+	15, // 59: Node.GetTransaction:output_type -> TransactionInfo
+	20, // 60: Node.GetUTXOProof:output_type -> UTXOProof
+	22, // 61: Node.ListMempool:output_type -> MempoolList
+	21, // 62: Node.GetMempoolEntry:output_type -> MempoolEntry
+	24, // 63: Node.GetMempoolStats:output_type -> MempoolStats
+	25, // 64: Node.SubscribeMempool:output_type -> MempoolEvent
+	34, // 65: Admin.ListBans:output_type -> BanList
+	40, // 66: Admin.BanPeer:output_type -> google.protobuf.Empty
+	40, // 67: Admin.UnbanPeer:output_type -> google.protobuf.Empty
+	18, // 68: Admin.ExportSnapshot:output_type -> UTXOSnapshot
+	37, // 69: Admin.ListPeers:output_type -> PeerStatsList
+	56, // [56:70] is the sub-list for method output_type
+	42, // [42:56] is the sub-list for method input_type
+	42, // [42:42] is the sub-list for extension type_name
+	42, // [42:42] is the sub-list for extension extendee
+	0,  // [0:42] is the sub-list for field type_name
 }
 
 func init() { file_proto_block_proto_init() }
@@ -498,18 +3122,35 @@ func file_proto_block_proto_init() {
 	if File_proto_block_proto != nil {
 		return
 	}
+	file_proto_block_proto_msgTypes[0].OneofWrappers = []any{
+		(*Envelope_Hello)(nil),
+		(*Envelope_Challenge)(nil),
+		(*Envelope_PeerInfo)(nil),
+		(*Envelope_Tx)(nil),
+		(*Envelope_Block)(nil),
+		(*Envelope_Inv)(nil),
+		(*Envelope_DataRequest)(nil),
+		(*Envelope_Data)(nil),
+		(*Envelope_BlockTxsRequest)(nil),
+		(*Envelope_BlockTxs)(nil),
+		(*Envelope_AddrsRequest)(nil),
+		(*Envelope_Addrs)(nil),
+		(*Envelope_Ping)(nil),
+		(*Envelope_Pong)(nil),
+	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_block_proto_rawDesc), len(file_proto_block_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   6,
+			NumEnums:      2,
+			NumMessages:   38,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   2,
 		},
 		GoTypes:           file_proto_block_proto_goTypes,
 		DependencyIndexes: file_proto_block_proto_depIdxs,
+		EnumInfos:         file_proto_block_proto_enumTypes,
 		MessageInfos:      file_proto_block_proto_msgTypes,
 	}.Build()
 	File_proto_block_proto = out.File
@@ -20,16 +20,30 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	Node_Handshake_FullMethodName         = "/Node/Handshake"
+	Node_Connect_FullMethodName           = "/Node/Connect"
 	Node_HandleTransaction_FullMethodName = "/Node/HandleTransaction"
+	Node_HandleBlock_FullMethodName       = "/Node/HandleBlock"
+	Node_GetTransaction_FullMethodName    = "/Node/GetTransaction"
+	Node_GetUTXOProof_FullMethodName      = "/Node/GetUTXOProof"
+	Node_ListMempool_FullMethodName       = "/Node/ListMempool"
+	Node_GetMempoolEntry_FullMethodName   = "/Node/GetMempoolEntry"
+	Node_GetMempoolStats_FullMethodName   = "/Node/GetMempoolStats"
+	Node_SubscribeMempool_FullMethodName  = "/Node/SubscribeMempool"
 )
 
 // NodeClient is the client API for Node service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type NodeClient interface {
-	Handshake(ctx context.Context, in *PeerInfo, opts ...grpc.CallOption) (*PeerInfo, error)
+	Connect(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Envelope, Envelope], error)
 	HandleTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	HandleBlock(ctx context.Context, in *Block, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	GetTransaction(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*TransactionInfo, error)
+	GetUTXOProof(ctx context.Context, in *UTXOProofRequest, opts ...grpc.CallOption) (*UTXOProof, error)
+	ListMempool(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*MempoolList, error)
+	GetMempoolEntry(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*MempoolEntry, error)
+	GetMempoolStats(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*MempoolStats, error)
+	SubscribeMempool(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MempoolEvent], error)
 }
 
 type nodeClient struct {
@@ -40,16 +54,19 @@ func NewNodeClient(cc grpc.ClientConnInterface) NodeClient {
 	return &nodeClient{cc}
 }
 
-func (c *nodeClient) Handshake(ctx context.Context, in *PeerInfo, opts ...grpc.CallOption) (*PeerInfo, error) {
+func (c *nodeClient) Connect(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Envelope, Envelope], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(PeerInfo)
-	err := c.cc.Invoke(ctx, Node_Handshake_FullMethodName, in, out, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &Node_ServiceDesc.Streams[0], Node_Connect_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	x := &grpc.GenericClientStream[Envelope, Envelope]{ClientStream: stream}
+	return x, nil
 }
 
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Node_ConnectClient = grpc.BidiStreamingClient[Envelope, Envelope]
+
 func (c *nodeClient) HandleTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(emptypb.Empty)
@@ -60,12 +77,98 @@ func (c *nodeClient) HandleTransaction(ctx context.Context, in *Transaction, opt
 	return out, nil
 }
 
+func (c *nodeClient) HandleBlock(ctx context.Context, in *Block, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Node_HandleBlock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) GetTransaction(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*TransactionInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransactionInfo)
+	err := c.cc.Invoke(ctx, Node_GetTransaction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) GetUTXOProof(ctx context.Context, in *UTXOProofRequest, opts ...grpc.CallOption) (*UTXOProof, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UTXOProof)
+	err := c.cc.Invoke(ctx, Node_GetUTXOProof_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) ListMempool(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*MempoolList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MempoolList)
+	err := c.cc.Invoke(ctx, Node_ListMempool_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) GetMempoolEntry(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*MempoolEntry, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MempoolEntry)
+	err := c.cc.Invoke(ctx, Node_GetMempoolEntry_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) GetMempoolStats(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*MempoolStats, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MempoolStats)
+	err := c.cc.Invoke(ctx, Node_GetMempoolStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) SubscribeMempool(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MempoolEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Node_ServiceDesc.Streams[1], Node_SubscribeMempool_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[emptypb.Empty, MempoolEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Node_SubscribeMempoolClient = grpc.ServerStreamingClient[MempoolEvent]
+
 // NodeServer is the server API for Node service.
 // All implementations must embed UnimplementedNodeServer
 // for forward compatibility.
 type NodeServer interface {
-	Handshake(context.Context, *PeerInfo) (*PeerInfo, error)
+	Connect(grpc.BidiStreamingServer[Envelope, Envelope]) error
 	HandleTransaction(context.Context, *Transaction) (*emptypb.Empty, error)
+	HandleBlock(context.Context, *Block) (*emptypb.Empty, error)
+	GetTransaction(context.Context, *GetTransactionRequest) (*TransactionInfo, error)
+	GetUTXOProof(context.Context, *UTXOProofRequest) (*UTXOProof, error)
+	ListMempool(context.Context, *emptypb.Empty) (*MempoolList, error)
+	GetMempoolEntry(context.Context, *GetTransactionRequest) (*MempoolEntry, error)
+	GetMempoolStats(context.Context, *emptypb.Empty) (*MempoolStats, error)
+	SubscribeMempool(*emptypb.Empty, grpc.ServerStreamingServer[MempoolEvent]) error
 	mustEmbedUnimplementedNodeServer()
 }
 
@@ -76,12 +179,33 @@ type NodeServer interface {
 // pointer dereference when methods are called.
 type UnimplementedNodeServer struct{}
 
-func (UnimplementedNodeServer) Handshake(context.Context, *PeerInfo) (*PeerInfo, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Handshake not implemented")
+func (UnimplementedNodeServer) Connect(grpc.BidiStreamingServer[Envelope, Envelope]) error {
+	return status.Errorf(codes.Unimplemented, "method Connect not implemented")
 }
 func (UnimplementedNodeServer) HandleTransaction(context.Context, *Transaction) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method HandleTransaction not implemented")
 }
+func (UnimplementedNodeServer) HandleBlock(context.Context, *Block) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HandleBlock not implemented")
+}
+func (UnimplementedNodeServer) GetTransaction(context.Context, *GetTransactionRequest) (*TransactionInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransaction not implemented")
+}
+func (UnimplementedNodeServer) GetUTXOProof(context.Context, *UTXOProofRequest) (*UTXOProof, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUTXOProof not implemented")
+}
+func (UnimplementedNodeServer) ListMempool(context.Context, *emptypb.Empty) (*MempoolList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMempool not implemented")
+}
+func (UnimplementedNodeServer) GetMempoolEntry(context.Context, *GetTransactionRequest) (*MempoolEntry, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMempoolEntry not implemented")
+}
+func (UnimplementedNodeServer) GetMempoolStats(context.Context, *emptypb.Empty) (*MempoolStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMempoolStats not implemented")
+}
+func (UnimplementedNodeServer) SubscribeMempool(*emptypb.Empty, grpc.ServerStreamingServer[MempoolEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeMempool not implemented")
+}
 func (UnimplementedNodeServer) mustEmbedUnimplementedNodeServer() {}
 func (UnimplementedNodeServer) testEmbeddedByValue()              {}
 
@@ -103,42 +227,150 @@ func RegisterNodeServer(s grpc.ServiceRegistrar, srv NodeServer) {
 	s.RegisterService(&Node_ServiceDesc, srv)
 }
 
-func _Node_Handshake_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(PeerInfo)
+func _Node_Connect_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(NodeServer).Connect(&grpc.GenericServerStream[Envelope, Envelope]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Node_ConnectServer = grpc.BidiStreamingServer[Envelope, Envelope]
+
+func _Node_HandleTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Transaction)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(NodeServer).Handshake(ctx, in)
+		return srv.(NodeServer).HandleTransaction(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: Node_Handshake_FullMethodName,
+		FullMethod: Node_HandleTransaction_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(NodeServer).Handshake(ctx, req.(*PeerInfo))
+		return srv.(NodeServer).HandleTransaction(ctx, req.(*Transaction))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _Node_HandleTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Transaction)
+func _Node_HandleBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Block)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(NodeServer).HandleTransaction(ctx, in)
+		return srv.(NodeServer).HandleBlock(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: Node_HandleTransaction_FullMethodName,
+		FullMethod: Node_HandleBlock_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(NodeServer).HandleTransaction(ctx, req.(*Transaction))
+		return srv.(NodeServer).HandleBlock(ctx, req.(*Block))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Node_GetTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).GetTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_GetTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).GetTransaction(ctx, req.(*GetTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_GetUTXOProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UTXOProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).GetUTXOProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_GetUTXOProof_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).GetUTXOProof(ctx, req.(*UTXOProofRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_ListMempool_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).ListMempool(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_ListMempool_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).ListMempool(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_GetMempoolEntry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).GetMempoolEntry(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_GetMempoolEntry_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).GetMempoolEntry(ctx, req.(*GetTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_GetMempoolStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).GetMempoolStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_GetMempoolStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).GetMempoolStats(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_SubscribeMempool_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(emptypb.Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NodeServer).SubscribeMempool(m, &grpc.GenericServerStream[emptypb.Empty, MempoolEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Node_SubscribeMempoolServer = grpc.ServerStreamingServer[MempoolEvent]
+
 // Node_ServiceDesc is the grpc.ServiceDesc for Node service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -146,14 +378,308 @@ var Node_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "Node",
 	HandlerType: (*NodeServer)(nil),
 	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "Handshake",
-			Handler:    _Node_Handshake_Handler,
-		},
 		{
 			MethodName: "HandleTransaction",
 			Handler:    _Node_HandleTransaction_Handler,
 		},
+		{
+			MethodName: "HandleBlock",
+			Handler:    _Node_HandleBlock_Handler,
+		},
+		{
+			MethodName: "GetTransaction",
+			Handler:    _Node_GetTransaction_Handler,
+		},
+		{
+			MethodName: "GetUTXOProof",
+			Handler:    _Node_GetUTXOProof_Handler,
+		},
+		{
+			MethodName: "ListMempool",
+			Handler:    _Node_ListMempool_Handler,
+		},
+		{
+			MethodName: "GetMempoolEntry",
+			Handler:    _Node_GetMempoolEntry_Handler,
+		},
+		{
+			MethodName: "GetMempoolStats",
+			Handler:    _Node_GetMempoolStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Connect",
+			Handler:       _Node_Connect_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "SubscribeMempool",
+			Handler:       _Node_SubscribeMempool_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/block.proto",
+}
+
+const (
+	Admin_ListBans_FullMethodName       = "/Admin/ListBans"
+	Admin_BanPeer_FullMethodName        = "/Admin/BanPeer"
+	Admin_UnbanPeer_FullMethodName      = "/Admin/UnbanPeer"
+	Admin_ExportSnapshot_FullMethodName = "/Admin/ExportSnapshot"
+	Admin_ListPeers_FullMethodName      = "/Admin/ListPeers"
+)
+
+// AdminClient is the client API for Admin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Admin changes how the node treats its peers, and exports the UTXO
+// snapshots another node can be started from. It is served apart from Node,
+// to local callers only.
+type AdminClient interface {
+	ListBans(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*BanList, error)
+	BanPeer(ctx context.Context, in *BanRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	UnbanPeer(ctx context.Context, in *BanRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ExportSnapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*UTXOSnapshot, error)
+	ListPeers(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*PeerStatsList, error)
+}
+
+type adminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminClient(cc grpc.ClientConnInterface) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) ListBans(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*BanList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BanList)
+	err := c.cc.Invoke(ctx, Admin_ListBans_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) BanPeer(ctx context.Context, in *BanRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Admin_BanPeer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) UnbanPeer(ctx context.Context, in *BanRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Admin_UnbanPeer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ExportSnapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*UTXOSnapshot, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UTXOSnapshot)
+	err := c.cc.Invoke(ctx, Admin_ExportSnapshot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListPeers(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*PeerStatsList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PeerStatsList)
+	err := c.cc.Invoke(ctx, Admin_ListPeers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServer is the server API for Admin service.
+// All implementations must embed UnimplementedAdminServer
+// for forward compatibility.
+//
+// Admin changes how the node treats its peers, and exports the UTXO
+// snapshots another node can be started from. It is served apart from Node,
+// to local callers only.
+type AdminServer interface {
+	ListBans(context.Context, *emptypb.Empty) (*BanList, error)
+	BanPeer(context.Context, *BanRequest) (*emptypb.Empty, error)
+	UnbanPeer(context.Context, *BanRequest) (*emptypb.Empty, error)
+	ExportSnapshot(context.Context, *SnapshotRequest) (*UTXOSnapshot, error)
+	ListPeers(context.Context, *emptypb.Empty) (*PeerStatsList, error)
+	mustEmbedUnimplementedAdminServer()
+}
+
+// UnimplementedAdminServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAdminServer struct{}
+
+func (UnimplementedAdminServer) ListBans(context.Context, *emptypb.Empty) (*BanList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBans not implemented")
+}
+func (UnimplementedAdminServer) BanPeer(context.Context, *BanRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BanPeer not implemented")
+}
+func (UnimplementedAdminServer) UnbanPeer(context.Context, *BanRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnbanPeer not implemented")
+}
+func (UnimplementedAdminServer) ExportSnapshot(context.Context, *SnapshotRequest) (*UTXOSnapshot, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportSnapshot not implemented")
+}
+func (UnimplementedAdminServer) ListPeers(context.Context, *emptypb.Empty) (*PeerStatsList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPeers not implemented")
+}
+func (UnimplementedAdminServer) mustEmbedUnimplementedAdminServer() {}
+func (UnimplementedAdminServer) testEmbeddedByValue()               {}
+
+// UnsafeAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServer will
+// result in compilation errors.
+type UnsafeAdminServer interface {
+	mustEmbedUnimplementedAdminServer()
+}
+
+func RegisterAdminServer(s grpc.ServiceRegistrar, srv AdminServer) {
+	// If the following call pancis, it indicates UnimplementedAdminServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Admin_ServiceDesc, srv)
+}
+
+func _Admin_ListBans_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListBans(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_ListBans_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListBans(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_BanPeer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).BanPeer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_BanPeer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).BanPeer(ctx, req.(*BanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_UnbanPeer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).UnbanPeer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_UnbanPeer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).UnbanPeer(ctx, req.(*BanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ExportSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ExportSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_ExportSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ExportSnapshot(ctx, req.(*SnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListPeers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListPeers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_ListPeers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListPeers(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Admin_ServiceDesc is the grpc.ServiceDesc for Admin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Admin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListBans",
+			Handler:    _Admin_ListBans_Handler,
+		},
+		{
+			MethodName: "BanPeer",
+			Handler:    _Admin_BanPeer_Handler,
+		},
+		{
+			MethodName: "UnbanPeer",
+			Handler:    _Admin_UnbanPeer_Handler,
+		},
+		{
+			MethodName: "ExportSnapshot",
+			Handler:    _Admin_ExportSnapshot_Handler,
+		},
+		{
+			MethodName: "ListPeers",
+			Handler:    _Admin_ListPeers_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/block.proto",
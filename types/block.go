@@ -2,21 +2,23 @@ package types
 
 import (
 	"crypto/sha256"
-	"encoding/json"
 
 	"github.com/pdrm26/blocker/crypto"
 	"github.com/pdrm26/blocker/proto"
 )
 
 func VerifyBlock(block *proto.Block) bool {
-	if len(block.PublicKey) != crypto.PublicKeySize {
+	if block.Header == nil {
 		return false
 	}
-	if len(block.Signature) != crypto.SignatureLen {
+	sig, err := crypto.SignatureFromBytes(block.Signature)
+	if err != nil {
+		return false
+	}
+	pubKey, err := crypto.PublicKeyFromBytes(block.PublicKey)
+	if err != nil {
 		return false
 	}
-	sig := crypto.SignatureFromBytes(block.Signature)
-	pubKey := crypto.PublicKeyFromBytes(block.PublicKey)
 	return sig.Verify(pubKey, HashBlock(block))
 }
 
@@ -25,13 +27,9 @@ func HashBlock(block *proto.Block) []byte {
 	return HashHeader(block.Header)
 }
 
+// HashHeader returns a SHA256 of the canonical encoding of the header.
 func HashHeader(header *proto.Header) []byte {
-	b, err := json.Marshal(header)
-	if err != nil {
-		panic(err)
-	}
-
-	hash := sha256.Sum256(b)
+	hash := sha256.Sum256(EncodeHeader(header))
 	return hash[:]
 }
 
@@ -0,0 +1,68 @@
+package types
+
+import (
+	"encoding/binary"
+
+	"github.com/pdrm26/blocker/proto"
+)
+
+// EncodingVersion is the first byte of every canonical encoding. Bumping it
+// is the only way the hash of an existing header or transaction may change.
+const EncodingVersion byte = 1
+
+// EncodeHeader returns the canonical binary encoding of a header, which is
+// what block hashes and block signatures are computed over. Fields are
+// written in a fixed order: integers big endian, byte strings prefixed with
+// their length as a uvarint.
+func EncodeHeader(header *proto.Header) []byte {
+	buf := []byte{EncodingVersion}
+	buf = binary.BigEndian.AppendUint32(buf, uint32(header.Version))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(header.Height))
+	buf = appendBytes(buf, header.PrevHash)
+	buf = appendBytes(buf, header.RootHash)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(header.Timestamp))
+	buf = appendBytes(buf, header.StateRoot)
+	return buf
+}
+
+// EncodeTransaction returns the canonical binary encoding of a transaction,
+// signatures included. Its hash identifies the transaction.
+func EncodeTransaction(tx *proto.Transaction) []byte {
+	return encodeTransaction(tx, true)
+}
+
+// EncodeTransactionForSigning is the canonical encoding of a transaction with
+// every input signature left out, which is what each input signs.
+func EncodeTransactionForSigning(tx *proto.Transaction) []byte {
+	return encodeTransaction(tx, false)
+}
+
+func encodeTransaction(tx *proto.Transaction, withSignatures bool) []byte {
+	buf := []byte{EncodingVersion}
+	buf = binary.BigEndian.AppendUint32(buf, uint32(tx.Version))
+
+	buf = binary.AppendUvarint(buf, uint64(len(tx.Inputs)))
+	for _, input := range tx.Inputs {
+		buf = appendBytes(buf, input.PrevTxHash)
+		buf = binary.BigEndian.AppendUint32(buf, input.PrevOutIndex)
+		buf = appendBytes(buf, input.PublicKey)
+		if withSignatures {
+			buf = appendBytes(buf, input.Signature)
+		} else {
+			buf = appendBytes(buf, nil)
+		}
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(tx.Outputs)))
+	for _, output := range tx.Outputs {
+		buf = binary.BigEndian.AppendUint64(buf, uint64(output.Amount))
+		buf = appendBytes(buf, output.Address)
+	}
+
+	return buf
+}
+
+func appendBytes(buf, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
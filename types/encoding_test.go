@@ -0,0 +1,87 @@
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/pdrm26/blocker/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func vectorHeader() *proto.Header {
+	return &proto.Header{
+		Version:   1,
+		Height:    7,
+		PrevHash:  bytes.Repeat([]byte{0x01}, 32),
+		RootHash:  bytes.Repeat([]byte{0x02}, 32),
+		Timestamp: 1700000000,
+		StateRoot: bytes.Repeat([]byte{0x03}, 32),
+	}
+}
+
+func vectorTransaction() *proto.Transaction {
+	return &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   bytes.Repeat([]byte{0x04}, 32),
+				PrevOutIndex: 1,
+				PublicKey:    bytes.Repeat([]byte{0x05}, 32),
+				Signature:    bytes.Repeat([]byte{0x06}, 64),
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  99,
+				Address: bytes.Repeat([]byte{0x07}, 20),
+			},
+		},
+	}
+}
+
+// The vectors below pin the canonical encoding. If one of them changes, every
+// block and transaction hash on the network changes with it.
+
+func TestEncodeHeaderVector(t *testing.T) {
+	header := vectorHeader()
+
+	assert.Equal(t,
+		"01"+"00000001"+"00000007"+
+			"20"+"0101010101010101010101010101010101010101010101010101010101010101"+
+			"20"+"0202020202020202020202020202020202020202020202020202020202020202"+
+			"000000006553f100"+
+			"20"+"0303030303030303030303030303030303030303030303030303030303030303",
+		hex.EncodeToString(EncodeHeader(header)),
+	)
+	assert.Equal(t, "edcb538bab4bcee9ad3a1fd2586ba132c59dccb31eefd0e511e8adf2d8a81d80", hex.EncodeToString(HashHeader(header)))
+	assert.Equal(t, "21fc3f955c14305ed66b2f6064de082e8447f29048da3ab7c5c01090c1b722ab", hex.EncodeToString(HashHeader(&proto.Header{})))
+}
+
+func TestEncodeTransactionVector(t *testing.T) {
+	tx := vectorTransaction()
+
+	assert.Equal(t,
+		"01"+"00000001"+
+			"01"+
+			"20"+"0404040404040404040404040404040404040404040404040404040404040404"+
+			"00000001"+
+			"20"+"0505050505050505050505050505050505050505050505050505050505050505"+
+			"40"+"06060606060606060606060606060606060606060606060606060606060606060606060606060606060606060606060606060606060606060606060606060606"+
+			"01"+
+			"0000000000000063"+
+			"14"+"0707070707070707070707070707070707070707",
+		hex.EncodeToString(EncodeTransaction(tx)),
+	)
+	assert.Equal(t, "1c58bdfd05227f898f777c6408d0f2d8bade7e2f2f662054c21fd844935f6821", hex.EncodeToString(HashTransaction(tx)))
+	assert.Equal(t, "c76fa5774492f999ffb9d1dcca4600cbdab0edf999bca7e9592080ee0de02e00", hex.EncodeToString(hashTransactionForSigning(tx)))
+}
+
+func TestSigningEncodingIgnoresSignatures(t *testing.T) {
+	tx := vectorTransaction()
+	signed := EncodeTransactionForSigning(tx)
+
+	tx.Inputs[0].Signature = nil
+	assert.Equal(t, signed, EncodeTransactionForSigning(tx))
+	assert.NotEqual(t, signed, EncodeTransaction(vectorTransaction()))
+}
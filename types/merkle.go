@@ -0,0 +1,131 @@
+package types
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"github.com/pdrm26/blocker/proto"
+)
+
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleProof is the path from a leaf up to the root. Count is the number of
+// leaves in the tree, which tells at which levels the leaf had no sibling.
+type MerkleProof struct {
+	Index    int
+	Count    int
+	Siblings [][]byte
+}
+
+func hashMerkleLeaf(leaf []byte) []byte {
+	hash := sha256.Sum256(append([]byte{merkleLeafPrefix}, leaf...))
+	return hash[:]
+}
+
+func hashMerkleNode(left, right []byte) []byte {
+	b := make([]byte, 0, 1+len(left)+len(right))
+	b = append(b, merkleNodePrefix)
+	b = append(b, left...)
+	b = append(b, right...)
+
+	hash := sha256.Sum256(b)
+	return hash[:]
+}
+
+// nextMerkleLevel pairs up the nodes of a level. An odd node at the end is
+// carried up unchanged instead of being hashed with itself.
+func nextMerkleLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+			continue
+		}
+		next = append(next, hashMerkleNode(level[i], level[i+1]))
+	}
+
+	return next
+}
+
+// MerkleRoot returns the root of a Merkle tree over the leaves. The root of
+// an empty tree is the SHA256 of nothing.
+func MerkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		hash := sha256.Sum256(nil)
+		return hash[:]
+	}
+
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = hashMerkleLeaf(leaf)
+	}
+	for len(level) > 1 {
+		level = nextMerkleLevel(level)
+	}
+
+	return level[0]
+}
+
+func BuildMerkleProof(leaves [][]byte, index int) *MerkleProof {
+	if index < 0 || index >= len(leaves) {
+		return nil
+	}
+
+	proof := &MerkleProof{Index: index, Count: len(leaves)}
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = hashMerkleLeaf(leaf)
+	}
+
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof.Siblings = append(proof.Siblings, level[sibling])
+		}
+		level = nextMerkleLevel(level)
+		index /= 2
+	}
+
+	return proof
+}
+
+func VerifyMerkleProof(root, leaf []byte, proof *MerkleProof) bool {
+	if proof == nil || proof.Index < 0 || proof.Index >= proof.Count {
+		return false
+	}
+
+	hash := hashMerkleLeaf(leaf)
+	index, count, next := proof.Index, proof.Count, 0
+	for count > 1 {
+		sibling := index ^ 1
+		if sibling < count {
+			if next >= len(proof.Siblings) {
+				return false
+			}
+			if index%2 == 0 {
+				hash = hashMerkleNode(hash, proof.Siblings[next])
+			} else {
+				hash = hashMerkleNode(proof.Siblings[next], hash)
+			}
+			next++
+		}
+		index /= 2
+		count = (count + 1) / 2
+	}
+
+	return next == len(proof.Siblings) && bytes.Equal(hash, root)
+}
+
+// CalculateRootHash returns the Merkle root over the hashes of the
+// transactions, which goes into the block header.
+func CalculateRootHash(txs []*proto.Transaction) []byte {
+	leaves := make([][]byte, len(txs))
+	for i, tx := range txs {
+		leaves[i] = HashTransaction(tx)
+	}
+
+	return MerkleRoot(leaves)
+}
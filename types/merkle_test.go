@@ -0,0 +1,35 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/pdrm26/blocker/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerkleProof(t *testing.T) {
+	for count := 1; count <= 9; count++ {
+		leaves := make([][]byte, count)
+		for i := range leaves {
+			leaves[i] = utils.RandomHash()
+		}
+		root := MerkleRoot(leaves)
+
+		for i, leaf := range leaves {
+			proof := BuildMerkleProof(leaves, i)
+			assert.True(t, VerifyMerkleProof(root, leaf, proof))
+			assert.False(t, VerifyMerkleProof(root, utils.RandomHash(), proof))
+		}
+	}
+
+	assert.Nil(t, BuildMerkleProof([][]byte{utils.RandomHash()}, 1))
+}
+
+func TestMerkleRootChangesWithLeaves(t *testing.T) {
+	leaves := [][]byte{utils.RandomHash(), utils.RandomHash(), utils.RandomHash()}
+	root := MerkleRoot(leaves)
+
+	assert.NotEqual(t, root, MerkleRoot(leaves[:2]))
+	assert.NotEqual(t, root, MerkleRoot([][]byte{leaves[1], leaves[0], leaves[2]}))
+	assert.Equal(t, 32, len(MerkleRoot(nil)))
+}
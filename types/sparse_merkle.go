@@ -0,0 +1,194 @@
+package types
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// SparseMerkleTree commits to a set of keyed leaves. The bits of the SHA256
+// of a key give its path from the root, and a subtree holding a single leaf
+// is replaced by that leaf, so the root only depends on the leaves and not
+// on the order they were set in, and paths stay about as long as the log of
+// the number of leaves. Set and Delete return a new tree and leave the old
+// one as it was, sharing the nodes that did not change. The zero value is
+// the empty tree.
+type SparseMerkleTree struct {
+	root *smtNode
+	size int
+}
+
+// smtNode is a leaf when it has no children, a branch holding at least two
+// leaves below it otherwise.
+type smtNode struct {
+	hash     []byte
+	path     [32]byte
+	leaf     []byte
+	children [2]*smtNode
+}
+
+func (n *smtNode) isLeaf() bool {
+	return n.children[0] == nil && n.children[1] == nil
+}
+
+func smtHash(n *smtNode) []byte {
+	if n == nil {
+		hash := sha256.Sum256(nil)
+		return hash[:]
+	}
+	return n.hash
+}
+
+// smtLeafHash commits to the path along with the leaf, so a leaf can't be
+// passed off as the one of another key sharing the start of its path.
+func smtLeafHash(path [32]byte, leaf []byte) []byte {
+	return hashMerkleLeaf(append(path[:], leaf...))
+}
+
+func smtBit(path [32]byte, depth int) int {
+	return int(path[depth/8]>>(7-depth%8)) & 1
+}
+
+func newSMTBranch(children [2]*smtNode) *smtNode {
+	return &smtNode{
+		hash:     hashMerkleNode(smtHash(children[0]), smtHash(children[1])),
+		children: children,
+	}
+}
+
+// Root returns the root hash of the tree. The root of the empty tree is the
+// SHA256 of nothing, like the one of an empty MerkleRoot.
+func (t SparseMerkleTree) Root() []byte {
+	return smtHash(t.root)
+}
+
+func (t SparseMerkleTree) Len() int {
+	return t.size
+}
+
+// Get returns the leaf set for key.
+func (t SparseMerkleTree) Get(key []byte) ([]byte, bool) {
+	path := sha256.Sum256(key)
+	n := t.root
+	for depth := 0; n != nil && !n.isLeaf(); depth++ {
+		n = n.children[smtBit(path, depth)]
+	}
+	if n == nil || n.path != path {
+		return nil, false
+	}
+	return n.leaf, true
+}
+
+// Set returns the tree with the leaf for key set to leaf.
+func (t SparseMerkleTree) Set(key, leaf []byte) SparseMerkleTree {
+	path := sha256.Sum256(key)
+	n := &smtNode{hash: smtLeafHash(path, leaf), path: path, leaf: leaf}
+
+	root, added := smtSet(t.root, n, 0)
+	if added {
+		t.size++
+	}
+	return SparseMerkleTree{root: root, size: t.size}
+}
+
+func smtSet(n, leaf *smtNode, depth int) (*smtNode, bool) {
+	if n == nil {
+		return leaf, true
+	}
+	if n.isLeaf() {
+		if n.path == leaf.path {
+			return leaf, false
+		}
+		// both leaves go below a new branch, split further down while
+		// their paths agree
+		var children [2]*smtNode
+		children[smtBit(n.path, depth)] = n
+		bit := smtBit(leaf.path, depth)
+		children[bit], _ = smtSet(children[bit], leaf, depth+1)
+		return newSMTBranch(children), true
+	}
+
+	children := n.children
+	bit := smtBit(leaf.path, depth)
+	child, added := smtSet(children[bit], leaf, depth+1)
+	children[bit] = child
+	return newSMTBranch(children), added
+}
+
+// Delete returns the tree without the leaf for key.
+func (t SparseMerkleTree) Delete(key []byte) SparseMerkleTree {
+	root, removed := smtDelete(t.root, sha256.Sum256(key), 0)
+	if !removed {
+		return t
+	}
+	return SparseMerkleTree{root: root, size: t.size - 1}
+}
+
+func smtDelete(n *smtNode, path [32]byte, depth int) (*smtNode, bool) {
+	if n == nil {
+		return nil, false
+	}
+	if n.isLeaf() {
+		if n.path != path {
+			return n, false
+		}
+		return nil, true
+	}
+
+	children := n.children
+	bit := smtBit(path, depth)
+	child, removed := smtDelete(children[bit], path, depth+1)
+	if !removed {
+		return n, false
+	}
+	children[bit] = child
+
+	// a branch left with a single leaf below it gives way to that leaf
+	other := children[1-bit]
+	if child == nil && other.isLeaf() {
+		return other, true
+	}
+	if other == nil && child.isLeaf() {
+		return child, true
+	}
+	return newSMTBranch(children), true
+}
+
+// Prove returns the hashes of the siblings on the path from the root down to
+// the leaf for key, the root's child first.
+func (t SparseMerkleTree) Prove(key []byte) ([][]byte, bool) {
+	var (
+		path     = sha256.Sum256(key)
+		siblings [][]byte
+		n        = t.root
+	)
+	for depth := 0; n != nil && !n.isLeaf(); depth++ {
+		bit := smtBit(path, depth)
+		siblings = append(siblings, smtHash(n.children[1-bit]))
+		n = n.children[bit]
+	}
+	if n == nil || n.path != path {
+		return nil, false
+	}
+
+	return siblings, true
+}
+
+// VerifySparseMerkleProof checks that the tree with the given root has leaf
+// set for key.
+func VerifySparseMerkleProof(root, key, leaf []byte, siblings [][]byte) bool {
+	path := sha256.Sum256(key)
+	if len(siblings) > len(path)*8 {
+		return false
+	}
+
+	hash := smtLeafHash(path, leaf)
+	for depth := len(siblings) - 1; depth >= 0; depth-- {
+		if smtBit(path, depth) == 0 {
+			hash = hashMerkleNode(hash, siblings[depth])
+		} else {
+			hash = hashMerkleNode(siblings[depth], hash)
+		}
+	}
+
+	return bytes.Equal(hash, root)
+}
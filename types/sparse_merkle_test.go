@@ -0,0 +1,86 @@
+package types
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"github.com/pdrm26/blocker/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSparseMerkleProof(t *testing.T) {
+	var (
+		tree SparseMerkleTree
+		keys [][]byte
+	)
+	for i := 0; i < 50; i++ {
+		key := utils.RandomHash()
+		keys = append(keys, key)
+		tree = tree.Set(key, key[:4])
+	}
+	assert.Equal(t, 50, tree.Len())
+
+	for _, key := range keys {
+		siblings, ok := tree.Prove(key)
+		assert.True(t, ok)
+		assert.True(t, VerifySparseMerkleProof(tree.Root(), key, key[:4], siblings))
+		assert.False(t, VerifySparseMerkleProof(tree.Root(), key, key[4:8], siblings))
+		assert.False(t, VerifySparseMerkleProof(tree.Root(), keys[0][:8], key[:4], siblings))
+	}
+
+	_, ok := tree.Prove(utils.RandomHash())
+	assert.False(t, ok)
+}
+
+func TestSparseMerkleRootIgnoresOrder(t *testing.T) {
+	var keys [][]byte
+	for i := 0; i < 40; i++ {
+		keys = append(keys, utils.RandomHash())
+	}
+
+	var forward, shuffled SparseMerkleTree
+	for _, key := range keys {
+		forward = forward.Set(key, key)
+	}
+	rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+	for _, key := range keys {
+		shuffled = shuffled.Set(key, key)
+	}
+	assert.Equal(t, forward.Root(), shuffled.Root())
+
+	// taking leaves out gives the tree that never had them, and leaves the
+	// old tree as it was
+	var half SparseMerkleTree
+	for _, key := range keys[:20] {
+		half = half.Set(key, key)
+	}
+	pruned := forward
+	for _, key := range keys[20:] {
+		pruned = pruned.Delete(key)
+	}
+	assert.Equal(t, half.Root(), pruned.Root())
+	assert.Equal(t, 20, pruned.Len())
+	assert.Equal(t, shuffled.Root(), forward.Root())
+
+	for _, key := range keys[:20] {
+		pruned = pruned.Delete(key)
+	}
+	assert.Equal(t, SparseMerkleTree{}.Root(), pruned.Root())
+	assert.Equal(t, MerkleRoot(nil), pruned.Root())
+}
+
+func TestSparseMerkleSetReplaces(t *testing.T) {
+	var (
+		key  = utils.RandomHash()
+		tree = SparseMerkleTree{}.Set(key, []byte("old"))
+	)
+	updated := tree.Set(key, []byte("new"))
+
+	assert.Equal(t, 1, updated.Len())
+	assert.NotEqual(t, tree.Root(), updated.Root())
+	leaf, ok := updated.Get(key)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("new"), leaf)
+	leaf, _ = tree.Get(key)
+	assert.Equal(t, []byte("old"), leaf)
+}
@@ -5,34 +5,40 @@ import (
 
 	"github.com/pdrm26/blocker/crypto"
 	"github.com/pdrm26/blocker/proto"
-	pb "google.golang.org/protobuf/proto"
 )
 
+// HashTransaction returns a SHA256 of the canonical encoding of the
+// transaction.
 func HashTransaction(tx *proto.Transaction) []byte {
-	b, err := pb.Marshal(tx)
-	if err != nil {
-		panic(err)
-	}
+	hash := sha256.Sum256(EncodeTransaction(tx))
+	return hash[:]
+}
 
-	hash := sha256.Sum256(b)
+// hashTransactionForSigning returns the hash every input of tx signs. It
+// leaves all signatures out, so an input can be signed without the others
+// and verifying never has to touch the tx.
+func hashTransactionForSigning(tx *proto.Transaction) []byte {
+	hash := sha256.Sum256(EncodeTransactionForSigning(tx))
 	return hash[:]
 }
 
 func SignTransaction(tx *proto.Transaction, privKey *crypto.PrivateKey) *crypto.Signature {
-	return privKey.Sign(HashTransaction(tx))
+	return privKey.Sign(hashTransactionForSigning(tx))
 }
 
 func VerifyTransaction(tx *proto.Transaction) bool {
+	hash := hashTransactionForSigning(tx)
 	for _, input := range tx.Inputs {
-		if len(input.Signature) == 0 {
-			panic("transaction has no signature")
+		pubKey, err := crypto.PublicKeyFromBytes(input.PublicKey)
+		if err != nil {
+			return false
+		}
+		sig, err := crypto.SignatureFromBytes(input.Signature)
+		if err != nil {
+			return false
 		}
 
-		pubKey := crypto.PublicKeyFromBytes(input.PublicKey)
-		sig := crypto.SignatureFromBytes(input.Signature)
-
-		input.Signature = nil
-		if !sig.Verify(pubKey, HashTransaction(tx)) {
+		if !sig.Verify(pubKey, hash) {
 			return false
 		}
 	}
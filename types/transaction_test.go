@@ -39,4 +39,53 @@ func TestTransaction(t *testing.T) {
 	input.Signature = sign.Bytes()
 
 	assert.True(t, VerifyTransaction(tx))
+	// verifying must leave the signatures in place
+	assert.Equal(t, sign.Bytes(), input.Signature)
+	assert.True(t, VerifyTransaction(tx))
+}
+
+func TestVerifyTransactionWithoutSignature(t *testing.T) {
+	privKey := crypto.NewPrivateKey()
+	tx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash: utils.RandomHash(),
+				PublicKey:  privKey.Public().Bytes(),
+			},
+		},
+	}
+
+	assert.False(t, VerifyTransaction(tx))
+
+	tx.Inputs[0].Signature = SignTransaction(tx, privKey).Bytes()
+	tx.Inputs[0].PublicKey = tx.Inputs[0].PublicKey[1:]
+	assert.False(t, VerifyTransaction(tx))
+}
+
+func TestSignTransactionWithManyInputs(t *testing.T) {
+	var (
+		alice = crypto.NewPrivateKey()
+		bob   = crypto.NewPrivateKey()
+		tx    = &proto.Transaction{
+			Version: 1,
+			Inputs: []*proto.TxInput{
+				{PrevTxHash: utils.RandomHash(), PublicKey: alice.Public().Bytes()},
+				{PrevTxHash: utils.RandomHash(), PrevOutIndex: 1, PublicKey: bob.Public().Bytes()},
+			},
+			Outputs: []*proto.TxOutput{{Amount: 100, Address: alice.Public().Address().Bytes()}},
+		}
+	)
+
+	// each input signs the same hash, whichever is signed first
+	tx.Inputs[1].Signature = SignTransaction(tx, bob).Bytes()
+	tx.Inputs[0].Signature = SignTransaction(tx, alice).Bytes()
+	assert.True(t, VerifyTransaction(tx))
+
+	// the signatures commit to everything but the signatures
+	tx.Outputs[0].Amount = 99
+	assert.False(t, VerifyTransaction(tx))
+	tx.Outputs[0].Amount = 100
+	tx.Inputs[1].PrevOutIndex = 0
+	assert.False(t, VerifyTransaction(tx))
 }